@@ -0,0 +1,58 @@
+// Copyright 2024 Vladislav Klimenko. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"sync"
+
+	"github.com/sunsingerus/pipeline/pkg/admin"
+	"github.com/sunsingerus/pipeline/pkg/controller"
+	"github.com/sunsingerus/pipeline/pkg/controller/dlq"
+	"github.com/sunsingerus/pipeline/pkg/controller/health"
+	"github.com/sunsingerus/pipeline/pkg/controller/metrics"
+	"github.com/sunsingerus/pipeline/pkg/controller/tracing"
+	"github.com/sunsingerus/pipeline/pkg/logger"
+)
+
+// adminOptions specifies options of the admin server
+var adminOptions admin.Options
+
+// adminInit launches admin server of the running pipeline, in case its address is specified.
+// Returns function, which stops the server
+func adminInit(ctx context.Context, ctrl *controller.Controller) func() {
+	if adminOptions.Address == "" {
+		return func() {}
+	}
+	server := admin.New(adminOptions)
+	server.Handle("/healthz", health.Healthz())
+	server.Handle("/readyz", health.Readyz(ctrl.Health()))
+	server.Handle("/livez", health.Livez(ctrl.Health()))
+	server.Handle("/stats", metrics.Handler(ctrl.Stats))
+	server.Handle("/dlq", dlq.Handler(ctrl.DeadLetter()))
+	server.Handle("/log/level", logger.Handler())
+	if tracer := ctrl.Tracer(); tracer != nil {
+		server.Handle("/traces", tracing.Handler(tracer))
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	wg := new(sync.WaitGroup)
+	wg.Add(1)
+	go server.Run(ctx, wg)
+	return func() {
+		cancel()
+		wg.Wait()
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright 2024 Vladislav Klimenko. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MakeNowJust/heredoc"
+	log "github.com/sirupsen/logrus"
+	cmd "github.com/spf13/cobra"
+	vprConfig "github.com/spf13/viper"
+
+	"github.com/sunsingerus/pipeline/pkg/bench"
+)
+
+// Available bench output formats
+const (
+	benchFormatTable = "table"
+	benchFormatJSON  = "json"
+)
+
+var (
+	benchOptions        bench.Options
+	benchWorkers        string
+	benchPacketSizes    string
+	benchDurationSecond int
+	benchFormat         string
+)
+
+var benchCmd = &cmd.Command{
+	Use:   "bench [OPTION(s)]",
+	Short: "Benchmark pipeline throughput and latency",
+	Long: heredoc.Docf(`
+		Run the pipeline with the generator delivering packets as fast as possible for each combination
+		of workers and packet sizes, then print throughput, end-to-end latency percentiles of the traced packets,
+		allocations per packet and CPU usage of each run.
+	`),
+	Args: cmd.NoArgs,
+	RunE: func(cmd *cmd.Command, args []string) error {
+		var err error
+		if benchOptions.Workers, err = parseInts(benchWorkers); err != nil {
+			return fmt.Errorf("workers: %v", err)
+		}
+		if benchOptions.PacketSizes, err = parseInts(benchPacketSizes); err != nil {
+			return fmt.Errorf("packet sizes: %v", err)
+		}
+		benchOptions.Duration = time.Duration(benchDurationSecond) * time.Second
+		switch benchFormat {
+		case benchFormatTable, benchFormatJSON:
+		default:
+			return fmt.Errorf("unknown format %q", benchFormat)
+		}
+
+		results, err := bench.Run(contextInit(), newConfig(), benchOptions)
+		if err != nil {
+			return err
+		}
+		if benchFormat == benchFormatJSON {
+			return bench.WriteJSON(os.Stdout, results)
+		}
+		return bench.WriteTable(os.Stdout, results)
+	},
+}
+
+// parseInts parses comma-separated positive numbers
+func parseInts(str string) ([]int, error) {
+	var ints []int
+	for _, item := range strings.Split(str, ",") {
+		i, err := strconv.Atoi(strings.TrimSpace(item))
+		if err != nil {
+			return nil, err
+		}
+		if i <= 0 {
+			return nil, fmt.Errorf("not a positive number %d", i)
+		}
+		ints = append(ints, i)
+	}
+	return ints, nil
+}
+
+func init() {
+	// Options (CLI+ENV)
+	pFlagString(benchCmd, "bench-workers", "", "comma-separated numbers of workers to run with", "1,2,4", &benchWorkers)
+	pFlagString(benchCmd, "bench-packet-sizes", "", "comma-separated sizes (items) of the generated packets to run with", "10,100", &benchPacketSizes)
+	pFlagInt(benchCmd, "bench-duration", "", "max time in seconds of each run", int(bench.DefaultDuration/time.Second), &benchDurationSecond)
+	pFlagInt64(benchCmd, "bench-packets", "", "number of packets each run ends after, 0 means each run lasts for bench-duration", 0, &benchOptions.Packets)
+	pFlagFloat64(benchCmd, "latency-sample", "", "ratio of packets traced for end-to-end latency, from 0 to 1", bench.DefaultLatencySample, &benchOptions.LatencySample)
+	pFlagString(benchCmd, "format", "", "output format, one of: table,json", benchFormatTable, &benchFormat)
+	pFlagString(benchCmd, "stages", "", "chain of processing stages to benchmark, see 'serve --stages' (default single top-N stage)", "", &stages)
+
+	// Bind full flag set to the configuration
+	if err := vprConfig.BindPFlags(benchCmd.PersistentFlags()); err != nil {
+		log.Fatal(err)
+	}
+
+	rootCmd.AddCommand(benchCmd)
+}
@@ -15,6 +15,7 @@
 package cmd
 
 import (
+	"strconv"
 	"strings"
 
 	cmd "github.com/spf13/cobra"
@@ -33,6 +34,65 @@ func pFlagInt(cmd *cmd.Command, name, short, description string, defaultValue in
 	cmd.PersistentFlags().IntVarP(variable, name, short, viper.GetInt(name), description)
 }
 
+// pFlagInt64 creates persistent flag with int64 value
+func pFlagInt64(cmd *cmd.Command, name, short, description string, defaultValue int64, variable *int64) {
+	viper.SetDefault(name, defaultValue)
+	cmd.PersistentFlags().Int64VarP(variable, name, short, viper.GetInt64(name), description)
+}
+
+// pFlagFloat64 creates persistent flag with float64 value
+func pFlagFloat64(cmd *cmd.Command, name, short, description string, defaultValue float64, variable *float64) {
+	viper.SetDefault(name, defaultValue)
+	cmd.PersistentFlags().Float64VarP(variable, name, short, viper.GetFloat64(name), description)
+}
+
+// pFlagBool creates persistent flag with bool value
+func pFlagBool(cmd *cmd.Command, name, short, description string, defaultValue bool, variable *bool) {
+	viper.SetDefault(name, defaultValue)
+	cmd.PersistentFlags().BoolVarP(variable, name, short, viper.GetBool(name), description)
+}
+
+// optionalFloat64 specifies float64 value of the flag, which is nil unless set
+type optionalFloat64 struct {
+	value **float64
+}
+
+// Set sets the value, empty string unsets it
+func (f optionalFloat64) Set(s string) error {
+	if s == "" {
+		*f.value = nil
+		return nil
+	}
+	value, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return err
+	}
+	*f.value = &value
+	return nil
+}
+
+// String returns the value, empty string in case it is not set
+func (f optionalFloat64) String() string {
+	if *f.value == nil {
+		return ""
+	}
+	return strconv.FormatFloat(**f.value, 'g', -1, 64)
+}
+
+// Type returns type of the value
+func (f optionalFloat64) Type() string {
+	return "float64"
+}
+
+// pFlagOptionalFloat64 creates persistent flag with float64 value, which is nil unless the flag is set
+func pFlagOptionalFloat64(cmd *cmd.Command, name, short, description string, variable **float64) {
+	viper.SetDefault(name, "")
+	value := optionalFloat64{value: variable}
+	// Invalid value of env is reported, once the flag is set from the configuration
+	_ = value.Set(viper.GetString(name))
+	cmd.PersistentFlags().VarP(value, name, short, description)
+}
+
 // flagInit initializes flag components
 func flagInit() {
 	// By default, empty environment variables are considered unset and will fall back to the next configuration source.
@@ -0,0 +1,187 @@
+// Copyright 2024 Vladislav Klimenko. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"syscall"
+
+	"github.com/fsnotify/fsnotify"
+	log "github.com/sirupsen/logrus"
+	cmd "github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+
+	"github.com/sunsingerus/pipeline/pkg/controller"
+	"github.com/sunsingerus/pipeline/pkg/logger"
+)
+
+// configFile specifies configuration file, which provides values of flags not set on the command line
+var configFile string
+
+// configInit reads configuration file, if specified, and sets flags of the command from it
+func configInit(command *cmd.Command) error {
+	if configFile != "" {
+		viper.SetConfigFile(configFile)
+		if err := viper.ReadInConfig(); err != nil {
+			return fmt.Errorf("unable to read config file %s: %v", configFile, err)
+		}
+	}
+	return configApply(command)
+}
+
+// configApply sets flags of the command, which are not set on the command line, from the configuration.
+// Flags, which are removed from the configuration, fall back to env or their defaults.
+func configApply(command *cmd.Command) error {
+	var err error
+	command.Flags().VisitAll(func(flag *pflag.Flag) {
+		// Flags not bound to the configuration, ex.: help, are skipped
+		if flag.Changed || (err != nil) || !viper.IsSet(flag.Name) {
+			return
+		}
+		if value := viper.GetString(flag.Name); value != flag.Value.String() {
+			if e := flag.Value.Set(value); e != nil {
+				err = fmt.Errorf("invalid value %q of %s: %v", value, flag.Name, e)
+			}
+		}
+	})
+	return err
+}
+
+// reloadInit returns channel, which is signalled on SIGHUP and on change of the configuration file, until context is done
+func reloadInit(ctx context.Context) <-chan struct{} {
+	reloads := make(chan struct{}, 1)
+	notify := func() {
+		// Pending reload re-reads the latest configuration anyway
+		select {
+		case reloads <- struct{}{}:
+		default:
+		}
+	}
+
+	hupChan := make(chan os.Signal, 1)
+	signal.Notify(hupChan, syscall.SIGHUP)
+	go func() {
+		defer signal.Stop(hupChan)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hupChan:
+				log.Info("Reload - SIGHUP received")
+				notify()
+			}
+		}
+	}()
+
+	if configFile == "" {
+		return reloads
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		log.Warnf("Reload - unable to watch config file: %v, reload on SIGHUP only", err)
+		return reloads
+	}
+	// Directory is watched, since editors replace files instead of writing them
+	file := filepath.Clean(configFile)
+	if err := watcher.Add(filepath.Dir(file)); err != nil {
+		log.Warnf("Reload - unable to watch config file: %v, reload on SIGHUP only", err)
+		_ = watcher.Close()
+		return reloads
+	}
+	go func() {
+		defer watcher.Close()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event := <-watcher.Events:
+				if (filepath.Clean(event.Name) == file) && event.Has(fsnotify.Write|fsnotify.Create) {
+					log.Infof("Reload - config file %s changed", file)
+					notify()
+				}
+			case err := <-watcher.Errors:
+				log.Warnf("Reload - config file watch: %v", err)
+			}
+		}
+	}()
+	return reloads
+}
+
+// reload re-reads configuration and applies it to the running pipeline
+func reload(command *cmd.Command, ctrl *controller.Controller) {
+	before := logOptions()
+	if configFile != "" {
+		if err := viper.ReadInConfig(); err != nil {
+			log.Errorf("Reload - unable to read config file %s: %v", configFile, err)
+			return
+		}
+	}
+	if err := configApply(command); err != nil {
+		log.Errorf("Reload - %v", err)
+		return
+	}
+
+	var applied []string
+	loggerOptionsInit()
+	after := logOptions()
+	for _, name := range sortedNames(after) {
+		if before[name] != after[name] {
+			applied = append(applied, fmt.Sprintf("%s: %s -> %s", name, before[name], after[name]))
+		}
+	}
+	if len(applied) > 0 {
+		logger.Init()
+	}
+	_applied, rejected := ctrl.Reload(newConfig())
+	applied = append(applied, _applied...)
+
+	if len(applied)+len(rejected) == 0 {
+		log.Info("Reload - no changes")
+	}
+	for _, change := range applied {
+		log.Infof("Reload - applied : %s", change)
+	}
+	for _, change := range rejected {
+		log.Warnf("Reload - rejected: %s", change)
+	}
+}
+
+// logOptions returns logging options by names of their flags
+func logOptions() map[string]string {
+	return map[string]string{
+		"log-level":             logger.Level,
+		"log-format":            logger.Formatter,
+		"log-levels":            logger.ComponentLevels,
+		"log-sampling":          logger.Sampling,
+		"log-sampling-interval": logger.SamplingInterval.String(),
+		"log-file":              fmt.Sprintf("%+v", logger.File),
+	}
+}
+
+// sortedNames returns names of the options in sorted order
+func sortedNames(options map[string]string) []string {
+	names := make([]string, 0, len(options))
+	for name := range options {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,88 @@
+// Copyright 2024 Vladislav Klimenko. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/sunsingerus/pipeline/pkg/admin"
+	"github.com/sunsingerus/pipeline/pkg/diagnostics"
+)
+
+var (
+	// diagnosticsOptions specifies options of the diagnostics server
+	diagnosticsOptions    admin.Options
+	profileOptions        diagnostics.ProfileOptions
+	profileDurationSecond int
+)
+
+// diagnosticsInit launches diagnostics server, in case its address is specified. Returns function, which stops the server
+func diagnosticsInit(ctx context.Context) func() {
+	if diagnosticsOptions.Address == "" {
+		return func() {}
+	}
+	diagnosticsOptions.Name = "Diagnostics"
+	server := admin.New(diagnosticsOptions)
+	diagnostics.Register(server)
+
+	ctx, cancel := context.WithCancel(ctx)
+	wg := new(sync.WaitGroup)
+	wg.Add(1)
+	go server.Run(ctx, wg)
+	return func() {
+		cancel()
+		wg.Wait()
+	}
+}
+
+// profileInit captures CPU and heap profiles into the profile directory on each SIGUSR1 until context is done
+func profileInit(ctx context.Context) {
+	profileOptions.Duration = time.Duration(profileDurationSecond) * time.Second
+	if len(profileSignals) == 0 {
+		return
+	}
+	profiler := diagnostics.NewProfiler(profileOptions)
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, profileSignals...)
+	go func() {
+		defer signal.Stop(signals)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-signals:
+				if profileOptions.Dir == "" {
+					log.Warn("Profiler - SIGUSR1 ignored, no profile directory specified")
+					continue
+				}
+				go func() {
+					paths, err := profiler.Capture(ctx)
+					if err != nil {
+						log.Errorf("Profiler - unable to capture profiles: %v", err)
+					}
+					if len(paths) > 0 {
+						log.Infof("Profiler - written %v", paths)
+					}
+				}()
+			}
+		}
+	}()
+}
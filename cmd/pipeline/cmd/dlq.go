@@ -0,0 +1,155 @@
+// Copyright 2024 Vladislav Klimenko. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/MakeNowJust/heredoc"
+	log "github.com/sirupsen/logrus"
+	cmd "github.com/spf13/cobra"
+	vprConfig "github.com/spf13/viper"
+
+	"github.com/sunsingerus/pipeline/pkg/controller/dlq"
+)
+
+var (
+	dlqFile         string
+	dlqAdminAddress string
+	reinjectNetwork string
+	reinjectAddress string
+	reinjectOutput  string
+)
+
+var dlqCmd = &cmd.Command{
+	Use:   "dlq [COMMAND]",
+	Short: "Manage dead-lettered packets",
+	Long: heredoc.Docf(`
+		Manage packets dead-lettered by the pipeline into the dead-letter file.
+		Run 'serve' with '--dlq file --dlq-file <path>' or with '--dlq memory --dlq-file <path>' to have dead-letter file.
+		Packets kept in memory by the pipeline running with '--dlq memory --admin-address <address>' are read from its admin server.
+	`),
+}
+
+var dlqListCmd = &cmd.Command{
+	Use:   "list [OPTION(s)]",
+	Short: "List dead-lettered packets",
+	Args:  cmd.NoArgs,
+	RunE: func(cmd *cmd.Command, args []string) error {
+		entries, err := dlqRead()
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "TIME\tSTAGE\tWORKER\tPACKET\tERROR")
+		for _, entry := range entries {
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%d\t[%s]\t%s\n", entry.Time.Format(time.RFC3339Nano), entry.Stage, entry.WorkerID, entry.Line(), entry.Error)
+		}
+		return w.Flush()
+	},
+}
+
+var dlqReinjectCmd = &cmd.Command{
+	Use:   "reinject [OPTION(s)]",
+	Short: "Re-inject dead-lettered packets",
+	Long: heredoc.Docf(`
+		Re-inject dead-lettered packets into a pipeline running with tcp or unix source,
+		or write them into a file, which can be used as a file source.
+	`),
+	Args: cmd.NoArgs,
+	RunE: func(cmd *cmd.Command, args []string) error {
+		entries, err := dlqRead()
+		if err != nil {
+			return err
+		}
+
+		var w io.WriteCloser
+		switch {
+		case reinjectOutput != "":
+			w, err = os.OpenFile(reinjectOutput, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		case reinjectAddress != "":
+			w, err = net.Dial(reinjectNetwork, reinjectAddress)
+		default:
+			err = fmt.Errorf("either address or output has to be specified")
+		}
+		if err != nil {
+			return err
+		}
+
+		buf := bufio.NewWriter(w)
+		for _, entry := range entries {
+			if _, err := fmt.Fprintln(buf, entry.Line()); err != nil {
+				_ = w.Close()
+				return err
+			}
+		}
+		if err := buf.Flush(); err != nil {
+			_ = w.Close()
+			return err
+		}
+		log.Infof("Re-injected %d packets", len(entries))
+		return w.Close()
+	},
+}
+
+// dlqRead reads dead-lettered packets either from the admin server or from the dead-letter file
+func dlqRead() ([]dlq.Entry, error) {
+	switch {
+	case dlqAdminAddress != "":
+		resp, err := http.Get("http://" + dlqAdminAddress + "/dlq")
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			body, _ := io.ReadAll(resp.Body)
+			return nil, fmt.Errorf("unable to get dead-lettered packets from %s: %s %s", dlqAdminAddress, resp.Status, body)
+		}
+		return dlq.Read(resp.Body)
+	case dlqFile != "":
+		return dlq.ReadFile(dlqFile)
+	default:
+		return nil, fmt.Errorf("either address or dead-letter file has to be specified")
+	}
+}
+
+func init() {
+	// Options (CLI+ENV)
+	pFlagString(dlqCmd, "dlq-file", "", "dead-letter file", "", &dlqFile)
+	pFlagString(dlqCmd, "admin-address", "", "address of the admin server of the running pipeline to get packets kept in memory from", "", &dlqAdminAddress)
+	pFlagString(dlqReinjectCmd, "network", "", "network of the pipeline source, one of: tcp,unix", "tcp", &reinjectNetwork)
+	pFlagString(dlqReinjectCmd, "address", "", "address of the pipeline source", "", &reinjectAddress)
+	pFlagString(dlqReinjectCmd, "output", "", "file to write packets to instead of sending them to the pipeline", "", &reinjectOutput)
+
+	// Bind full flag set to the configuration
+	if err := vprConfig.BindPFlags(dlqCmd.PersistentFlags()); err != nil {
+		log.Fatal(err)
+	}
+	if err := vprConfig.BindPFlags(dlqReinjectCmd.PersistentFlags()); err != nil {
+		log.Fatal(err)
+	}
+
+	dlqCmd.AddCommand(dlqListCmd)
+	dlqCmd.AddCommand(dlqReinjectCmd)
+	rootCmd.AddCommand(dlqCmd)
+}
@@ -15,6 +15,8 @@
 package cmd
 
 import (
+	"time"
+
 	"github.com/MakeNowJust/heredoc"
 	log "github.com/sirupsen/logrus"
 	cmd "github.com/spf13/cobra"
@@ -23,18 +25,36 @@ import (
 	"github.com/sunsingerus/pipeline/pkg/logger"
 )
 
+var (
+	// logSamplingIntervalMillisecond specifies interval log sampling starts over
+	logSamplingIntervalMillisecond int
+	logFileMaxSizeMegabyte         int
+	logFileMaxAgeSecond            int
+)
+
 // rootCmd represents the base command when called without any sub-commands
 var rootCmd = &cmd.Command{
 	Use:   "pipeline [COMMAND]",
 	Short: "Pipeline service.",
 	Long:  heredoc.Docf(`Pipeline service is used to serve pipelines. Pipeline with caution.`),
 	PersistentPreRun: func(cmd *cmd.Command, args []string) {
+		if err := configInit(cmd); err != nil {
+			log.Fatal(err)
+		}
+		loggerOptionsInit()
 		logger.Init()
 		log.Infof(heredoc.Docf(`
 				Starting root:
 				log-level: %s
 				log-format: %s
-			`, logger.Level, logger.Formatter))
+				log-levels: %s
+				log-sampling: %s
+				log-file: %s
+				config: %s
+			`, logger.Level, logger.Formatter, logger.ComponentLevels, logger.Sampling, logger.File.Path, configFile))
+	},
+	PersistentPostRun: func(cmd *cmd.Command, args []string) {
+		logger.Close()
 	},
 }
 
@@ -42,7 +62,16 @@ func init() {
 	flagInit()
 	// Options (CLI+ENV)
 	pFlagString(rootCmd, "log-level", "l", "log level, one of: panic,fatal,error,warn,warning,info,debug,trace", "info", &logger.Level)
-	pFlagString(rootCmd, "log-format", "f", "log format, one of: text,json", "text", &logger.Formatter)
+	pFlagString(rootCmd, "log-format", "f", "log format, one of: text,json,logfmt,console (compact, colored on terminal)", "text", &logger.Formatter)
+	pFlagString(rootCmd, "log-levels", "", "log levels of components, which log with level other than log-level, ex.: 'processor=debug,generator=warn'. Components: generator,join-generator,processor,pool,accum,publisher,join,topology,stage,watchdog", "", &logger.ComponentLevels)
+	pFlagString(rootCmd, "log-sampling", "", "sampling of component logs as component=first/every items, ex.: 'processor=10/100,*=5/0'. Each interval first messages of each event are logged, then every one of them, number of suppressed messages is logged at the end of the interval. * specifies components with no sampling specified (default no sampling)", "", &logger.Sampling)
+	pFlagInt(rootCmd, "log-sampling-interval", "", "interval in milliseconds log sampling starts over", int(logger.DefaultSamplingInterval/time.Millisecond), &logSamplingIntervalMillisecond)
+	pFlagString(rootCmd, "log-file", "", "file to write logs to instead of stderr", "", &logger.File.Path)
+	pFlagInt(rootCmd, "log-file-max-size", "", "size in megabytes log file is rotated on reaching, 0 means no size-based rotation", logger.DefaultFileMaxSize/1024/1024, &logFileMaxSizeMegabyte)
+	pFlagInt(rootCmd, "log-file-max-age", "", "age in seconds log file is rotated on reaching, 0 means no age-based rotation", int(logger.DefaultFileMaxAge/time.Second), &logFileMaxAgeSecond)
+	pFlagInt(rootCmd, "log-file-max-backups", "", "number of rotated log files kept, 0 means all of them", logger.DefaultFileMaxBackups, &logger.File.MaxBackups)
+	pFlagBool(rootCmd, "log-file-compress", "", "compress rotated log files with gzip", false, &logger.File.Compress)
+	rootCmd.PersistentFlags().StringVarP(&configFile, "config", "c", "", "configuration file (yaml, json, toml), its keys are flag names, ex.: 'workers: 4'. Flags set on the command line take precedence. Serve reloads it on change and on SIGHUP")
 
 	// Bind full flag set to the configuration
 	if err := viper.BindPFlags(rootCmd.PersistentFlags()); err != nil {
@@ -50,6 +79,13 @@ func init() {
 	}
 }
 
+// loggerOptionsInit converts logging flags into logger options
+func loggerOptionsInit() {
+	logger.SamplingInterval = time.Duration(logSamplingIntervalMillisecond) * time.Millisecond
+	logger.File.MaxSize = int64(logFileMaxSizeMegabyte) * 1024 * 1024
+	logger.File.MaxAge = time.Duration(logFileMaxAgeSecond) * time.Second
+}
+
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
 		log.Fatal(err)
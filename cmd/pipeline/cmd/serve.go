@@ -21,20 +21,66 @@ import (
 	cmd "github.com/spf13/cobra"
 	vprConfig "github.com/spf13/viper"
 	"github.com/sunsingerus/pipeline/pkg/controller"
+	"github.com/sunsingerus/pipeline/pkg/controller/dlq"
+	"github.com/sunsingerus/pipeline/pkg/controller/generator/arrival"
+	"github.com/sunsingerus/pipeline/pkg/controller/generator/packet_builder"
+	"github.com/sunsingerus/pipeline/pkg/controller/generator/source"
+	"github.com/sunsingerus/pipeline/pkg/controller/health"
+	"github.com/sunsingerus/pipeline/pkg/controller/join"
+	"github.com/sunsingerus/pipeline/pkg/controller/pool"
+	"github.com/sunsingerus/pipeline/pkg/controller/processor"
+	"github.com/sunsingerus/pipeline/pkg/controller/supervisor"
+	"github.com/sunsingerus/pipeline/pkg/controller/tracing"
+	"github.com/sunsingerus/pipeline/pkg/controller/transform"
+	"github.com/sunsingerus/pipeline/pkg/controller/watchdog"
+	"github.com/sunsingerus/pipeline/pkg/dashboard"
+	"github.com/sunsingerus/pipeline/pkg/diagnostics"
 	"os"
 	"os/signal"
-	"sync"
 	"syscall"
 	"time"
 )
 
 var (
 	generatorIntervalMillisecond int
+	generatorCatchUp             bool
+	arrivalOptions               arrival.Options
+	burstIntervalMillisecond     int
+	sourceOptions                source.Options
+	deadLetterOptions            dlq.Options
+	supervisorOptions            supervisor.Options
+	restartBackoffMinMillisecond int
+	restartBackoffMaxMillisecond int
+	rampDurationSecond           int
 	publisherIntervalSecond      int
 	packetSizeIn                 int
 	packetSizeOut                int
+	packetSizeMax                int
+	packetSizeDistribution       packetbuilder.DistributionOptions
+	shortPacketPolicy            string
+	stages                       string
+	alert                        string
+	alertCopy                    bool
+	joinOptions                  join.Options
+	joinIntervalMillisecond      int
+	joinWindowMillisecond        int
+	packetDeadlineMillisecond    int
+	deadlinePolicy               string
+	slowPercentile               float64
 	workersNum                   int
+	poolOptions                  pool.Options
+	gapPolicy                    string
+	gapTimeoutMillisecond        int
 	runTimeoutSecond             int
+	seed                         int64
+	valueMin                     int
+	valueMax                     int
+	distribution                 packetbuilder.DistributionOptions
+	tracingOptions               tracing.Options
+	healthOptions                health.Options
+	stallTimeoutSecond           int
+	watchdogOptions              watchdog.Options
+	watchdogIntervalMillisecond  int
 )
 
 var serveCmd = &cmd.Command{
@@ -53,13 +99,32 @@ var serveCmd = &cmd.Command{
 			Options:
 			----------------------------
 			generator-interval (ms)    : %d
+			arrival                    : %s
+			source                     : %s
 			publisher-interval (s)     : %d
 			packet-size-in     (items) : %d
 			packet-size-out    (items) : %d
+			packet-size-max    (items) : %d
+			packet-size-dist           : %s
+			short-packet               : %s
+			stages                     : %s
+			alert                      : %s
+			join                       : %s
+			packet-deadline    (ms)    : %d
+			deadline-policy            : %s
+			slow-percentile            : %g
+			dlq                        : %s
 			workers            (num)   : %d
+			ordered                    : %t
+			sharded                    : %t
 			timeout            (s)     : %d
+			seed                       : %d
+			value-min                  : %d
+			value-max                  : %d
+			distribution               : %s
+			trace-sample               : %g
 			----------------------------
-`, generatorIntervalMillisecond, publisherIntervalSecond, packetSizeIn, packetSizeOut, workersNum, runTimeoutSecond))
+`, generatorIntervalMillisecond, arrivalOptions.Kind, sourceOptions.Kind, publisherIntervalSecond, packetSizeIn, packetSizeOut, packetSizeMax, packetSizeDistribution.Kind, shortPacketPolicy, stages, alert, joinOptions.Mode, packetDeadlineMillisecond, deadlinePolicy, slowPercentile, deadLetterOptions.Kind, workersNum, poolOptions.Ordered, poolOptions.Sharded, runTimeoutSecond, seed, valueMin, valueMax, distribution.Kind, tracingOptions.Sample))
 
 		if runTimeoutSecond > 0 {
 			log.Infof("Will run for %d sec", runTimeoutSecond)
@@ -67,10 +132,35 @@ var serveCmd = &cmd.Command{
 			ctx, fn = context.WithTimeout(ctx, time.Duration(runTimeoutSecond)*time.Second)
 			defer fn()
 		}
-		wg, cancel := run(ctx)
-		contextWait(ctx)
+		ctrl := controller.New(newConfig())
+		wg, cancel, err := ctrl.Run(ctx)
+		if err != nil {
+			log.Fatalf("Unable to run pipeline: %v", err)
+		}
+		stopAdmin := adminInit(ctx, ctrl)
+		stopDiagnostics := diagnosticsInit(ctx)
+		stopDashboard := dashboardInit(ctx, ctrl)
+		reloads := reloadInit(ctx)
+		go func() {
+			for {
+				select {
+				case <-ctrl.Done():
+					return
+				case <-reloads:
+					reload(cmd, ctrl)
+				}
+			}
+		}()
+		contextWait(ctrl.Done())
 		wg.Wait()
 		cancel()
+		stopDashboard()
+		stopAdmin()
+		stopDiagnostics()
+		traceExport(ctrl.Tracer())
+		if err := ctrl.Err(); err != nil {
+			log.Fatalf("Shut down: %v", err)
+		}
 		log.Info("Shut down")
 	},
 }
@@ -79,11 +169,71 @@ func init() {
 	flagInit()
 	// Options (CLI+ENV)
 	pFlagInt(serveCmd, "generator-interval", "g", "interval in microseconds between packets produced by the generator", 1000, &generatorIntervalMillisecond)
+	pFlagBool(serveCmd, "catch-up", "", "generate packets due while delivery was blocked at once afterwards, instead of dropping them", false, &generatorCatchUp)
+	pFlagString(serveCmd, "arrival", "", "arrival process of generated packets, one of: regular,poisson,burst,ramp,steps", arrival.KindRegular, &arrivalOptions.Kind)
+	pFlagFloat64(serveCmd, "arrival-rate", "", "mean rate (packets per second) of poisson arrival", 0, &arrivalOptions.Rate)
+	pFlagInt(serveCmd, "burst-size", "", "number of packets in a burst of burst arrival", 0, &arrivalOptions.BurstSize)
+	pFlagInt(serveCmd, "burst-interval", "", "interval in milliseconds between bursts of burst arrival", 1000, &burstIntervalMillisecond)
+	pFlagFloat64(serveCmd, "ramp-from", "", "rate (packets per second) ramp arrival starts with", 0, &arrivalOptions.RampFrom)
+	pFlagFloat64(serveCmd, "ramp-to", "", "rate (packets per second) ramp arrival ends with", 0, &arrivalOptions.RampTo)
+	pFlagInt(serveCmd, "ramp-duration", "", "duration in seconds of ramp arrival", 0, &rampDurationSecond)
+	pFlagString(serveCmd, "arrival-profile", "", "path to step-function profile of steps arrival, each line is 'duration rate', ex.: '10s 100'", "", &arrivalOptions.Profile)
+	pFlagString(serveCmd, "source", "", "external source of packets instead of random packets, one of: stdin,file,tail,tcp,unix,http", "", &sourceOptions.Kind)
+	pFlagString(serveCmd, "source-address", "", "file path, network address or unix socket path of the source", "", &sourceOptions.Address)
+	pFlagString(serveCmd, "source-reject-file", "", "file to append malformed source lines to", "", &sourceOptions.RejectFile)
 	pFlagInt(serveCmd, "publisher-interval", "p", "interval in seconds between publisher reports", 1, &publisherIntervalSecond)
 	pFlagInt(serveCmd, "packet-size-in", "s", "size of the generated packet", 10, &packetSizeIn)
 	pFlagInt(serveCmd, "packet-size-out", "o", "size of the processed packet", 3, &packetSizeOut)
+	pFlagInt(serveCmd, "packet-size-max", "", "max size of the generated packet, when greater than packet-size-in sizes vary between them", 0, &packetSizeMax)
+	pFlagString(serveCmd, "packet-size-distribution", "", "distribution of generated packet sizes, one of: uniform,normal,exponential,zipf,poisson,drift", packetbuilder.DistributionUniform, &packetSizeDistribution.Kind)
+	pFlagString(serveCmd, "short-packet", "", "policy for packets shorter than packet-size-out, one of: pad,pass,reject", string(transform.ShortPacketPad), &shortPacketPolicy)
+	pFlagString(serveCmd, "stages", "", "chain of processing stages separated by |, each with optional parameters, ex.: 'filter(min=5)|dedupe|topn(n=3,workers=4,capacity=10)|scale(factor=2)'. Transforms: filter(min,max),dedupe,topn(n,short),scale(factor,offset). Stage parameters: name,workers,capacity,overflow (block,drop),ordered,sharded,key (default single topn stage of packet-size-out)", "", &stages)
+	pFlagString(serveCmd, "alert", "", "route processed packets matching the predicate to the alerts branch instead of the accumulator, ex.: 'max>15'. Keys: first,last,min,max,sum,len,id. Operators: >,>=,<,<=,==,!=", "", &alert)
+	pFlagBool(serveCmd, "alert-copy", "", "deliver packets matching the alert predicate to the accumulator as well, the alerts branch gets their copies", false, &alertCopy)
+	pFlagString(serveCmd, "join", "", "join generated packets with packets of the second generator, one of: sequence (pair packets with the same sequence number),window (pair packets arrived within the join window) (default no join)", "", &joinOptions.Mode)
+	pFlagInt(serveCmd, "join-interval", "", "interval in milliseconds between packets produced by the second generator of the join", 1000, &joinIntervalMillisecond)
+	pFlagInt(serveCmd, "join-window", "", "max time in milliseconds packet waits for a pair (default unlimited in sequence mode, 100 in window mode)", 0, &joinWindowMillisecond)
+	pFlagInt(serveCmd, "join-max-pending", "", "max number of packets waiting for a pair on each side of the join", join.DefaultMaxPending, &joinOptions.MaxPending)
+	pFlagString(serveCmd, "join-unmatched", "", "policy for packets with no pair, one of: drop,pass,deadletter", string(join.UnmatchedDrop), (*string)(&joinOptions.Unmatched))
+	pFlagInt(serveCmd, "packet-deadline", "", "max time in milliseconds to process a packet (default unlimited)", 0, &packetDeadlineMillisecond)
+	pFlagString(serveCmd, "deadline-policy", "", "policy for packets exceeded deadline, one of: deadletter,pass", string(processor.DeadlineDeadLetter), &deadlinePolicy)
+	pFlagFloat64(serveCmd, "slow-percentile", "", "percentile of recent processing times above which packets are logged as slow, ex.: 0.99 (default no slow packets log)", 0, &slowPercentile)
+	pFlagString(serveCmd, "dlq", "", "dead-letter sink of packets failed to be processed, one of: memory,file", dlq.KindMemory, &deadLetterOptions.Kind)
+	pFlagInt(serveCmd, "dlq-size", "", "number of the latest entries kept by memory dead-letter sink", dlq.DefaultSize, &deadLetterOptions.Size)
+	pFlagString(serveCmd, "dlq-file", "", "file of file dead-letter sink, memory sink dumps its entries into it on shut down", "", &deadLetterOptions.Path)
 	pFlagInt(serveCmd, "workers", "w", "workers number", 3, &workersNum)
+	pFlagBool(serveCmd, "sharded", "", "process packets with the same key by the same worker", false, &poolOptions.Sharded)
+	pFlagString(serveCmd, "shard-key", "", "key packets are sharded by, one of: first,sum,max,id", pool.ShardKeyFirst, &poolOptions.ShardKey)
+	pFlagBool(serveCmd, "ordered", "", "deliver processed packets in the order they were generated", false, &poolOptions.Ordered)
+	pFlagInt(serveCmd, "reorder-buffer", "", "max number of processed packets held while waiting for a missing packet in ordered mode", pool.DefaultReorderBuffer, &poolOptions.ReorderBuffer)
+	pFlagString(serveCmd, "gap-policy", "", "policy for a missing packet in ordered mode, one of: wait (until reorder buffer is full),skip (after gap timeout)", string(pool.GapWait), &gapPolicy)
+	pFlagInt(serveCmd, "gap-timeout", "", "time in milliseconds a missing packet is waited for by skip gap policy", int(pool.DefaultGapTimeout/time.Millisecond), &gapTimeoutMillisecond)
+	pFlagInt(serveCmd, "restart-backoff-min", "", "delay in milliseconds before the first restart of a crashed component", int(supervisor.DefaultBackoffMin/time.Millisecond), &restartBackoffMinMillisecond)
+	pFlagInt(serveCmd, "restart-backoff-max", "", "max delay in milliseconds before restart of a crashed component", int(supervisor.DefaultBackoffMax/time.Millisecond), &restartBackoffMaxMillisecond)
+	pFlagInt(serveCmd, "max-restarts", "", "max restarts of a component per minute, pipeline is stopped on exceeding it", supervisor.DefaultMaxRestarts, &supervisorOptions.MaxRestarts)
+	pFlagString(serveCmd, "admin-address", "", "address of the admin HTTP server, ex.: ':8080'. Endpoints: /healthz (process is alive), /readyz (all stages run and the generator has delivered packets), /livez (no stage is stalled), /stats (GET returns state of the pipeline as JSON), /log/level (GET lists log levels, POST component=<name>&level=<level> sets level of the component, level=reset resets it), /traces (GET returns finished traces as OTLP JSON, slowest=<n> limits them to the slowest ones) (default no admin server)", "", &adminOptions.Address)
+	pFlagString(serveCmd, "diagnostics-address", "", "address of the diagnostics HTTP server, ex.: ':6060'. Endpoints: /debug/pprof/ (net/http/pprof profiles), /debug/runtime (GET returns goroutines, heap and GC pause stats as JSON) (default no diagnostics server)", "", &diagnosticsOptions.Address)
+	pFlagString(serveCmd, "profile-dir", "", "directory to write CPU and heap profiles into on SIGUSR1 (default SIGUSR1 is ignored)", "", &profileOptions.Dir)
+	pFlagInt(serveCmd, "profile-duration", "", "time in seconds CPU profile is captured for on SIGUSR1", int(diagnostics.DefaultProfileDuration/time.Second), &profileDurationSecond)
+	pFlagInt(serveCmd, "stall-timeout", "", "time in seconds stage with packets in flight may make no progress before it is considered stalled: /livez of the admin server fails and the watchdog dumps state of the pipeline", int(health.DefaultStallTimeout/time.Second), &stallTimeoutSecond)
+	pFlagInt(serveCmd, "watchdog-interval", "", "interval in milliseconds the watchdog checks stages and edges between them for stalls at, 0 means no watchdog", int(watchdog.DefaultInterval/time.Millisecond), &watchdogIntervalMillisecond)
+	pFlagString(serveCmd, "stall-dump-file", "", "file to append dumps of goroutines and stage states to on detected stalls (default dumps are logged only)", "", &watchdogOptions.DumpFile)
+	pFlagBool(serveCmd, "dashboard", "", "show refreshing terminal view of the pipeline instead of scrolling logs, consider '--log-file' to keep logs", false, &dashboardEnabled)
+	pFlagInt(serveCmd, "dashboard-interval", "", "interval in milliseconds between dashboard refreshes", int(dashboard.DefaultInterval/time.Millisecond), &dashboardIntervalMillisecond)
+	pFlagFloat64(serveCmd, "trace-sample", "", "ratio of packets traced, from 0 to 1, ex.: 0.01. Traces are served by the admin server at /traces (default no tracing)", 0, &tracingOptions.Sample)
+	pFlagInt(serveCmd, "trace-size", "", "number of the latest finished traces kept", tracing.DefaultSize, &tracingOptions.Size)
+	pFlagString(serveCmd, "trace-file", "", "file to export the latest finished traces to as OTLP JSON on shut down", "", &traceFile)
 	pFlagInt(serveCmd, "timeout", "t", "run timeout in seconds (default unlimited)", 0, &runTimeoutSecond)
+	pFlagInt64(serveCmd, "seed", "", "seed of the packet generator, same seed produces same packets (default random)", 0, &seed)
+	pFlagInt(serveCmd, "value-min", "", "min value (inclusive) of a generated packet item", 0, &valueMin)
+	pFlagInt(serveCmd, "value-max", "", "max value (inclusive) of a generated packet item", packetbuilder.DefaultValueMax, &valueMax)
+	pFlagString(serveCmd, "distribution", "", "distribution of packet item values, one of: uniform,normal,exponential,zipf,poisson,drift", packetbuilder.DistributionUniform, &distribution.Kind)
+	pFlagOptionalFloat64(serveCmd, "dist-mean", "", "mean of normal, poisson and drift distributions (default middle of the value range)", &distribution.Mean)
+	pFlagOptionalFloat64(serveCmd, "dist-stddev", "", "standard deviation of normal and drift distributions (default 1/6 of the value range)", &distribution.StdDev)
+	pFlagOptionalFloat64(serveCmd, "dist-rate", "", "rate of exponential distribution (default 4/value range)", &distribution.Rate)
+	pFlagOptionalFloat64(serveCmd, "dist-zipf-s", "", "s > 1 parameter of zipf distribution (default 1.1)", &distribution.ZipfS)
+	pFlagOptionalFloat64(serveCmd, "dist-zipf-v", "", "v >= 1 parameter of zipf distribution (default 1)", &distribution.ZipfV)
+	pFlagFloat64(serveCmd, "dist-drift", "", "change of drift distribution mean per generated value", 0, &distribution.Drift)
 
 	// Bind full flag set to the configuration
 	if err := vprConfig.BindPFlags(serveCmd.PersistentFlags()); err != nil {
@@ -93,18 +243,49 @@ func init() {
 	rootCmd.AddCommand(serveCmd)
 }
 
-// run runs service
-func run(ctx context.Context) (*sync.WaitGroup, func()) {
-	log.Infof("run() - start")
-	defer log.Infof("run() - end")
+// newConfig creates configuration of the controller from flags
+func newConfig() controller.Config {
 
-	return controller.New(controller.Config{
+	poolOptions.GapPolicy = pool.GapPolicy(gapPolicy)
+	poolOptions.GapTimeout = time.Duration(gapTimeoutMillisecond) * time.Millisecond
+	supervisorOptions.BackoffMin = time.Duration(restartBackoffMinMillisecond) * time.Millisecond
+	supervisorOptions.BackoffMax = time.Duration(restartBackoffMaxMillisecond) * time.Millisecond
+	arrivalOptions.BurstInterval = time.Duration(burstIntervalMillisecond) * time.Millisecond
+	arrivalOptions.RampDuration = time.Duration(rampDurationSecond) * time.Second
+	joinOptions.Window = time.Duration(joinWindowMillisecond) * time.Millisecond
+	healthOptions.StallTimeout = time.Duration(stallTimeoutSecond) * time.Second
+	watchdogOptions.Interval = time.Duration(watchdogIntervalMillisecond) * time.Millisecond
+	return controller.Config{
+		Supervisor:                   supervisorOptions,
 		GeneratorIntervalMillisecond: generatorIntervalMillisecond,
+		GeneratorCatchUp:             generatorCatchUp,
+		Arrival:                      arrivalOptions,
+		Source:                       sourceOptions,
+		DeadLetter:                   deadLetterOptions,
 		PublisherIntervalSecond:      publisherIntervalSecond,
 		PacketSizeIn:                 packetSizeIn,
 		PacketSizeOut:                packetSizeOut,
+		PacketSizeMax:                packetSizeMax,
+		PacketSizeDistribution:       packetSizeDistribution,
+		ShortPacketPolicy:            transform.ShortPacketPolicy(shortPacketPolicy),
+		Stages:                       stages,
+		Alert:                        alert,
+		AlertCopy:                    alertCopy,
+		Join:                         joinOptions,
+		JoinIntervalMillisecond:      joinIntervalMillisecond,
+		PacketDeadline:               time.Duration(packetDeadlineMillisecond) * time.Millisecond,
+		DeadlinePolicy:               processor.DeadlinePolicy(deadlinePolicy),
+		SlowPercentile:               slowPercentile,
 		WorkersNum:                   workersNum,
-	}).Run(ctx)
+		Pool:                         poolOptions,
+		Seed:                         seed,
+		ValueMin:                     valueMin,
+		ValueMax:                     valueMax,
+		Distribution:                 distribution,
+		Tracing:                      tracingOptions,
+		Health:                       healthOptions,
+		Watchdog:                     watchdogOptions,
+	}
 }
 
 func contextInit() context.Context {
@@ -117,12 +298,14 @@ func contextInit() context.Context {
 		cancelFunc()
 		<-stopChan
 	}()
+	// Profiles are captured on demand
+	profileInit(ctx)
 
 	return ctx
 }
 
-// contextWait
-func contextWait(ctx context.Context) {
-	<-ctx.Done()
+// contextWait waits until done
+func contextWait(done <-chan struct{}) {
+	<-done
 	log.Info("Shutting down...")
 }
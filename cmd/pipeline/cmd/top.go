@@ -0,0 +1,123 @@
+// Copyright 2024 Vladislav Klimenko. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/MakeNowJust/heredoc"
+	log "github.com/sirupsen/logrus"
+	cmd "github.com/spf13/cobra"
+	vprConfig "github.com/spf13/viper"
+
+	"github.com/sunsingerus/pipeline/pkg/controller"
+	"github.com/sunsingerus/pipeline/pkg/controller/metrics"
+	"github.com/sunsingerus/pipeline/pkg/dashboard"
+	"github.com/sunsingerus/pipeline/pkg/logger"
+)
+
+var (
+	dashboardEnabled             bool
+	dashboardIntervalMillisecond int
+)
+
+var topCmd = &cmd.Command{
+	Use:   "top [OPTION(s)]",
+	Short: "Show live dashboard of the running pipeline",
+	Long: heredoc.Docf(`
+		Show refreshing terminal view of the pipeline running with '--admin-address <address>':
+		per-stage throughput, channel fill levels, worker utilisation, accumulator value and drop/error counts.
+	`),
+	Args: cmd.NoArgs,
+	RunE: func(cmd *cmd.Command, args []string) error {
+		if adminOptions.Address == "" {
+			return fmt.Errorf("address of the admin server has to be specified")
+		}
+		ctx := contextInit()
+		client := &http.Client{
+			Timeout: time.Duration(dashboardIntervalMillisecond) * time.Millisecond,
+		}
+		wg := new(sync.WaitGroup)
+		wg.Add(1)
+		go dashboardNew(func() (metrics.PipelineStats, error) {
+			return statsGet(client)
+		}).Run(ctx, wg)
+		wg.Wait()
+		return nil
+	},
+}
+
+// statsGet gets state of the pipeline from the admin server
+func statsGet(client *http.Client) (metrics.PipelineStats, error) {
+	var stats metrics.PipelineStats
+	resp, err := client.Get("http://" + adminOptions.Address + "/stats")
+	if err != nil {
+		return stats, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return stats, fmt.Errorf("%s", resp.Status)
+	}
+	err = json.NewDecoder(resp.Body).Decode(&stats)
+	return stats, err
+}
+
+// dashboardNew creates dashboard, which renders state of the pipeline into stdout
+func dashboardNew(source dashboard.Source) *dashboard.Dashboard {
+	return dashboard.New(source, os.Stdout, dashboard.Options{
+		Interval: time.Duration(dashboardIntervalMillisecond) * time.Millisecond,
+		Colors:   logger.IsTerminal(os.Stdout),
+	})
+}
+
+// dashboardInit launches dashboard of the running pipeline, in case it is enabled.
+// Returns function, which stops the dashboard
+func dashboardInit(ctx context.Context, ctrl *controller.Controller) func() {
+	if !dashboardEnabled {
+		return func() {}
+	}
+	if logger.File.Path == "" {
+		log.Warn("Dashboard is cleared on refresh, consider '--log-file' to keep logs")
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	wg := new(sync.WaitGroup)
+	wg.Add(1)
+	go dashboardNew(func() (metrics.PipelineStats, error) {
+		return ctrl.Stats(), nil
+	}).Run(ctx, wg)
+	return func() {
+		cancel()
+		wg.Wait()
+	}
+}
+
+func init() {
+	// Options (CLI+ENV)
+	pFlagString(topCmd, "admin-address", "", "address of the admin server of the running pipeline to get its state from", "", &adminOptions.Address)
+	pFlagInt(topCmd, "dashboard-interval", "", "interval in milliseconds between dashboard refreshes", int(dashboard.DefaultInterval/time.Millisecond), &dashboardIntervalMillisecond)
+
+	// Bind full flag set to the configuration
+	if err := vprConfig.BindPFlags(topCmd.PersistentFlags()); err != nil {
+		log.Fatal(err)
+	}
+
+	rootCmd.AddCommand(topCmd)
+}
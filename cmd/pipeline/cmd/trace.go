@@ -0,0 +1,128 @@
+// Copyright 2024 Vladislav Klimenko. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/MakeNowJust/heredoc"
+	log "github.com/sirupsen/logrus"
+	cmd "github.com/spf13/cobra"
+	vprConfig "github.com/spf13/viper"
+
+	"github.com/sunsingerus/pipeline/pkg/controller/tracing"
+)
+
+var (
+	traceFile  string
+	traceTop   int
+	traceWidth int
+)
+
+var traceCmd = &cmd.Command{
+	Use:   "trace [OPTION(s)]",
+	Short: "Show the slowest packet traces",
+	Long: heredoc.Docf(`
+		Show waterfall view of the slowest packets traced by the pipeline.
+		Traces are read either from the trace file written by 'serve --trace-sample <ratio> --trace-file <path>'
+		on shut down, or from the admin server of the pipeline running with '--trace-sample <ratio> --admin-address <address>'.
+	`),
+	Args: cmd.NoArgs,
+	RunE: func(cmd *cmd.Command, args []string) error {
+		traces, err := traceRead()
+		if err != nil {
+			return err
+		}
+		if len(traces) == 0 {
+			log.Info("No traces")
+			return nil
+		}
+		for _, trace := range tracing.Slowest(traces, traceTop) {
+			if err := tracing.WriteWaterfall(os.Stdout, trace, traceWidth); err != nil {
+				return err
+			}
+			fmt.Println()
+		}
+		return nil
+	},
+}
+
+// traceRead reads traces either from the admin server or from the trace file
+func traceRead() ([]tracing.Trace, error) {
+	switch {
+	case adminOptions.Address != "":
+		resp, err := http.Get("http://" + adminOptions.Address + "/traces?slowest=" + strconv.Itoa(traceTop))
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			body, _ := io.ReadAll(resp.Body)
+			return nil, fmt.Errorf("unable to get traces from %s: %s %s", adminOptions.Address, resp.Status, body)
+		}
+		return tracing.ReadOTLP(resp.Body)
+	case traceFile != "":
+		f, err := os.Open(traceFile)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return tracing.ReadOTLP(f)
+	default:
+		return nil, fmt.Errorf("either address or trace file has to be specified")
+	}
+}
+
+// traceExport writes finished traces into the trace file, in case it is specified
+func traceExport(tracer *tracing.Tracer) {
+	if (tracer == nil) || (traceFile == "") {
+		return
+	}
+	f, err := os.Create(traceFile)
+	if err != nil {
+		log.Errorf("Unable to export traces - %v", err)
+		return
+	}
+	traces := tracer.Traces()
+	if err := tracing.WriteOTLP(f, traces); err != nil {
+		_ = f.Close()
+		log.Errorf("Unable to export traces - %v", err)
+		return
+	}
+	if err := f.Close(); err != nil {
+		log.Errorf("Unable to export traces - %v", err)
+		return
+	}
+	log.Infof("Exported %d traces into %s, %d traces evicted unfinished", len(traces), traceFile, tracer.Evicted())
+}
+
+func init() {
+	// Options (CLI+ENV)
+	pFlagString(traceCmd, "trace-file", "", "trace file to read traces from", "", &traceFile)
+	pFlagString(traceCmd, "admin-address", "", "address of the admin server of the running pipeline to get traces from", "", &adminOptions.Address)
+	pFlagInt(traceCmd, "top", "", "number of the slowest traces to show", 10, &traceTop)
+	pFlagInt(traceCmd, "width", "", "width of the waterfall bars", tracing.DefaultWidth, &traceWidth)
+
+	// Bind full flag set to the configuration
+	if err := vprConfig.BindPFlags(traceCmd.PersistentFlags()); err != nil {
+		log.Fatal(err)
+	}
+
+	rootCmd.AddCommand(traceCmd)
+}
@@ -0,0 +1,98 @@
+// Copyright 2024 Vladislav Klimenko. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package admin
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ShutdownTimeout specifies how long requests in flight are waited for on shut down
+const ShutdownTimeout = 5 * time.Second
+
+// DefaultName specifies name of the server, unless specified
+const DefaultName = "Admin"
+
+// Options specifies admin server options
+type Options struct {
+	// Address specifies address server listens on, ex.: ':8080'
+	Address string
+	// Name specifies name of the server it logs as. Empty name means Admin
+	Name string
+}
+
+// Server specifies admin HTTP server of the service
+type Server struct {
+	mux *http.ServeMux
+	Options
+}
+
+// New creates new admin server
+func New(opts Options) *Server {
+	if opts.Name == "" {
+		opts.Name = DefaultName
+	}
+	return &Server{
+		mux:     http.NewServeMux(),
+		Options: opts,
+	}
+}
+
+// Handle registers handler of the pattern. Handlers are to be registered before the server runs
+func (s *Server) Handle(pattern string, handler http.Handler) {
+	if s == nil {
+		return
+	}
+	s.mux.Handle(pattern, handler)
+}
+
+// Run serves requests until context is done
+func (s *Server) Run(ctx context.Context, wg *sync.WaitGroup) {
+	defer wg.Done()
+	if s == nil {
+		return
+	}
+	log.Infof("%s server - start", s.Options.Name)
+	defer log.Infof("%s server - end", s.Options.Name)
+
+	listener, err := net.Listen("tcp", s.Options.Address)
+	if err != nil {
+		log.Errorf("%s server - unable to listen on %s: %v", s.Options.Name, s.Options.Address, err)
+		return
+	}
+	log.Infof("%s server - listening on %s", s.Options.Name, listener.Addr())
+
+	server := &http.Server{
+		Handler:           s.mux,
+		ReadHeaderTimeout: ShutdownTimeout,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), ShutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			log.Warnf("%s server - shut down: %v", s.Options.Name, err)
+		}
+	}()
+	if err := server.Serve(listener); !errors.Is(err, http.ErrServerClosed) {
+		log.Errorf("%s server - %v", s.Options.Name, err)
+	}
+}
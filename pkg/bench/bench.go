@@ -0,0 +1,241 @@
+// Copyright 2024 Vladislav Klimenko. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bench
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"runtime"
+	"runtime/metrics"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/sunsingerus/pipeline/pkg/controller"
+	"github.com/sunsingerus/pipeline/pkg/controller/accum"
+	"github.com/sunsingerus/pipeline/pkg/controller/tracing"
+)
+
+// Defaults of the benchmark
+const (
+	DefaultDuration      = 5 * time.Second
+	DefaultLatencySample = 0.01
+	// LatencyTraces specifies max number of the latest traces latency percentiles are calculated over
+	LatencyTraces = 100000
+)
+
+// pollInterval specifies how often number of packets is checked against the packet count
+const pollInterval = 10 * time.Millisecond
+
+// Options specifies benchmark options
+type Options struct {
+	// Workers specifies numbers of workers swept
+	Workers []int
+	// PacketSizes specifies sizes of the generated packets swept
+	PacketSizes []int
+	// Duration specifies max duration of each run. Zero means default duration
+	Duration time.Duration
+	// Packets specifies number of packets each run ends after. Zero means each run lasts for the duration
+	Packets int64
+	// LatencySample specifies ratio of packets traced for latency. Zero means default ratio
+	LatencySample float64
+}
+
+// Result specifies result of the benchmark run
+type Result struct {
+	Workers    int `json:"workers"`
+	PacketSize int `json:"packetSize"`
+	// Packets specifies number of packets, which have reached the end of the pipeline within Duration
+	Packets  int64         `json:"packets"`
+	Duration time.Duration `json:"duration"`
+	// Throughput specifies packets per second
+	Throughput float64 `json:"throughput"`
+	// P50 and P99 specify end-to-end latency percentiles of the traced packets, Traced of them
+	P50    time.Duration `json:"p50"`
+	P99    time.Duration `json:"p99"`
+	Traced int           `json:"traced"`
+	// AllocsPerPacket and BytesPerPacket specify heap allocations of the whole process per packet
+	AllocsPerPacket float64 `json:"allocsPerPacket"`
+	BytesPerPacket  float64 `json:"bytesPerPacket"`
+	// CPU specifies average number of CPU cores the process used, ex.: 1.5 means 150%
+	CPU float64 `json:"cpu"`
+}
+
+// Run runs the pipeline of the configuration for each combination of workers and packet sizes
+// with the generator delivering packets as fast as possible
+func Run(ctx context.Context, conf controller.Config, opts Options) ([]Result, error) {
+	if opts.Duration <= 0 {
+		opts.Duration = DefaultDuration
+	}
+	if opts.LatencySample <= 0 {
+		opts.LatencySample = DefaultLatencySample
+	}
+	if (len(opts.Workers) == 0) || (len(opts.PacketSizes) == 0) {
+		return nil, fmt.Errorf("no workers or packet sizes to run with")
+	}
+
+	var results []Result
+	for _, size := range opts.PacketSizes {
+		for _, workers := range opts.Workers {
+			if ctx.Err() != nil {
+				return results, ctx.Err()
+			}
+			log.Infof("Bench - running with %d workers and packet size %d", workers, size)
+			conf.WorkersNum = workers
+			conf.PacketSizeIn = size
+			conf.GeneratorNoDelay = true
+			conf.Tracing = tracing.Options{
+				Sample: opts.LatencySample,
+				Size:   LatencyTraces,
+			}
+			result, err := runOne(ctx, conf, opts)
+			if err != nil {
+				return results, err
+			}
+			results = append(results, result)
+		}
+	}
+	return results, nil
+}
+
+// runOne runs the pipeline once and measures it
+func runOne(ctx context.Context, conf controller.Config, opts Options) (Result, error) {
+	ctx, cancel := context.WithTimeout(ctx, opts.Duration)
+	defer cancel()
+
+	ctrl := controller.New(conf)
+	wg, stop, err := ctrl.Run(ctx)
+	if err != nil {
+		return Result{}, err
+	}
+	defer func() {
+		cancel()
+		wg.Wait()
+		stop()
+	}()
+
+	// Packets may have been accumulated since the pipeline started, so they are not counted
+	initial := accumulated(ctrl)
+	start := time.Now()
+	startMem, startCPU := readMem(), readCPU()
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	var packets int64
+wait:
+	for (opts.Packets <= 0) || (packets < opts.Packets) {
+		select {
+		case <-ctx.Done():
+			break wait
+		case <-ticker.C:
+			packets = accumulated(ctrl) - initial
+		}
+	}
+	packets = accumulated(ctrl) - initial
+	elapsed := time.Since(start)
+	endMem, endCPU := readMem(), readCPU()
+
+	result := Result{
+		Workers:    conf.WorkersNum,
+		PacketSize: conf.PacketSizeIn,
+		Packets:    packets,
+		Duration:   elapsed,
+		Throughput: float64(packets) / elapsed.Seconds(),
+		CPU:        (endCPU - startCPU) / elapsed.Seconds(),
+	}
+	if packets > 0 {
+		result.AllocsPerPacket = float64(endMem.Mallocs-startMem.Mallocs) / float64(packets)
+		result.BytesPerPacket = float64(endMem.TotalAlloc-startMem.TotalAlloc) / float64(packets)
+	}
+	result.P50, result.P99, result.Traced = latency(ctrl.Tracer().Traces())
+	return result, nil
+}
+
+// accumulated returns number of packets received by the accumulator
+func accumulated(ctrl *controller.Controller) int64 {
+	for _, stage := range ctrl.Stats().Stages {
+		if stage.Name == accum.Component {
+			return stage.Counters["received"]
+		}
+	}
+	return 0
+}
+
+// latency returns median and 99th percentile of durations of the traces of packets, which have reached the end of the pipeline
+func latency(traces []tracing.Trace) (time.Duration, time.Duration, int) {
+	var durations []time.Duration
+	for i := range traces {
+		if traces[i].Status == tracing.StatusOK {
+			durations = append(durations, traces[i].Duration())
+		}
+	}
+	if len(durations) == 0 {
+		return 0, 0, 0
+	}
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	return percentile(durations, 0.5), percentile(durations, 0.99), len(durations)
+}
+
+// percentile returns percentile of the sorted durations by the nearest rank
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(p*float64(len(sorted))+0.5) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= len(sorted) {
+		rank = len(sorted) - 1
+	}
+	return sorted[rank]
+}
+
+func readMem() runtime.MemStats {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	return mem
+}
+
+// readCPU returns CPU time in seconds the process has used so far, as estimated by the runtime
+func readCPU() float64 {
+	samples := []metrics.Sample{
+		{Name: "/cpu/classes/total:cpu-seconds"},
+		{Name: "/cpu/classes/idle:cpu-seconds"},
+	}
+	metrics.Read(samples)
+	if (samples[0].Value.Kind() != metrics.KindFloat64) || (samples[1].Value.Kind() != metrics.KindFloat64) {
+		return 0
+	}
+	return samples[0].Value.Float64() - samples[1].Value.Float64()
+}
+
+// WriteTable writes results as a table
+func WriteTable(w io.Writer, results []Result) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	_, _ = fmt.Fprintln(tw, "WORKERS\tSIZE\tPACKETS\tPACKETS/S\tP50\tP99\tALLOCS/PACKET\tBYTES/PACKET\tCPU\t")
+	for _, r := range results {
+		_, _ = fmt.Fprintf(tw, "%d\t%d\t%d\t%.0f\t%s\t%s\t%.1f\t%.0f\t%.0f%%\t\n",
+			r.Workers, r.PacketSize, r.Packets, r.Throughput, r.P50.Round(time.Microsecond), r.P99.Round(time.Microsecond), r.AllocsPerPacket, r.BytesPerPacket, r.CPU*100)
+	}
+	return tw.Flush()
+}
+
+// WriteJSON writes results as JSON array
+func WriteJSON(w io.Writer, results []Result) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(results)
+}
@@ -0,0 +1,95 @@
+// Copyright 2024 Vladislav Klimenko. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bench
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/sunsingerus/pipeline/pkg/controller"
+	"github.com/sunsingerus/pipeline/pkg/controller/dlq"
+	"github.com/sunsingerus/pipeline/pkg/controller/tracing"
+)
+
+func TestLatency(t *testing.T) {
+	start := time.Unix(100, 0)
+	var traces []tracing.Trace
+	for i := 1; i <= 100; i++ {
+		traces = append(traces, tracing.Trace{Start: start, End: start.Add(time.Duration(i) * time.Millisecond), Status: tracing.StatusOK})
+	}
+	traces = append(traces, tracing.Trace{Start: start, End: start.Add(time.Hour), Status: tracing.StatusEvicted})
+	p50, p99, traced := latency(traces)
+	require.Equal(t, 50*time.Millisecond, p50)
+	require.Equal(t, 99*time.Millisecond, p99)
+	require.Equal(t, 100, traced, "Check packets, which have not reached the end of the pipeline, are skipped")
+
+	p50, p99, traced = latency(nil)
+	require.Zero(t, p50)
+	require.Zero(t, p99)
+	require.Zero(t, traced)
+}
+
+func TestRun(t *testing.T) {
+	_, err := Run(context.Background(), controller.Config{}, Options{})
+	require.Error(t, err)
+
+	conf := controller.Config{
+		DeadLetter:              dlq.Options{Kind: dlq.KindMemory},
+		PublisherIntervalSecond: 1,
+		PacketSizeOut:           3,
+		ValueMax:                9,
+	}
+	results, err := Run(context.Background(), conf, Options{
+		Workers:       []int{1, 2},
+		PacketSizes:   []int{10},
+		Duration:      5 * time.Second,
+		Packets:       100,
+		LatencySample: 1,
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	for i, result := range results {
+		require.Equal(t, i+1, result.Workers)
+		require.Equal(t, 10, result.PacketSize)
+		require.GreaterOrEqual(t, result.Packets, int64(100))
+		require.Less(t, result.Duration, 5*time.Second, "Check run ends after the packet count")
+		require.Greater(t, result.Throughput, float64(0))
+		require.Greater(t, result.Traced, 0)
+		require.LessOrEqual(t, result.P50, result.P99)
+		require.Greater(t, result.AllocsPerPacket, float64(0))
+	}
+}
+
+func TestWrite(t *testing.T) {
+	results := []Result{{Workers: 2, PacketSize: 10, Packets: 1000, Throughput: 500, P50: 1500 * time.Nanosecond, P99: time.Millisecond, AllocsPerPacket: 3.25, BytesPerPacket: 100, CPU: 1.5}}
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, WriteTable(buf, results))
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 2)
+	require.Equal(t, []string{"2", "10", "1000", "500", "2µs", "1ms", "3.2", "100", "150%"}, strings.Fields(lines[1]))
+
+	buf.Reset()
+	require.NoError(t, WriteJSON(buf, results))
+	var decoded []Result
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &decoded))
+	require.Equal(t, results, decoded)
+}
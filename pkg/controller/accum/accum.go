@@ -18,10 +18,14 @@ import (
 	"context"
 	"fmt"
 	"sync"
+	"time"
 
-	log "github.com/sirupsen/logrus"
-
+	"github.com/sunsingerus/pipeline/pkg/controller/health"
+	"github.com/sunsingerus/pipeline/pkg/controller/metrics"
 	"github.com/sunsingerus/pipeline/pkg/controller/packet"
+	"github.com/sunsingerus/pipeline/pkg/controller/stage"
+	"github.com/sunsingerus/pipeline/pkg/controller/tracing"
+	"github.com/sunsingerus/pipeline/pkg/logger"
 )
 
 type inPacket interface {
@@ -34,15 +38,40 @@ type inPacket interface {
 type Accum struct {
 	// in specifies chan where accum reads packets
 	in    chan packet.Packet
+	stats *metrics.Stage
 	accum int
 	mux   sync.RWMutex
+	log   *logger.Entry
+	Options
+}
+
+// Options specifies accumulator options
+type Options struct {
+	// Tracer specifies tracer of the accumulated packets, accumulation finishes their traces. Nil means no tracing
+	Tracer *tracing.Tracer
+	// Health specifies monitor accumulator reports heartbeats to as accum stage. Nil means no heartbeats
+	Health *health.Monitor
 }
 
+// Component specifies component accumulator logs as
+const Component = "accum"
+
 // New creates new accumulator
-func New(in chan packet.Packet) *Accum {
+func New(in chan packet.Packet, opts Options) *Accum {
 	return &Accum{
-		in: in,
+		in:      in,
+		stats:   metrics.NewStage(Component),
+		log:     logger.Component(Component),
+		Options: opts,
+	}
+}
+
+// Stats returns counters of the accumulator
+func (a *Accum) Stats() *metrics.Stage {
+	if a == nil {
+		return nil
 	}
+	return a.stats
 }
 
 func (a *Accum) Get() int {
@@ -82,17 +111,30 @@ func (a *Accum) Run(ctx context.Context, wg *sync.WaitGroup) {
 	if a == nil {
 		return
 	}
-	log.Infof("Accum start")
-	defer log.Infof("Accum end")
-
-	for {
-		select {
-		case <-ctx.Done():
-			log.Infof("Accum done")
-			return
-		case pack := <-a.in:
-			log.Infof("Accum got packet: %s", pack)
+	a.log.Info("start")
+	defer a.log.Info("end")
+	a.Options.Health.Start(Component)
+	defer a.Options.Health.Stop(Component)
+
+	sink := stage.NewSink[packet.Packet](
+		"Accum",
+		func(pack packet.Packet) error {
+			received := time.Now()
+			a.Options.Health.Begin(Component)
+			defer a.Options.Health.End(Component)
+			a.Options.Tracer.Wait(pack.ID(), tracing.SpanQueue+" "+Component, received)
 			a.processPacket(pack)
-		}
-	}
+			a.Options.Tracer.Span(pack.ID(), tracing.SpanAccumulate, received, time.Now())
+			a.Options.Tracer.Finish(pack.ID(), tracing.StatusOK)
+			return nil
+		},
+		a.stats,
+		a.in,
+		stage.Options[packet.Packet]{
+			Component: Component,
+		},
+	)
+	_wg := new(sync.WaitGroup)
+	_wg.Add(1)
+	sink.Run(ctx, _wg)
 }
@@ -50,7 +50,7 @@ func TestAccum(t *testing.T) {
 		},
 	}
 	ch := make(chan packet.Packet)
-	accum := New(ch)
+	accum := New(ch, Options{})
 
 	wg := &sync.WaitGroup{}
 	ctx, cancel := context.WithCancel(context.Background())
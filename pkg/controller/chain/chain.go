@@ -0,0 +1,406 @@
+// Copyright 2024 Vladislav Klimenko. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chain
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/sunsingerus/pipeline/pkg/controller/dlq"
+	"github.com/sunsingerus/pipeline/pkg/controller/metrics"
+	"github.com/sunsingerus/pipeline/pkg/controller/packet"
+	"github.com/sunsingerus/pipeline/pkg/controller/pool"
+	"github.com/sunsingerus/pipeline/pkg/controller/processor"
+	"github.com/sunsingerus/pipeline/pkg/controller/supervisor"
+	"github.com/sunsingerus/pipeline/pkg/controller/transform"
+)
+
+// Stage specifies processing stage of the chain
+type Stage struct {
+	// Name specifies unique name of the stage. Empty name means name of the transform
+	Name string
+	// Transform specifies kind of the transform stage applies to packets
+	Transform string
+	// Params specifies transform parameters
+	Params transform.Params
+	// Factory specifies factory of the transforms. Transform kind and parameters are ignored in case it is specified
+	Factory transform.Factory
+	// Workers specifies number of stage processors. Zero means chain default
+	Workers int
+	// Capacity specifies capacity of the stage input channel. Zero means unbuffered channel
+	Capacity int
+	// Overflow specifies how packets are delivered in case the next stage is busy. Empty policy means chain default
+	Overflow processor.OverflowPolicy
+	// Ordered specifies whether stage delivers packets in the order of their ids
+	Ordered bool
+	// Sharded specifies whether packets with the same key are always processed by the same stage processor
+	Sharded bool
+	// ShardKey specifies name of the key packets are sharded by. Empty means chain default
+	ShardKey string
+}
+
+// Stage parameters, which are not passed to the transform
+const (
+	paramName     = "name"
+	paramWorkers  = "workers"
+	paramCapacity = "capacity"
+	paramOverflow = "overflow"
+	paramOrdered  = "ordered"
+	paramSharded  = "sharded"
+	paramKey      = "key"
+)
+
+// Parse parses chain specification, which lists stages separated by |, each stage optionally followed by
+// comma-separated parameters in parentheses: "filter(min=5)|dedupe|topn(n=3,workers=4,capacity=10)"
+func Parse(spec string) ([]Stage, error) {
+	var stages []Stage
+	for _, item := range strings.Split(spec, "|") {
+		stage, err := parseStage(strings.TrimSpace(item))
+		if err != nil {
+			return nil, err
+		}
+		stages = append(stages, stage)
+	}
+	return stages, nil
+}
+
+func parseStage(spec string) (Stage, error) {
+	kind, args, hasArgs := strings.Cut(spec, "(")
+	stage := Stage{
+		Transform: strings.TrimSpace(kind),
+		Params:    transform.Params{},
+	}
+	if stage.Transform == "" {
+		return Stage{}, fmt.Errorf("stage with no transform in %q", spec)
+	}
+	if hasArgs {
+		if !strings.HasSuffix(args, ")") {
+			return Stage{}, fmt.Errorf("unterminated parameters of stage %q", spec)
+		}
+		args = strings.TrimSpace(strings.TrimSuffix(args, ")"))
+	}
+	if args == "" {
+		return stage, nil
+	}
+
+	for _, arg := range strings.Split(args, ",") {
+		name, value, ok := strings.Cut(arg, "=")
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+		if !ok || (name == "") {
+			return Stage{}, fmt.Errorf("invalid parameter %q of stage %q", arg, spec)
+		}
+		var err error
+		switch name {
+		case paramName:
+			stage.Name = value
+		case paramWorkers:
+			stage.Workers, err = strconv.Atoi(value)
+		case paramCapacity:
+			stage.Capacity, err = strconv.Atoi(value)
+		case paramOverflow:
+			stage.Overflow = processor.OverflowPolicy(value)
+		case paramOrdered:
+			stage.Ordered, err = strconv.ParseBool(value)
+		case paramSharded:
+			stage.Sharded, err = strconv.ParseBool(value)
+		case paramKey:
+			stage.ShardKey = value
+		default:
+			stage.Params[name] = value
+		}
+		if err != nil {
+			return Stage{}, fmt.Errorf("invalid %s %q of stage %q", name, value, spec)
+		}
+	}
+	return stage, nil
+}
+
+// Pipes specifies where the chain delivers packets to
+type Pipes struct {
+	Out chan packet.Packet
+	// DeadLetter receives packets failed to be processed by any of the stages
+	DeadLetter dlq.Sink
+}
+
+// Options specifies defaults of the chain stages
+type Options struct {
+	// Workers specifies number of processors of each stage, unless specified by the stage
+	Workers int
+	// Processor specifies options of all stage processors
+	Processor processor.Options
+	// Pool specifies options of all stage pools. Ordering is only needed at the last stage and is applied there
+	Pool pool.Options
+}
+
+// DefaultWorkers specifies number of stage processors, unless specified
+const DefaultWorkers = 1
+
+// stage specifies running stage of the chain
+type stage struct {
+	Stage
+	in      chan packet.Packet
+	pool    *pool.Pool
+	metrics *metrics.Stage
+	// defaultWorkers specifies whether number of stage processors is the chain default
+	defaultWorkers bool
+}
+
+// Chain specifies ordered chain of processing stages, each running its own pool of processors
+type Chain struct {
+	stages []*stage
+	Pipes
+}
+
+// New creates new chain of stages. Stages report to the metrics registry, which can be nil
+func New(
+	stages []Stage,
+	outPacketConstructor processor.OutPacketConstructor,
+	supervisor *supervisor.Supervisor,
+	registry *metrics.Registry,
+	pipes Pipes,
+	opts Options,
+) (*Chain, error) {
+	if len(stages) == 0 {
+		return nil, fmt.Errorf("no stages")
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = DefaultWorkers
+	}
+	if registry == nil {
+		registry = metrics.NewRegistry()
+	}
+
+	chain := &Chain{
+		Pipes: pipes,
+	}
+	names := make(map[string]bool)
+	factories := make([]transform.Factory, len(stages))
+	for i, conf := range stages {
+		if conf.Name == "" {
+			conf.Name = conf.Transform
+		}
+		if conf.Name == "" {
+			return nil, fmt.Errorf("stage %d has no name", i)
+		}
+		if names[conf.Name] {
+			return nil, fmt.Errorf("duplicate stage name %q, specify stage name explicitly", conf.Name)
+		}
+		names[conf.Name] = true
+		defaultWorkers := conf.Workers <= 0
+		if defaultWorkers {
+			conf.Workers = opts.Workers
+		}
+		if conf.Capacity < 0 {
+			return nil, fmt.Errorf("invalid capacity %d of stage %q", conf.Capacity, conf.Name)
+		}
+		if conf.Overflow == "" {
+			conf.Overflow = opts.Processor.Overflow
+		}
+		switch conf.Overflow {
+		case "", processor.OverflowBlock, processor.OverflowDrop:
+		default:
+			return nil, fmt.Errorf("unknown overflow policy %q of stage %q", conf.Overflow, conf.Name)
+		}
+		if (i == len(stages)-1) && opts.Pool.Ordered {
+			conf.Ordered = true
+		}
+		if opts.Pool.Sharded {
+			conf.Sharded = true
+		}
+		if conf.ShardKey == "" {
+			conf.ShardKey = opts.Pool.ShardKey
+		}
+
+		var err error
+		_metrics := registry.Stage(conf.Name)
+		factories[i] = conf.Factory
+		if factories[i] == nil {
+			factories[i], err = transform.New(conf.Transform, conf.Params, _metrics)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("stage %q: %v", conf.Name, err)
+		}
+		if conf.Ordered && (i > 0) && (opts.Pool.GapPolicy != pool.GapSkip) {
+			log.Warnf("Chain - ordered stage %q waits for packets filtered out or rejected by previous stages, consider skip gap policy", conf.Name)
+		}
+
+		chain.stages = append(chain.stages, &stage{
+			Stage:          conf,
+			in:             make(chan packet.Packet, conf.Capacity),
+			metrics:        _metrics,
+			defaultWorkers: defaultWorkers,
+		})
+	}
+	// Stages are connected once all their input channels are made
+	for i, stage := range chain.stages {
+		var err error
+		if stage.pool, err = chain.buildPool(stage, factories[i], outPacketConstructor, supervisor, opts); err != nil {
+			return nil, fmt.Errorf("stage %q: %v", stage.Name, err)
+		}
+	}
+	return chain, nil
+}
+
+// buildPool builds pool of the stage processors
+func (c *Chain) buildPool(
+	stage *stage,
+	factory transform.Factory,
+	outPacketConstructor processor.OutPacketConstructor,
+	supervisor *supervisor.Supervisor,
+	opts Options,
+) (*pool.Pool, error) {
+	poolOptions := opts.Pool
+	poolOptions.Name = stage.Name
+	poolOptions.Ordered = stage.Ordered
+	poolOptions.Sharded = stage.Sharded
+	poolOptions.ShardKey = stage.ShardKey
+
+	processorOptions := opts.Processor
+	processorOptions.Stage = stage.Name
+	processorOptions.Overflow = stage.Overflow
+
+	return pool.New(
+		stage.Workers,
+		func(id int, pipes pool.Pipes) pool.Processor {
+			return processor.New(
+				id,
+				factory(),
+				outPacketConstructor,
+				stage.metrics,
+				processor.Pipes{
+					In:          pipes.In,
+					Out:         pipes.Out,
+					DeadLetter:  c.Pipes.DeadLetter,
+					Quit:        pipes.Quit,
+					Undelivered: pipes.Undelivered,
+				},
+				processorOptions,
+			)
+		},
+		supervisor,
+		pool.Pipes{
+			In:  stage.in,
+			Out: c.next(stage),
+		},
+		poolOptions,
+	)
+}
+
+// next returns channel the stage delivers packets to
+func (c *Chain) next(stage *stage) chan packet.Packet {
+	for i := range c.stages {
+		if (c.stages[i] == stage) && (i+1 < len(c.stages)) {
+			return c.stages[i+1].in
+		}
+	}
+	return c.Pipes.Out
+}
+
+// In returns channel the chain reads packets from
+func (c *Chain) In() chan packet.Packet {
+	if c == nil {
+		return nil
+	}
+	return c.stages[0].in
+}
+
+// Launch launches pools of all stages
+func (c *Chain) Launch(ctx context.Context, wg *sync.WaitGroup) {
+	if c == nil {
+		return
+	}
+	for _, stage := range c.stages {
+		log.Infof("Chain - launching stage %s: transform=%s workers=%d capacity=%d", stage.Name, stage.Transform, stage.Workers, stage.Capacity)
+		stage.pool.Launch(ctx, wg)
+	}
+}
+
+// Resize changes number of processors of the running stages, which use the chain default number of processors.
+// All stages are checked beforehand, so none of them is resized in case any of them can not be
+func (c *Chain) Resize(workers int) error {
+	if c == nil {
+		return fmt.Errorf("no chain")
+	}
+	if workers <= 0 {
+		return fmt.Errorf("invalid number of workers %d", workers)
+	}
+	for _, stage := range c.stages {
+		if !stage.defaultWorkers {
+			continue
+		}
+		if err := stage.pool.CheckResize(workers); err != nil {
+			return fmt.Errorf("stage %q: %v", stage.Name, err)
+		}
+	}
+	for _, stage := range c.stages {
+		if !stage.defaultWorkers {
+			log.Infof("Chain - stage %s keeps %d workers specified explicitly", stage.Name, stage.Workers)
+			continue
+		}
+		if err := stage.pool.Resize(workers); err != nil {
+			return fmt.Errorf("stage %q: %v", stage.Name, err)
+		}
+	}
+	return nil
+}
+
+// Names returns names of all stages in order of the chain
+func (c *Chain) Names() []string {
+	if c == nil {
+		return nil
+	}
+	var names []string
+	for _, stage := range c.stages {
+		names = append(names, stage.Name)
+	}
+	return names
+}
+
+// Stats returns state of all stages in order of the chain
+func (c *Chain) Stats() []metrics.StageStats {
+	if c == nil {
+		return nil
+	}
+	var stats []metrics.StageStats
+	for _, stage := range c.stages {
+		stats = append(stats, metrics.StageStats{
+			Name:     stage.Name,
+			Counters: stage.metrics.Snapshot(),
+			Queue:    len(stage.in),
+			Capacity: cap(stage.in),
+			Workers:  stage.pool.Size(),
+			Busy:     int(stage.metrics.Busy.Load()),
+		})
+	}
+	return stats
+}
+
+// Close closes channels of all stages. Chain must not be running
+func (c *Chain) Close() {
+	if c == nil {
+		return
+	}
+	for _, stage := range c.stages {
+		if stage.Ordered {
+			log.Infof("Stage %s pool stats: %s", stage.Name, &stage.pool.Stats)
+		}
+		close(stage.in)
+	}
+}
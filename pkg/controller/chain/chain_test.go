@@ -0,0 +1,169 @@
+// Copyright 2024 Vladislav Klimenko. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chain
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/sunsingerus/pipeline/pkg/controller/dlq"
+	"github.com/sunsingerus/pipeline/pkg/controller/metrics"
+	"github.com/sunsingerus/pipeline/pkg/controller/packet"
+	"github.com/sunsingerus/pipeline/pkg/controller/processor"
+	"github.com/sunsingerus/pipeline/pkg/controller/transform"
+	model "github.com/sunsingerus/pipeline/pkg/model/packet"
+)
+
+func TestParse(t *testing.T) {
+	stages, err := Parse("filter(min=5) | dedupe | topn(n=3, workers=4, capacity=10, short=pass) | scale(factor=2,name=double,overflow=drop,ordered=true,sharded=true,key=sum)")
+	require.NoError(t, err)
+	require.Equal(t, []Stage{
+		{
+			Transform: "filter",
+			Params:    transform.Params{"min": "5"},
+		},
+		{
+			Transform: "dedupe",
+			Params:    transform.Params{},
+		},
+		{
+			Transform: "topn",
+			Params:    transform.Params{"n": "3", "short": "pass"},
+			Workers:   4,
+			Capacity:  10,
+		},
+		{
+			Name:      "double",
+			Transform: "scale",
+			Params:    transform.Params{"factor": "2"},
+			Overflow:  processor.OverflowDrop,
+			Ordered:   true,
+			Sharded:   true,
+			ShardKey:  "sum",
+		},
+	}, stages)
+
+	for _, spec := range []string{"", "topn|", "topn(n=3", "topn(n)", "topn(workers=x)", "topn(ordered=maybe)"} {
+		_, err := Parse(spec)
+		require.Error(t, err, "Check invalid spec: %q", spec)
+	}
+}
+
+func TestNewInvalid(t *testing.T) {
+	for _, spec := range []string{"sort", "topn|topn", "topn(overflow=spill)", "topn(capacity=-1)", "topn(n=-1)"} {
+		stages, err := Parse(spec)
+		require.NoError(t, err)
+		_, err = New(stages, newOutPacket, nil, nil, Pipes{}, Options{})
+		require.Error(t, err, "Check invalid chain: %q", spec)
+	}
+}
+
+func newOutPacket(slice []int) packet.Packet {
+	return model.New(slice)
+}
+
+func TestChain(t *testing.T) {
+	stages, err := Parse("filter(min=5)|dedupe(capacity=2)|topn(n=2,short=reject,workers=2)|scale(factor=10,capacity=5)")
+	require.NoError(t, err)
+
+	out := make(chan packet.Packet)
+	ring := dlq.NewRing(10, "")
+	registry := metrics.NewRegistry()
+	chain, err := New(stages, newOutPacket, nil, registry, Pipes{Out: out, DeadLetter: ring}, Options{})
+	require.NoError(t, err)
+	require.Equal(t, 5, cap(chain.stages[3].in))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := &sync.WaitGroup{}
+	chain.Launch(ctx, wg)
+
+	// In-memory source
+	inputs := [][]int{
+		{1, 9, 6, 9, 7},
+		{1, 2, 3},
+		{5, 5, 1},
+		{8, 6},
+	}
+	go func() {
+		for i, values := range inputs {
+			pack := model.New(values)
+			pack.SetID(uint64(i + 1))
+			chain.In() <- pack
+		}
+	}()
+
+	// In-memory sink
+	results := make(map[uint64]string)
+	for len(results) < 2 {
+		pack := <-out
+		results[pack.ID()] = pack.String()
+	}
+	require.Equal(t, map[uint64]string{1: "[70,90]", 4: "[60,80]"}, results)
+
+	require.Eventually(t, func() bool { return len(ring.List()) == 1 }, time.Second, time.Millisecond)
+	cancel()
+	wg.Wait()
+	stats := chain.Stats()
+	require.Len(t, stats, 4)
+	require.Equal(t, "topn", stats[2].Name)
+	require.Equal(t, 2, stats[2].Workers)
+	require.Equal(t, 0, stats[2].Busy, "Check no workers are busy once the chain stopped")
+	require.Equal(t, 5, stats[3].Capacity)
+	require.Equal(t, int64(4), stats[0].Counters["received"])
+	chain.Close()
+
+	entries := ring.List()
+	require.Equal(t, "topn", entries[0].Stage)
+	require.Equal(t, "5", entries[0].Line())
+
+	var names []string
+	for _, stage := range registry.Stages() {
+		names = append(names, stage.Name())
+	}
+	require.Equal(t, []string{"filter", "dedupe", "topn", "scale"}, names)
+	filter := registry.Stage("filter")
+	require.Equal(t, int64(4), filter.Received.Load())
+	require.Equal(t, int64(1), filter.Filtered.Load())
+	require.Equal(t, int64(3), filter.Delivered.Load())
+	require.Equal(t, int64(1), registry.Stage("topn").Rejected.Load())
+	require.Equal(t, int64(2), registry.Stage("scale").Delivered.Load())
+}
+
+func TestResize(t *testing.T) {
+	stages, err := Parse("filter(min=5)|topn(n=2,workers=3)|scale(factor=10)")
+	require.NoError(t, err)
+	chain, err := New(stages, newOutPacket, nil, nil, Pipes{Out: make(chan packet.Packet)}, Options{Workers: 2})
+	require.NoError(t, err)
+
+	// The last stage is not running, so none of the stages is resized
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := &sync.WaitGroup{}
+	chain.stages[0].pool.Launch(ctx, wg)
+	chain.stages[1].pool.Launch(ctx, wg)
+	require.Error(t, chain.Resize(4))
+	require.Equal(t, 2, chain.stages[0].pool.Size(), "Check stage is not resized once resize fails")
+
+	chain.stages[2].pool.Launch(ctx, wg)
+	require.NoError(t, chain.Resize(4))
+	require.Equal(t, 4, chain.stages[0].pool.Size())
+	require.Equal(t, 3, chain.stages[1].pool.Size(), "Check explicit number of workers is kept")
+	require.Equal(t, 4, chain.stages[2].pool.Size())
+	cancel()
+	wg.Wait()
+}
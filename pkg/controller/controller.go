@@ -16,101 +16,446 @@ package controller
 
 import (
 	"context"
+	"fmt"
 	log "github.com/sirupsen/logrus"
+	"strconv"
 	"sync"
 	"time"
 
 	"github.com/sunsingerus/pipeline/pkg/controller/accum"
+	"github.com/sunsingerus/pipeline/pkg/controller/chain"
+	"github.com/sunsingerus/pipeline/pkg/controller/dlq"
 	"github.com/sunsingerus/pipeline/pkg/controller/generator"
+	"github.com/sunsingerus/pipeline/pkg/controller/generator/arrival"
 	"github.com/sunsingerus/pipeline/pkg/controller/generator/packet_builder"
+	"github.com/sunsingerus/pipeline/pkg/controller/generator/source"
+	"github.com/sunsingerus/pipeline/pkg/controller/health"
+	"github.com/sunsingerus/pipeline/pkg/controller/join"
+	"github.com/sunsingerus/pipeline/pkg/controller/metrics"
 	"github.com/sunsingerus/pipeline/pkg/controller/packet"
 	"github.com/sunsingerus/pipeline/pkg/controller/pool"
 	"github.com/sunsingerus/pipeline/pkg/controller/processor"
 	"github.com/sunsingerus/pipeline/pkg/controller/publisher"
+	"github.com/sunsingerus/pipeline/pkg/controller/supervisor"
+	"github.com/sunsingerus/pipeline/pkg/controller/topology"
+	"github.com/sunsingerus/pipeline/pkg/controller/tracing"
+	"github.com/sunsingerus/pipeline/pkg/controller/transform"
+	"github.com/sunsingerus/pipeline/pkg/controller/watchdog"
 	mpacket "github.com/sunsingerus/pipeline/pkg/model/packet"
 )
 
 type Config struct {
 	GeneratorIntervalMillisecond int
+	GeneratorCatchUp             bool
+	GeneratorNoDelay             bool
+	Arrival                      arrival.Options
+	Source                       source.Options
+	DeadLetter                   dlq.Options
 	PublisherIntervalSecond      int
 	PacketSizeIn                 int
 	PacketSizeOut                int
+	PacketSizeMax                int
+	PacketSizeDistribution       packetbuilder.DistributionOptions
+	ShortPacketPolicy            transform.ShortPacketPolicy
+	Stages                       string
+	Alert                        string
+	AlertCopy                    bool
+	Join                         join.Options
+	JoinIntervalMillisecond      int
+	PacketDeadline               time.Duration
+	DeadlinePolicy               processor.DeadlinePolicy
+	SlowPercentile               float64
 	WorkersNum                   int
+	Pool                         pool.Options
+	Seed                         int64
+	ValueMin                     int
+	ValueMax                     int
+	Distribution                 packetbuilder.DistributionOptions
+	Supervisor                   supervisor.Options
+	Tracing                      tracing.Options
+	Health                       health.Options
+	// Watchdog specifies options of the stall detector. Zero interval means no stall detector
+	Watchdog watchdog.Options
 }
 
 type Controller struct {
 	generatorInterval   time.Duration
+	generatorCatchUp    bool
+	generatorNoDelay    bool
+	arrival             arrival.Options
+	sourceOptions       source.Options
+	source              *source.Source
+	deadLetterOptions   dlq.Options
+	deadLetter          *dlq.Switch
 	publisherInterval   time.Duration
 	generatorPacketSize int
 	processorPacketSize int
+	generatorSizeMax    int
+	generatorSizeDist   packetbuilder.DistributionOptions
+	shortPacketPolicy   transform.ShortPacketPolicy
+	stages              string
+	alert               string
+	alertCopy           bool
+	joinOptions         join.Options
+	joinInterval        time.Duration
+	packetDeadline      time.Duration
+	deadlinePolicy      processor.DeadlinePolicy
+	slowPercentile      float64
 	workersNum          int
+	poolOptions         pool.Options
+	seed                int64
+	valueMin            int
+	valueMax            int
+	distribution        packetbuilder.DistributionOptions
+	metrics             *metrics.Registry
+	supervisorOptions   supervisor.Options
+	supervisor          *supervisor.Supervisor
+	tracingOptions      tracing.Options
+	tracer              *tracing.Tracer
+	healthOptions       health.Options
+	health              *health.Monitor
+	watchdogOptions     watchdog.Options
+	// config specifies configuration the controller currently runs with
+	config Config
+	// Components, which can be reconfigured while running
+	generator     *generator.Generator
+	joinGenerator *generator.Generator
+	accum         *accum.Accum
+	publisher     *publisher.Publisher
+	chain         *chain.Chain
+	reloadMux     sync.Mutex
+	// stop stops the pipeline
+	stop context.CancelFunc
+	// done is closed when the pipeline stops
+	done chan struct{}
+	// err specifies why the pipeline was stopped by escalation, if it was
+	err    error
+	errMux sync.Mutex
+}
+
+// component specifies pipeline component, which runs until context is done
+type component interface {
+	Run(ctx context.Context, wg *sync.WaitGroup)
+}
+
+// node specifies supervised component of the pipeline
+type node struct {
+	name      string
+	component component
 }
 
 func New(conf Config) *Controller {
 	return &Controller{
 		generatorInterval:   time.Duration(conf.GeneratorIntervalMillisecond) * time.Millisecond,
+		generatorCatchUp:    conf.GeneratorCatchUp,
+		generatorNoDelay:    conf.GeneratorNoDelay,
+		arrival:             conf.Arrival,
+		sourceOptions:       conf.Source,
+		deadLetterOptions:   conf.DeadLetter,
 		publisherInterval:   time.Duration(conf.PublisherIntervalSecond) * time.Second,
 		generatorPacketSize: conf.PacketSizeIn,
 		processorPacketSize: conf.PacketSizeOut,
+		generatorSizeMax:    conf.PacketSizeMax,
+		generatorSizeDist:   conf.PacketSizeDistribution,
+		shortPacketPolicy:   conf.ShortPacketPolicy,
+		stages:              conf.Stages,
+		alert:               conf.Alert,
+		alertCopy:           conf.AlertCopy,
+		joinOptions:         conf.Join,
+		joinInterval:        time.Duration(conf.JoinIntervalMillisecond) * time.Millisecond,
+		packetDeadline:      conf.PacketDeadline,
+		deadlinePolicy:      conf.DeadlinePolicy,
+		slowPercentile:      conf.SlowPercentile,
 		workersNum:          conf.WorkersNum,
+		poolOptions:         conf.Pool,
+		seed:                conf.Seed,
+		valueMin:            conf.ValueMin,
+		valueMax:            conf.ValueMax,
+		distribution:        conf.Distribution,
+		metrics:             metrics.NewRegistry(),
+		supervisorOptions:   conf.Supervisor,
+		tracingOptions:      conf.Tracing,
+		healthOptions:       conf.Health,
+		watchdogOptions:     conf.Watchdog,
+		config:              conf,
+		done:                make(chan struct{}),
 	}
 }
 
-func (c *Controller) buildPacketBuilder() generator.PacketBuilder {
+func (c *Controller) buildPacketBuilder() (generator.PacketBuilder, error) {
+	if c.sourceOptions.Kind != "" {
+		log.Infof("Building %s source", c.sourceOptions.Kind)
+		_source, err := source.New(
+			func(_len int) packet.Packet {
+				return mpacket.New(_len)
+			},
+			c.sourceOptions,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("source: %w", err)
+		}
+		c.source = _source
+		return _source, nil
+	}
+
 	log.Info("Building packet builder")
+	builder, err := c.newPacketBuilder(c.seed)
+	if err != nil {
+		return nil, fmt.Errorf("packet builder: %w", err)
+	}
+	return builder, nil
+}
+
+// newPacketBuilder creates builder of random packets with the seed
+func (c *Controller) newPacketBuilder(seed int64) (*packetbuilder.PacketBuilder, error) {
 	return packetbuilder.New(
-		func(_len int) packetbuilder.Packet {
+		func(_len int) packet.Packet {
 			return mpacket.New(_len)
 		},
 		packetbuilder.Options{
-			Size: c.generatorPacketSize,
+			Size:             c.generatorPacketSize,
+			SizeMax:          c.generatorSizeMax,
+			SizeDistribution: c.generatorSizeDist,
+			Seed:             seed,
+			ValueMin:         c.valueMin,
+			ValueMax:         c.valueMax,
+			Distribution:     c.distribution,
 		},
 	)
 }
 
-func (c *Controller) buildGenerator() (*generator.Generator, chan packet.Packet) {
+func (c *Controller) buildArrival() (arrival.Arrival, error) {
+	log.Info("Building arrival")
+	opts := c.arrival
+	opts.Interval = c.generatorInterval
+	opts.Seed = c.seed
+	_arrival, err := arrival.New(opts)
+	if err != nil {
+		return nil, fmt.Errorf("arrival: %w", err)
+	}
+	return _arrival, nil
+}
+
+func (c *Controller) buildGenerator(ch chan packet.Packet) (*generator.Generator, error) {
 	log.Info("Building generator")
-	log.Info("Making generator channel")
-	ch := make(chan packet.Packet)
-	gen := generator.New(
+	builder, err := c.buildPacketBuilder()
+	if err != nil {
+		return nil, err
+	}
+	_arrival, err := c.buildArrival()
+	if err != nil {
+		return nil, err
+	}
+	return generator.New(
 		ch,
-		c.buildPacketBuilder(),
+		builder,
 		generator.Options{
 			Interval: c.generatorInterval,
+			Arrival:  _arrival,
+			CatchUp:  c.generatorCatchUp,
+			NoDelay:  c.generatorNoDelay,
+			Tracer:   c.tracer,
+			Health:   c.health,
 		},
-	)
+	), nil
+}
 
-	return gen, ch
-}
-
-func (c *Controller) buildPool(in chan packet.Packet, out chan packet.Packet) *pool.Pool {
-	log.Info("Building pool")
-	_pool := pool.New(
-		c.workersNum,
-		func(id int) pool.Processor {
-			return processor.New(
-				id,
-				func(slice []int) processor.OutPacket {
-					return mpacket.New(slice)
-				},
-				processor.Pipes{
-					In:  in,
-					Out: out,
-				},
-				processor.Options{
-					ResultSize: c.processorPacketSize,
-				},
-			)
-		})
-	return _pool
+func (c *Controller) buildDeadLetter() (*dlq.Switch, error) {
+	log.Info("Building dead-letter sink")
+	sink, err := dlq.New(c.deadLetterOptions)
+	if err != nil {
+		return nil, fmt.Errorf("dead-letter sink: %w", err)
+	}
+	// Sink is switched on configuration reload
+	return dlq.NewSwitch(sink), nil
+}
+
+// defaultStages returns chain of the single top-N stage. Returns error in case short packet policy is unknown
+func (c *Controller) defaultStages() ([]chain.Stage, error) {
+	switch c.shortPacketPolicy {
+	case transform.ShortPacketPad, transform.ShortPacketPass, transform.ShortPacketReject:
+	case "":
+		c.shortPacketPolicy = transform.ShortPacketPad
+	default:
+		return nil, fmt.Errorf("unknown short packet policy %q", c.shortPacketPolicy)
+	}
+	return []chain.Stage{
+		{
+			Transform: transform.KindTopN,
+			Params: transform.Params{
+				"n":     strconv.Itoa(c.processorPacketSize),
+				"short": string(c.shortPacketPolicy),
+			},
+		},
+	}, nil
+}
+
+func (c *Controller) buildChain(out chan packet.Packet) (*chain.Chain, error) {
+	log.Info("Building chain")
+	var stages []chain.Stage
+	var err error
+	if c.stages != "" {
+		stages, err = chain.Parse(c.stages)
+	} else {
+		stages, err = c.defaultStages()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("chain: %w", err)
+	}
+
+	_chain, err := chain.New(
+		stages,
+		func(slice []int) packet.Packet {
+			return mpacket.New(slice)
+		},
+		c.supervisor,
+		c.metrics,
+		chain.Pipes{
+			Out:        out,
+			DeadLetter: c.deadLetter,
+		},
+		chain.Options{
+			Workers: c.workersNum,
+			Processor: processor.Options{
+				Deadline:       c.packetDeadline,
+				DeadlinePolicy: c.deadlinePolicy,
+				SlowPercentile: c.slowPercentile,
+				Tracer:         c.tracer,
+				Health:         c.health,
+			},
+			Pool: c.poolOptions,
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("chain: %w", err)
+	}
+	return _chain, nil
 }
 
 func (c *Controller) buildAccum() (*accum.Accum, chan packet.Packet) {
 	log.Info("Building accum")
 	log.Info("Making accum channel")
 	ch := make(chan packet.Packet)
-	return accum.New(ch), ch
+	return accum.New(ch, accum.Options{Tracer: c.tracer, Health: c.health}), ch
+
+}
+
+// buildJoin builds join of the generated packets with packets of the second generator, joined packets are delivered to out.
+// Returns nil nodes and out in case no join is specified. Returns error in case join is misconfigured
+func (c *Controller) buildJoin(out chan packet.Packet) ([]node, chan packet.Packet, []chan packet.Packet, error) {
+	if c.joinOptions.Mode == "" {
+		return nil, out, nil, nil
+	}
+	log.Info("Building join")
+	log.Info("Making join channels")
+	leftCh := make(chan packet.Packet)
+	rightCh := make(chan packet.Packet)
+	_join, err := join.New(
+		func(values []int) packet.Packet {
+			return mpacket.New(values)
+		},
+		c.metrics.Stage(join.Stage),
+		join.Pipes{
+			Left:       leftCh,
+			Right:      rightCh,
+			Out:        out,
+			DeadLetter: c.deadLetter,
+		},
+		c.joinOptions,
+	)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("join: %w", err)
+	}
 
+	log.Info("Building join generator")
+	// Second stream differs from the first one, unless both are random
+	seed := c.seed
+	if seed != 0 {
+		seed++
+	}
+	builder, err := c.newPacketBuilder(seed)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("join packet builder: %w", err)
+	}
+	right := generator.New(
+		rightCh,
+		builder,
+		generator.Options{
+			Name:     "join-generator",
+			Interval: c.joinInterval,
+		},
+	)
+	c.joinGenerator = right
+	return []node{{"join generator", right}, {"join", _join}}, leftCh, []chan packet.Packet{leftCh, rightCh}, nil
+}
+
+// buildAlerts builds router of packets matching alert predicate to the alerts sink, other packets are routed to out.
+// In case alerts are copied, all packets are routed to out and copies of the matching ones to the alerts sink.
+// Returns nil nodes and out in case no alert predicate is specified. Returns error in case alert predicate is invalid
+func (c *Controller) buildAlerts(out chan packet.Packet) ([]node, chan packet.Packet, []chan packet.Packet, error) {
+	if c.alert == "" {
+		return nil, out, nil, nil
+	}
+	log.Info("Building alerts")
+	predicate, err := topology.ParsePredicate(c.alert)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("alert: %w", err)
+	}
+
+	log.Info("Making router and alerts channels")
+	routerCh := make(chan packet.Packet)
+	alertsCh := make(chan packet.Packet)
+	in, chs := routerCh, []chan packet.Packet{routerCh, alertsCh}
+	routerOut := []chan packet.Packet{alertsCh, out}
+	var nodes []node
+	if c.alertCopy {
+		log.Info("Making broadcast channel")
+		in = make(chan packet.Packet)
+		chs = append([]chan packet.Packet{in}, chs...)
+		broadcast, err := topology.NewBroadcast(
+			"Broadcast",
+			func(slice []int) packet.Packet {
+				return mpacket.New(slice)
+			},
+			c.metrics.Stage("broadcast"),
+			topology.Pipes{
+				In:  []chan packet.Packet{in},
+				Out: []chan packet.Packet{routerCh, out},
+			},
+		)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("alert: %w", err)
+		}
+		nodes = append(nodes, node{"broadcast", broadcast})
+		// Originals are delivered to out by the broadcast, so router drops copies not matching the predicate
+		routerOut = routerOut[:1]
+	}
+	router, err := topology.NewRouter(
+		"Router",
+		[]topology.Predicate{predicate},
+		c.metrics.Stage("router"),
+		topology.Pipes{
+			In:  []chan packet.Packet{routerCh},
+			Out: routerOut,
+		},
+	)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("alert: %w", err)
+	}
+	alerts, err := topology.NewSink(
+		"Alerts",
+		func(pack packet.Packet) {
+			log.Warnf("Alert - #%d %s matches %s", pack.ID(), pack, c.alert)
+		},
+		c.metrics.Stage("alerts"),
+		topology.Pipes{
+			In: []chan packet.Packet{alertsCh},
+		},
+	)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("alert: %w", err)
+	}
+	return append(nodes, node{"router", router}, node{"alerts", alerts}), in, chs, nil
 }
 
 func (c *Controller) buildPublisher(accum *accum.Accum) *publisher.Publisher {
@@ -120,30 +465,235 @@ func (c *Controller) buildPublisher(accum *accum.Accum) *publisher.Publisher {
 	})
 }
 
-func (c *Controller) build() (*generator.Generator, *pool.Pool, *accum.Accum, *publisher.Publisher, func()) {
-	gen, genCh := c.buildGenerator()
+// buildTracer builds tracer of the packets. Returns nil in case packets are not traced
+func (c *Controller) buildTracer() *tracing.Tracer {
+	if c.tracingOptions.Sample <= 0 {
+		return nil
+	}
+	if c.joinOptions.Mode != "" {
+		// Join renumbers packets, so their traces can not be followed by packet ids
+		log.Warnf("Tracing - not supported with join, no tracing")
+		return nil
+	}
+	log.Info("Building tracer")
+	return tracing.New(c.tracingOptions)
+}
+
+// buildHealth builds monitor of the stage heartbeats
+func (c *Controller) buildHealth() *health.Monitor {
+	log.Info("Building health monitor")
+	return health.New(c.healthOptions)
+}
+
+// buildWatchdog builds stall detector of the stages and edges between them. Returns nil nodes in case no detector is specified
+func (c *Controller) buildWatchdog(edges []watchdog.Edge) []node {
+	if c.watchdogOptions.Interval <= 0 {
+		return nil
+	}
+	log.Info("Building watchdog")
+	return []node{{"watchdog", watchdog.New(c.health, edges, c.Stats, c.watchdogOptions)}}
+}
+
+// edges returns edges between the stages reporting heartbeats, which deliver packets to each other directly
+func (c *Controller) edges(joined, alerted bool) []watchdog.Edge {
+	var edges []watchdog.Edge
+	names := c.chain.Names()
+	if !joined {
+		edges = append(edges, watchdog.Edge{From: generator.DefaultName, To: names[0]})
+	}
+	for i := 1; i < len(names); i++ {
+		edges = append(edges, watchdog.Edge{From: names[i-1], To: names[i]})
+	}
+	if !alerted {
+		edges = append(edges, watchdog.Edge{From: names[len(names)-1], To: accum.Component})
+	}
+	return edges
+}
+
+func (c *Controller) buildSupervisor() *supervisor.Supervisor {
+	log.Info("Building supervisor")
+	return supervisor.New(c.escalate, c.supervisorOptions)
+}
+
+// build builds components of the pipeline. Returns error in case the pipeline is misconfigured
+func (c *Controller) build() (_ []node, _ *chain.Chain, _ func(), err error) {
+	c.supervisor = c.buildSupervisor()
+	c.tracer = c.buildTracer()
+	c.health = c.buildHealth()
+	c.deadLetter, err = c.buildDeadLetter()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	defer func() {
+		if err == nil {
+			return
+		}
+		if _err := c.deadLetter.Close(); _err != nil {
+			log.Errorf("Unable to close dead-letter sink: %v", _err)
+		}
+	}()
 	acc, accCh := c.buildAccum()
-	_pool := c.buildPool(genCh, accCh)
-	pub := c.buildPublisher(acc)
-	return gen, _pool, acc, pub, func() {
-		log.Info("Closing generator channel")
-		close(genCh)
+	c.accum = acc
+	alerts, chainOut, alertsChs, err := c.buildAlerts(accCh)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	c.chain, err = c.buildChain(chainOut)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	joins, genOut, joinChs, err := c.buildJoin(c.chain.In())
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	c.generator, err = c.buildGenerator(genOut)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	c.publisher = c.buildPublisher(acc)
+	// Pipeline is ready once the generator, the chain and the accumulator run
+	c.health.Expect(generator.DefaultName)
+	c.health.Expect(c.chain.Names()...)
+	c.health.Expect(accum.Component)
+	nodes := append([]node{{"generator", c.generator}, {"accum", acc}, {"publisher", c.publisher}}, joins...)
+	nodes = append(nodes, alerts...)
+	nodes = append(nodes, c.buildWatchdog(c.edges(len(joins) > 0, len(alerts) > 0))...)
+	return nodes, c.chain, func() {
+		if len(joinChs) > 0 {
+			log.Info("Closing join channels")
+			for _, ch := range joinChs {
+				close(ch)
+			}
+		}
+		log.Info("Closing chain channels")
+		c.chain.Close()
+		if len(alertsChs) > 0 {
+			log.Info("Closing alerts channels")
+			for _, ch := range alertsChs {
+				close(ch)
+			}
+		}
 		log.Info("Closing accum channel")
 		close(accCh)
-	}
+		for _, stage := range c.metrics.Stages() {
+			log.Infof("Stage %s stats: %s", stage.Name(), stage)
+		}
+		log.Info("Closing dead-letter sink")
+		if err := c.deadLetter.Close(); err != nil {
+			log.Errorf("Unable to close dead-letter sink: %v", err)
+		}
+	}, nil
 }
 
-func (c *Controller) Run(ctx context.Context) (*sync.WaitGroup, func()) {
-	gen, _pool, acc, pub, cancel := c.build()
+// Run launches the pipeline, which runs until context is done or a component crash-loops.
+// Returns error in case the pipeline is misconfigured, nothing is launched then
+func (c *Controller) Run(ctx context.Context) (*sync.WaitGroup, func(), error) {
+	c.reloadMux.Lock()
+	defer c.reloadMux.Unlock()
+	nodes, _chain, cancel, err := c.build()
+	if err != nil {
+		// Pipeline is not running, so it can not be reloaded
+		c.chain = nil
+		return nil, nil, err
+	}
+
+	ctx, c.stop = context.WithCancel(ctx)
+	go func() {
+		<-ctx.Done()
+		close(c.done)
+	}()
 
 	log.Info("Launching components")
 
 	wg := new(sync.WaitGroup)
-	wg.Add(3)
-	go gen.Run(ctx, wg)
-	go acc.Run(ctx, wg)
-	go pub.Run(ctx, wg)
+	if c.source != nil {
+		wg.Add(1)
+		go c.source.Run(ctx, wg)
+	}
+	for _, node := range nodes {
+		c.supervisor.Go(ctx, wg, node.name, task(node.component), nil)
+	}
+
+	_chain.Launch(ctx, wg)
+	return wg, func() {
+		c.stop()
+		cancel()
+	}, nil
+}
+
+// Tracer returns tracer of the packets, which is nil in case packets are not traced. Available once the pipeline runs
+func (c *Controller) Tracer() *tracing.Tracer {
+	c.reloadMux.Lock()
+	defer c.reloadMux.Unlock()
+	return c.tracer
+}
+
+// Stats returns state of the pipeline: stages of the chain followed by other stages and the accumulator
+func (c *Controller) Stats() metrics.PipelineStats {
+	c.reloadMux.Lock()
+	defer c.reloadMux.Unlock()
+	stats := metrics.PipelineStats{
+		Time: time.Now(),
+	}
+	if c.chain == nil {
+		// Pipeline is not running yet
+		return stats
+	}
+	stats.Stages = c.chain.Stats()
+	chained := make(map[string]bool)
+	for _, stage := range stats.Stages {
+		chained[stage.Name] = true
+	}
+	for _, stage := range c.metrics.Stages() {
+		if !chained[stage.Name()] {
+			stats.Stages = append(stats.Stages, stage.Stats())
+		}
+	}
+	stats.Stages = append(stats.Stages, c.accum.Stats().Stats())
+	stats.Accum = c.accum.Get()
+	return stats
+}
+
+// DeadLetter returns dead-letter sink of the packets failed to be processed. Available once the pipeline runs
+func (c *Controller) DeadLetter() *dlq.Switch {
+	c.reloadMux.Lock()
+	defer c.reloadMux.Unlock()
+	return c.deadLetter
+}
 
-	_pool.Launch(ctx, wg)
-	return wg, cancel
+// Health returns monitor of the stage heartbeats. Available once the pipeline runs
+func (c *Controller) Health() *health.Monitor {
+	c.reloadMux.Lock()
+	defer c.reloadMux.Unlock()
+	return c.health
+}
+
+// Done returns channel, which is closed when the pipeline stops
+func (c *Controller) Done() <-chan struct{} {
+	return c.done
+}
+
+// Err returns error in case the pipeline was stopped by escalation
+func (c *Controller) Err() error {
+	c.errMux.Lock()
+	defer c.errMux.Unlock()
+	return c.err
+}
+
+// escalate stops the pipeline
+func (c *Controller) escalate(err error) {
+	log.Errorf("Stopping pipeline: %v", err)
+	c.errMux.Lock()
+	c.err = err
+	c.errMux.Unlock()
+	c.stop()
+}
+
+// task adapts component to the supervised task
+func task(comp component) supervisor.Task {
+	return func(ctx context.Context) {
+		wg := new(sync.WaitGroup)
+		wg.Add(1)
+		comp.Run(ctx, wg)
+	}
 }
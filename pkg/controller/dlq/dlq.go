@@ -0,0 +1,301 @@
+// Copyright 2024 Vladislav Klimenko. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dlq
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Available sink kinds
+const (
+	KindMemory = "memory"
+	KindFile   = "file"
+)
+
+// DefaultSize specifies default number of entries kept by the memory sink
+const DefaultSize = 1000
+
+// Entry specifies dead-lettered packet
+type Entry struct {
+	// Time specifies when packet was dead-lettered
+	Time time.Time `json:"time"`
+	// Stage specifies name of the stage which failed to handle the packet
+	Stage string `json:"stage"`
+	// WorkerID specifies id of the stage worker which failed to handle the packet
+	WorkerID int `json:"worker_id"`
+	// Error specifies why packet was dead-lettered
+	Error string `json:"error"`
+	// Packet specifies values of the packet
+	Packet []int `json:"packet"`
+}
+
+// NewEntry creates new entry stamped with current time
+func NewEntry(stage string, workerID int, err error, packet []int) Entry {
+	// Packet may be reused by the stage, so keep a copy
+	values := make([]int, len(packet))
+	copy(values, packet)
+	return Entry{
+		Time:     time.Now(),
+		Stage:    stage,
+		WorkerID: workerID,
+		Error:    err.Error(),
+		Packet:   values,
+	}
+}
+
+// Line returns packet as a comma-separated line, which can be read by a source
+func (e Entry) Line() string {
+	values := make([]string, len(e.Packet))
+	for i, value := range e.Packet {
+		values[i] = strconv.Itoa(value)
+	}
+	return strings.Join(values, ",")
+}
+
+// String returns string representation of the entry
+func (e Entry) String() string {
+	return fmt.Sprintf("%s %s[%d] [%s] %s", e.Time.Format(time.RFC3339Nano), e.Stage, e.WorkerID, e.Line(), e.Error)
+}
+
+// Sink specifies dead-letter sink
+type Sink interface {
+	// Put stores the entry
+	Put(Entry)
+	// Close flushes and closes the sink
+	Close() error
+}
+
+// Options specifies dead-letter sink options
+type Options struct {
+	// Kind specifies sink kind, one of: memory, file. Empty kind means memory
+	Kind string
+	// Size specifies number of the latest entries kept by the memory sink
+	Size int
+	// Path specifies file of the file sink. Memory sink dumps its entries into the file on close, if specified
+	Path string
+}
+
+// New creates new dead-letter sink from options
+func New(opts Options) (Sink, error) {
+	switch strings.ToLower(opts.Kind) {
+	case "", KindMemory:
+		return NewRing(opts.Size, opts.Path), nil
+	case KindFile:
+		return NewFile(opts.Path)
+	}
+	return nil, fmt.Errorf("not a valid dead-letter sink: %q", opts.Kind)
+}
+
+// Ring specifies in-memory sink, which keeps the latest entries
+type Ring struct {
+	entries []Entry
+	// next specifies index where the next entry is put to
+	next int
+	// count specifies total number of entries ever put
+	count int
+	// path specifies file where entries are dumped on close, if any
+	path string
+	mux  sync.RWMutex
+}
+
+// NewRing creates new in-memory sink of specified size
+func NewRing(size int, path string) *Ring {
+	if size <= 0 {
+		size = DefaultSize
+	}
+	return &Ring{
+		entries: make([]Entry, size),
+		path:    path,
+	}
+}
+
+// Put stores the entry, the oldest entry is overwritten in case the ring is full
+func (r *Ring) Put(entry Entry) {
+	if r == nil {
+		return
+	}
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	r.entries[r.next] = entry
+	r.next = (r.next + 1) % len(r.entries)
+	r.count++
+}
+
+// List returns kept entries, the oldest first
+func (r *Ring) List() []Entry {
+	if r == nil {
+		return nil
+	}
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+
+	if r.count < len(r.entries) {
+		return append([]Entry(nil), r.entries[:r.count]...)
+	}
+	return append(append([]Entry(nil), r.entries[r.next:]...), r.entries[:r.next]...)
+}
+
+// Count returns total number of entries ever put
+func (r *Ring) Count() int {
+	if r == nil {
+		return 0
+	}
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+
+	return r.count
+}
+
+// Close dumps kept entries into the file, if specified
+func (r *Ring) Close() error {
+	if (r == nil) || (r.path == "") {
+		return nil
+	}
+	f, err := NewFile(r.path)
+	if err != nil {
+		return err
+	}
+	for _, entry := range r.List() {
+		f.Put(entry)
+	}
+	return f.Close()
+}
+
+// File specifies sink, which appends entries to a file as JSON lines
+type File struct {
+	file    *os.File
+	encoder *json.Encoder
+	// err specifies the first write error
+	err error
+	mux sync.Mutex
+}
+
+// NewFile creates new file sink
+func NewFile(path string) (*File, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &File{
+		file:    file,
+		encoder: json.NewEncoder(file),
+	}, nil
+}
+
+// Put appends the entry to the file. The first write error is reported on close
+func (f *File) Put(entry Entry) {
+	if f == nil {
+		return
+	}
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	// Entry is written right away, so entries are not lost in case of a crash
+	if err := f.encoder.Encode(entry); (err != nil) && (f.err == nil) {
+		f.err = err
+	}
+}
+
+// Close closes the file
+func (f *File) Close() error {
+	if f == nil {
+		return nil
+	}
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	if err := f.file.Close(); f.err == nil {
+		f.err = err
+	}
+	return f.err
+}
+
+// Switch specifies sink, which can be replaced while packets are being put into it
+type Switch struct {
+	sink Sink
+	mux  sync.RWMutex
+}
+
+// NewSwitch creates new switch of the sink
+func NewSwitch(sink Sink) *Switch {
+	return &Switch{
+		sink: sink,
+	}
+}
+
+// Put puts entry into the current sink
+func (s *Switch) Put(entry Entry) {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+	s.sink.Put(entry)
+}
+
+// Swap replaces the current sink. Returns the replaced sink, which is to be closed by the caller
+func (s *Switch) Swap(sink Sink) Sink {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	replaced := s.sink
+	s.sink = sink
+	return replaced
+}
+
+// Sink returns the current sink
+func (s *Switch) Sink() Sink {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+	return s.sink
+}
+
+// Close closes the current sink
+func (s *Switch) Close() error {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+	return s.sink.Close()
+}
+
+// ReadFile reads entries from the file written by the file sink
+func ReadFile(path string) ([]Entry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Read(f)
+}
+
+// Read reads entries written as JSON lines
+func Read(r io.Reader) ([]Entry, error) {
+	var entries []Entry
+	decoder := json.NewDecoder(r)
+	for {
+		var entry Entry
+		err := decoder.Decode(&entry)
+		switch {
+		case err == io.EOF:
+			return entries, nil
+		case err != nil:
+			return entries, err
+		}
+		entries = append(entries, entry)
+	}
+}
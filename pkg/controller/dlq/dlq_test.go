@@ -0,0 +1,111 @@
+// Copyright 2024 Vladislav Klimenko. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dlq
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRing(t *testing.T) {
+	ring := NewRing(3, "")
+	require.Empty(t, ring.List())
+	for i := 0; i < 5; i++ {
+		ring.Put(NewEntry("processor", i, fmt.Errorf("error %d", i), []int{i}))
+	}
+	entries := ring.List()
+	require.Len(t, entries, 3)
+	for i, entry := range entries {
+		require.Equal(t, i+2, entry.WorkerID, "Check the oldest entries are dropped")
+	}
+	require.Equal(t, 5, ring.Count())
+}
+
+func TestFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dlq")
+	sink, err := New(Options{Kind: KindFile, Path: path})
+	require.NoError(t, err)
+	packet := []int{3, 2, 1}
+	sink.Put(NewEntry("processor", 1, fmt.Errorf("failed"), packet))
+	// Entry keeps its own copy of the packet
+	packet[0] = 100
+	sink.Put(NewEntry("accum", 0, fmt.Errorf("failed again"), nil))
+	require.NoError(t, sink.Close())
+
+	entries, err := ReadFile(path)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	require.Equal(t, "3,2,1", entries[0].Line())
+	require.Equal(t, "processor", entries[0].Stage)
+	require.Equal(t, 1, entries[0].WorkerID)
+	require.Equal(t, "failed", entries[0].Error)
+	require.Equal(t, "", entries[1].Line())
+}
+
+func TestRingDump(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dlq")
+	ring := NewRing(2, path)
+	for i := 0; i < 3; i++ {
+		ring.Put(NewEntry("processor", i, fmt.Errorf("error"), []int{i}))
+	}
+	require.NoError(t, ring.Close())
+
+	entries, err := ReadFile(path)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	require.Equal(t, "1", entries[0].Line())
+	require.Equal(t, "2", entries[1].Line())
+}
+
+func TestSwitch(t *testing.T) {
+	first := NewRing(10, "")
+	second := NewRing(10, "")
+	sink := NewSwitch(first)
+	sink.Put(NewEntry("processor", 0, fmt.Errorf("first"), []int{1}))
+	require.Equal(t, first, sink.Swap(second))
+	sink.Put(NewEntry("processor", 0, fmt.Errorf("second"), []int{2}))
+	require.NoError(t, sink.Close())
+
+	require.Len(t, first.List(), 1)
+	require.Len(t, second.List(), 1)
+	require.Equal(t, "second", second.List()[0].Error)
+}
+
+func TestHandler(t *testing.T) {
+	ring := NewRing(10, "")
+	ring.Put(NewEntry("processor", 1, fmt.Errorf("failed"), []int{3, 2, 1}))
+	sink := NewSwitch(ring)
+
+	w := httptest.NewRecorder()
+	Handler(sink).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/dlq", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	entries, err := Read(w.Body)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, "3,2,1", entries[0].Line())
+
+	file, err := NewFile(filepath.Join(t.TempDir(), "dlq"))
+	require.NoError(t, err)
+	require.NoError(t, sink.Swap(file).Close())
+	w = httptest.NewRecorder()
+	Handler(sink).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/dlq", nil))
+	require.Equal(t, http.StatusNotFound, w.Code, "Check file sink keeps no entries in memory")
+	require.NoError(t, sink.Close())
+}
@@ -0,0 +1,43 @@
+// Copyright 2024 Vladislav Klimenko. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dlq
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Handler returns HTTP handler, which responds with entries kept by the current in-memory sink, the oldest first, as JSON lines.
+// Responds with not found in case the current sink keeps no entries in memory
+func Handler(sink *Switch) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		ring, ok := sink.Sink().(*Ring)
+		if !ok {
+			http.Error(w, "dead-letter sink keeps no entries in memory", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		encoder := json.NewEncoder(w)
+		for _, entry := range ring.List() {
+			if err := encoder.Encode(entry); err != nil {
+				return
+			}
+		}
+	})
+}
@@ -0,0 +1,216 @@
+// Copyright 2024 Vladislav Klimenko. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package arrival
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// Available arrival kinds
+const (
+	KindRegular = "regular"
+	KindPoisson = "poisson"
+	KindBurst   = "burst"
+	KindRamp    = "ramp"
+	KindSteps   = "steps"
+)
+
+// Never specifies delay returned when no more packets are expected to arrive
+const Never = time.Duration(math.MaxInt64)
+
+// Arrival specifies arrival process of packets
+type Arrival interface {
+	// Next returns delay between the previous and the next packet
+	Next() time.Duration
+}
+
+// Options specifies arrival process options
+type Options struct {
+	// Kind specifies arrival kind, one of: regular, poisson, burst, ramp, steps. Empty kind means regular.
+	Kind string
+	// Interval specifies interval between packets of regular arrival
+	Interval time.Duration
+	// Rate specifies mean rate (packets per second) of poisson arrival
+	Rate float64
+	// Seed specifies seed of poisson arrival random source. Zero means seed from current time.
+	Seed int64
+	// BurstSize specifies number of packets in a burst
+	BurstSize int
+	// BurstInterval specifies interval between bursts
+	BurstInterval time.Duration
+	// RampFrom specifies rate (packets per second) ramp starts with
+	RampFrom float64
+	// RampTo specifies rate (packets per second) ramp ends with and keeps afterwards
+	RampTo float64
+	// RampDuration specifies duration of the ramp
+	RampDuration time.Duration
+	// Profile specifies path to step-function profile file of the steps arrival
+	Profile string
+}
+
+// New creates arrival process from options
+func New(opts Options) (Arrival, error) {
+	switch strings.ToLower(opts.Kind) {
+	case "", KindRegular:
+		return NewRegular(opts.Interval), nil
+	case KindPoisson:
+		if opts.Rate <= 0 {
+			return nil, fmt.Errorf("poisson arrival requires rate > 0, got %v", opts.Rate)
+		}
+		seed := opts.Seed
+		if seed == 0 {
+			seed = time.Now().UnixNano()
+		}
+		return NewPoisson(opts.Rate, rand.New(rand.NewSource(seed))), nil
+	case KindBurst:
+		if opts.BurstSize <= 0 {
+			return nil, fmt.Errorf("burst arrival requires burst size > 0, got %v", opts.BurstSize)
+		}
+		return NewBurst(opts.BurstSize, opts.BurstInterval), nil
+	case KindRamp:
+		if (opts.RampFrom < 0) || (opts.RampTo < 0) || (opts.RampFrom+opts.RampTo == 0) {
+			return nil, fmt.Errorf("ramp arrival requires non-negative rates and at least one of them > 0, got %v - %v", opts.RampFrom, opts.RampTo)
+		}
+		return NewRamp(opts.RampFrom, opts.RampTo, opts.RampDuration), nil
+	case KindSteps:
+		if opts.Profile == "" {
+			return nil, fmt.Errorf("steps arrival requires profile")
+		}
+		steps, err := ReadProfile(opts.Profile)
+		if err != nil {
+			return nil, err
+		}
+		return NewSteps(steps)
+	}
+
+	return nil, fmt.Errorf("not a valid arrival: %q", opts.Kind)
+}
+
+// Regular specifies arrival with constant interval between packets
+type Regular struct {
+	interval time.Duration
+}
+
+// NewRegular creates regular arrival
+func NewRegular(interval time.Duration) *Regular {
+	return &Regular{
+		interval: interval,
+	}
+}
+
+// Next returns delay before the next packet
+func (a *Regular) Next() time.Duration {
+	return a.interval
+}
+
+// Poisson specifies arrival with exponentially distributed intervals between packets
+type Poisson struct {
+	rate float64
+	rand *rand.Rand
+}
+
+// NewPoisson creates poisson arrival with mean rate in packets per second
+func NewPoisson(rate float64, rnd *rand.Rand) *Poisson {
+	return &Poisson{
+		rate: rate,
+		rand: rnd,
+	}
+}
+
+// Next returns delay before the next packet
+func (a *Poisson) Next() time.Duration {
+	return seconds(a.rand.ExpFloat64() / a.rate)
+}
+
+// Burst specifies arrival of bursts of packets at regular interval
+type Burst struct {
+	size     int
+	interval time.Duration
+	count    int
+}
+
+// NewBurst creates burst arrival of size packets every interval
+func NewBurst(size int, interval time.Duration) *Burst {
+	return &Burst{
+		size:     size,
+		interval: interval,
+	}
+}
+
+// Next returns delay before the next packet
+func (a *Burst) Next() time.Duration {
+	defer func() { a.count++ }()
+	if a.count%a.size == 0 {
+		// First packet of the burst
+		return a.interval
+	}
+	return 0
+}
+
+// Ramp specifies arrival with rate changing linearly over time and constant afterwards
+type Ramp struct {
+	from     float64
+	to       float64
+	duration float64
+	// count specifies number of packets arrived so far
+	count float64
+	// now specifies time of the last packet since the ramp start, in seconds
+	now float64
+}
+
+// NewRamp creates ramp arrival with rate changing from rate 'from' to rate 'to' (in packets per second) within duration
+func NewRamp(from, to float64, duration time.Duration) *Ramp {
+	return &Ramp{
+		from:     from,
+		to:       to,
+		duration: duration.Seconds(),
+	}
+}
+
+// Next returns delay before the next packet
+func (a *Ramp) Next() time.Duration {
+	// Find time at which cumulative number of arrived packets reaches count+1
+	a.count++
+	slope := 0.0
+	if a.duration > 0 {
+		slope = (a.to - a.from) / a.duration
+	}
+	// Cumulative number of packets within the ramp is N(t) = from*t + slope*t^2/2
+	total := a.from*a.duration + slope*a.duration*a.duration/2
+	var at float64
+	switch {
+	case a.count > total:
+		if a.to == 0 {
+			return Never
+		}
+		at = a.duration + (a.count-total)/a.to
+	case slope == 0:
+		at = a.count / a.from
+	default:
+		at = (-a.from + math.Sqrt(math.Max(a.from*a.from+2*slope*a.count, 0))) / slope
+	}
+	delay := at - a.now
+	a.now = at
+	return seconds(delay)
+}
+
+// seconds converts seconds to duration
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
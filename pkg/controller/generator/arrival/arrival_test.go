@@ -0,0 +1,132 @@
+// Copyright 2024 Vladislav Klimenko. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package arrival
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// count returns number of packets arrived within duration
+func count(a Arrival, duration time.Duration) int {
+	var now time.Duration
+	n := 0
+	for {
+		delay := a.Next()
+		if delay == Never {
+			return n
+		}
+		now += delay
+		if now > duration {
+			return n
+		}
+		n++
+	}
+}
+
+func TestRegular(t *testing.T) {
+	require.Equal(t, 100, count(NewRegular(10*time.Millisecond), time.Second))
+}
+
+func TestPoisson(t *testing.T) {
+	a, err := New(Options{Kind: KindPoisson, Rate: 1000, Seed: 1})
+	require.NoError(t, err)
+	require.InEpsilon(t, 100000, count(a, 100*time.Second), 0.01)
+}
+
+func TestBurst(t *testing.T) {
+	a := NewBurst(3, time.Second)
+	expect := []time.Duration{time.Second, 0, 0, time.Second, 0, 0, time.Second}
+	for i, delay := range expect {
+		require.Equal(t, delay, a.Next(), "Check burst delay: %d", i)
+	}
+}
+
+func TestRamp(t *testing.T) {
+	tests := []struct {
+		from     float64
+		to       float64
+		duration time.Duration
+		within   time.Duration
+		expect   int
+	}{
+		{
+			// Ramp-up from zero: (0 + 100) / 2 * 10
+			from:     0,
+			to:       100,
+			duration: 10 * time.Second,
+			within:   10 * time.Second,
+			expect:   500,
+		},
+		{
+			// Ramp-down and keep the final rate afterwards: (100 + 50) / 2 * 10 + 50 * 10
+			from:     100,
+			to:       50,
+			duration: 10 * time.Second,
+			within:   20 * time.Second,
+			expect:   1250,
+		},
+		{
+			// Ramp-down to zero stops arrival
+			from:     100,
+			to:       0,
+			duration: 10 * time.Second,
+			within:   time.Hour,
+			expect:   500,
+		},
+	}
+	for _, tt := range tests {
+		a := NewRamp(tt.from, tt.to, tt.duration)
+		require.InDelta(t, tt.expect, count(a, tt.within), 1, "Check ramp %v - %v", tt.from, tt.to)
+	}
+}
+
+func TestSteps(t *testing.T) {
+	steps, err := ParseProfile(strings.NewReader(`
+		# warm-up
+		1s 10
+		500ms 0
+		2s 100
+	`))
+	require.NoError(t, err)
+	require.Equal(t, []Step{{time.Second, 10}, {500 * time.Millisecond, 0}, {2 * time.Second, 100}}, steps)
+
+	a, err := NewSteps(steps)
+	require.NoError(t, err)
+	// Profile is repeated twice within 7 seconds: 2 * (10 + 0 + 200)
+	require.InDelta(t, 420, count(a, 7*time.Second), 1)
+}
+
+func TestInvalid(t *testing.T) {
+	tests := []Options{
+		{Kind: "unknown"},
+		{Kind: KindPoisson},
+		{Kind: KindBurst},
+		{Kind: KindRamp},
+		{Kind: KindSteps, Profile: "no-such-file"},
+	}
+	for _, tt := range tests {
+		_, err := New(tt)
+		require.Error(t, err, "Check invalid arrival: %v", tt)
+	}
+
+	_, err := ParseProfile(strings.NewReader("1s"))
+	require.Error(t, err)
+	_, err = NewSteps([]Step{{time.Second, 0}})
+	require.Error(t, err)
+}
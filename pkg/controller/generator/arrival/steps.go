@@ -0,0 +1,124 @@
+// Copyright 2024 Vladislav Klimenko. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package arrival
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Step specifies one step of the step-function load profile
+type Step struct {
+	// Duration specifies duration of the step
+	Duration time.Duration
+	// Rate specifies rate of packets (in packets per second) within the step
+	Rate float64
+}
+
+// Steps specifies arrival with piecewise-constant rate. Profile is repeated after the last step.
+type Steps struct {
+	steps []Step
+	// step specifies index of the current step
+	step int
+	// elapsed specifies time passed since the current step start, in seconds
+	elapsed float64
+}
+
+// NewSteps creates steps arrival
+func NewSteps(steps []Step) (*Steps, error) {
+	positive := false
+	for _, step := range steps {
+		if (step.Duration <= 0) || (step.Rate < 0) {
+			return nil, fmt.Errorf("step requires duration > 0 and rate >= 0, got %s %v", step.Duration, step.Rate)
+		}
+		positive = positive || (step.Rate > 0)
+	}
+	if !positive {
+		return nil, fmt.Errorf("steps profile requires at least one step with rate > 0")
+	}
+	return &Steps{
+		steps: steps,
+	}, nil
+}
+
+// Next returns delay before the next packet
+func (a *Steps) Next() time.Duration {
+	// Each packet requires one unit of "work", where step's rate specifies how much work is done per second
+	work := 1.0
+	delay := 0.0
+	for {
+		step := a.steps[a.step]
+		left := step.Duration.Seconds() - a.elapsed
+		if step.Rate*left >= work {
+			spent := work / step.Rate
+			a.elapsed += spent
+			return seconds(delay + spent)
+		}
+		// Step ends before the packet arrives
+		work -= step.Rate * left
+		delay += left
+		a.elapsed = 0
+		a.step = (a.step + 1) % len(a.steps)
+	}
+}
+
+// ReadProfile reads step-function profile from file
+func ReadProfile(path string) ([]Step, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseProfile(f)
+}
+
+// ParseProfile parses step-function profile.
+// Each line specifies one step as a duration and a rate in packets per second, ex.: "10s 100".
+// Empty lines and lines starting with '#' are ignored.
+func ParseProfile(r io.Reader) ([]Step, error) {
+	var steps []Step
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if (text == "") || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("profile line %d: expected 'duration rate', got %q", line, text)
+		}
+		duration, err := time.ParseDuration(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("profile line %d: %w", line, err)
+		}
+		rate, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("profile line %d: %w", line, err)
+		}
+		steps = append(steps, Step{Duration: duration, Rate: rate})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(steps) == 0 {
+		return nil, fmt.Errorf("profile has no steps")
+	}
+	return steps, nil
+}
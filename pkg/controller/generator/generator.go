@@ -21,18 +21,43 @@ import (
 
 	log "github.com/sirupsen/logrus"
 
-	"github.com/sunsingerus/pipeline/pkg/controller/generator/packet_builder"
+	"github.com/sunsingerus/pipeline/pkg/controller/generator/arrival"
+	"github.com/sunsingerus/pipeline/pkg/controller/health"
 	"github.com/sunsingerus/pipeline/pkg/controller/packet"
+	"github.com/sunsingerus/pipeline/pkg/controller/stage"
+	"github.com/sunsingerus/pipeline/pkg/controller/tracing"
+	"github.com/sunsingerus/pipeline/pkg/logger"
 )
 
+// PacketBuilder builds packets to be generated. Nil packet means there is no packet to generate at the moment
 type PacketBuilder interface {
-	Build() packetbuilder.Packet
+	Build() packet.Packet
 }
 
+// DefaultName specifies name of the generator component, unless specified
+const DefaultName = "generator"
+
+// idleInterval specifies how long generator without delay waits once packet builder has no packet to provide
+const idleInterval = time.Millisecond
+
 // Options specifies generator options
 type Options struct {
+	// Name specifies component generator logs as. Empty name means generator
+	Name string
 	// Interval specifies interval between packet generations (in milliseconds)
 	Interval time.Duration
+	// Arrival specifies arrival process of generated packets. Nil means regular arrival with Interval
+	Arrival arrival.Arrival
+	// CatchUp specifies whether packets, which were due while delivery was blocked, are generated at once afterwards,
+	// so the rate of the arrival is kept. False means they are dropped, as ticker drops ticks
+	CatchUp bool
+	// NoDelay specifies packets are generated back to back, each one once the previous one is delivered.
+	// Interval and Arrival are ignored
+	NoDelay bool
+	// Tracer specifies tracer of the generated packets. Nil means no tracing
+	Tracer *tracing.Tracer
+	// Health specifies monitor generator reports heartbeats to as stage of its name. Nil means no heartbeats
+	Health *health.Monitor
 }
 
 // Generator specifies generator
@@ -40,28 +65,57 @@ type Generator struct {
 	// out specifies chan where generator puts generated packet
 	out           chan packet.Packet
 	packetBuilder PacketBuilder
+	// sequence specifies id of the last generated packet
+	sequence uint64
+	// rearm is signalled when arrival is replaced, so the next packet is rescheduled
+	rearm chan struct{}
+	mux   sync.Mutex
+	log   *logger.Entry
 	Options
 }
 
 // New creates new generator from options
 func New(out chan packet.Packet, packetBuilder PacketBuilder, opts Options) *Generator {
+	if opts.Name == "" {
+		opts.Name = DefaultName
+	}
+	if opts.Arrival == nil {
+		opts.Arrival = arrival.NewRegular(opts.Interval)
+	}
 	return &Generator{
 		out:           out,
 		packetBuilder: packetBuilder,
+		rearm:         make(chan struct{}, 1),
+		log:           logger.Component(opts.Name),
 		Options:       opts,
 	}
 }
 
-func (g *Generator) deliver(ctx context.Context, pack packetbuilder.Packet) {
+// deliver numbers and delivers packet, which started to be built at the specified time
+func (g *Generator) deliver(ctx context.Context, pack packet.Packet, built time.Time) {
 	if g == nil {
 		return
 	}
-	select {
-	case <-ctx.Done():
-		log.Infof("Generator - NODELIVERY: %s", pack)
-	case g.out <- pack.(packet.Packet):
-		log.Infof("Generator - delivered : %s", pack)
+	// Packets are numbered starting from 1
+	g.sequence++
+	pack.SetID(g.sequence)
+	if g.Options.Tracer.Start(g.sequence, built) {
+		g.Options.Tracer.Span(g.sequence, tracing.SpanGenerate, built, time.Now())
 	}
+	// Packet is described for debug logs only, before the first stage transforms it
+	entry, str := g.log, ""
+	if g.log.Logger.IsLevelEnabled(log.DebugLevel) {
+		entry, str = g.log.WithField(logger.FieldPacketID, g.sequence), pack.String()
+	}
+	g.Options.Health.Begin(g.Options.Name)
+	defer g.Options.Health.End(g.Options.Name)
+	g.Options.Health.BeginSend(g.Options.Name)
+	defer g.Options.Health.EndSend(g.Options.Name)
+	if !stage.Deliver(ctx, g.out, pack) {
+		entry.Debugf("NODELIVERY: %s", str)
+		return
+	}
+	entry.Debugf("delivered: %s", str)
 }
 
 // Run runs generator until context is done
@@ -70,20 +124,99 @@ func (g *Generator) Run(ctx context.Context, wg *sync.WaitGroup) {
 	if g == nil {
 		return
 	}
-	log.Infof("Generator start")
-	defer log.Infof("Generator end")
+	g.log.Info("start")
+	defer g.log.Info("end")
+	g.Options.Health.Start(g.Options.Name)
+	defer g.Options.Health.Stop(g.Options.Name)
+	if g.Options.NoDelay {
+		g.flood(ctx)
+		return
+	}
 
-	ticker := time.NewTicker(g.Options.Interval)
+	// Packets are scheduled against absolute time, so arrival is not skewed by time spent to deliver
+	next := time.Now()
+	timer := time.NewTimer(arrival.Never)
+	g.schedule(timer, &next)
 	for {
 		select {
 		case <-ctx.Done():
-			ticker.Stop()
-			log.Infof("Generator - done")
+			timer.Stop()
+			g.log.Info("done")
 			return
-		case at := <-ticker.C:
-			pack := g.packetBuilder.Build()
-			log.Infof("Generator - new packet: %s @[%s]", pack, at)
-			g.deliver(ctx, pack)
+		case at := <-timer.C:
+			// Packet builder may have no packet to provide, ex.: stopped external source
+			built := time.Now()
+			if pack := g.packetBuilder.Build(); pack != nil {
+				g.log.Tracef("new packet: %s @[%s]", pack, at)
+				g.deliver(ctx, pack, built)
+			}
+			g.schedule(timer, &next)
+		case <-g.rearm:
+			g.log.Info("arrival replaced")
+			next = time.Now()
+			g.schedule(timer, &next)
+		}
+	}
+}
+
+// flood generates packets without delay until context is done
+func (g *Generator) flood(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			g.log.Info("done")
+			return
+		default:
+		}
+		built := time.Now()
+		pack := g.packetBuilder.Build()
+		if pack == nil {
+			// Packet builder has no packet to provide at the moment, ex.: stopped external source
+			select {
+			case <-ctx.Done():
+			case <-time.After(idleInterval):
+			}
+			continue
+		}
+		g.log.Tracef("new packet: %s", pack)
+		g.deliver(ctx, pack, built)
+	}
+}
+
+// SetArrival replaces arrival process of the running generator. The next packet is rescheduled.
+// Generator without delay ignores arrival
+func (g *Generator) SetArrival(_arrival arrival.Arrival) {
+	if (g == nil) || (_arrival == nil) {
+		return
+	}
+	g.mux.Lock()
+	g.Options.Arrival = _arrival
+	g.mux.Unlock()
+	select {
+	case g.rearm <- struct{}{}:
+	default:
+	}
+}
+
+// schedule resets timer to the arrival time of the next packet
+func (g *Generator) schedule(timer *time.Timer, next *time.Time) {
+	g.mux.Lock()
+	delay := g.Options.Arrival.Next()
+	g.mux.Unlock()
+	if delay == arrival.Never {
+		g.log.Info("no more packets expected to arrive")
+		return
+	}
+	*next = next.Add(delay)
+	if now := time.Now(); !g.Options.CatchUp && next.Before(now) {
+		// Packets due while delivery was blocked are dropped
+		*next = now
+	}
+	if !timer.Stop() {
+		select {
+		case <-timer.C:
+		default:
 		}
 	}
+	timer.Reset(time.Until(*next))
 }
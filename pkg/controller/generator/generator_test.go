@@ -20,6 +20,7 @@ import (
 	packetbuilder "github.com/sunsingerus/pipeline/pkg/controller/generator/packet_builder"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/sunsingerus/pipeline/pkg/controller/packet"
 	model "github.com/sunsingerus/pipeline/pkg/model/packet"
@@ -42,7 +43,8 @@ func TestGenerator(t *testing.T) {
 	}
 	for _, tt := range tests {
 		ch := make(chan packet.Packet)
-		builder := packetbuilder.New(func(size int) packetbuilder.Packet { return model.New(size) }, packetbuilder.Options{Size: tt.size})
+		builder, err := packetbuilder.New(func(size int) packet.Packet { return model.New(size) }, packetbuilder.Options{Size: tt.size, ValueMax: packetbuilder.DefaultValueMax})
+		require.NoError(t, err)
 		gen := New(ch, builder, Options{Interval: 100})
 
 		wg := &sync.WaitGroup{}
@@ -52,6 +54,9 @@ func TestGenerator(t *testing.T) {
 
 		pack := <-ch
 		require.Equal(t, tt.expect, pack.Len(), "Check generator: %s", pack)
+		require.Equal(t, uint64(1), pack.ID(), "Check generator: %s", pack)
+		pack = <-ch
+		require.Equal(t, uint64(2), pack.ID(), "Check generator: %s", pack)
 
 		cancel()
 		wg.Wait()
@@ -59,3 +64,57 @@ func TestGenerator(t *testing.T) {
 		ch = nil
 	}
 }
+
+func TestGeneratorStall(t *testing.T) {
+	interval := 20 * time.Millisecond
+	for _, catchUp := range []bool{false, true} {
+		ch := make(chan packet.Packet)
+		builder, err := packetbuilder.New(func(size int) packet.Packet { return model.New(size) }, packetbuilder.Options{Size: 1, ValueMax: 1})
+		require.NoError(t, err)
+		gen := New(ch, builder, Options{Interval: interval, CatchUp: catchUp})
+
+		wg := &sync.WaitGroup{}
+		ctx, cancel := context.WithCancel(context.Background())
+		wg.Add(1)
+		go gen.Run(ctx, wg)
+
+		// Delivery is blocked for a number of intervals
+		<-ch
+		time.Sleep(15 * interval)
+		<-ch
+		start := time.Now()
+		<-ch
+		<-ch
+		<-ch
+		elapsed := time.Since(start)
+		if catchUp {
+			require.Less(t, elapsed, interval, "Check packets due during the stall are generated at once")
+		} else {
+			require.GreaterOrEqual(t, elapsed, 3*interval/2, "Check packets due during the stall are dropped")
+		}
+
+		cancel()
+		wg.Wait()
+	}
+}
+
+func TestGeneratorNoDelay(t *testing.T) {
+	ch := make(chan packet.Packet)
+	builder, err := packetbuilder.New(func(size int) packet.Packet { return model.New(size) }, packetbuilder.Options{Size: 1, ValueMax: 1})
+	require.NoError(t, err)
+	// Arrival would delay packets for an hour, unless it is ignored
+	gen := New(ch, builder, Options{Interval: time.Hour, NoDelay: true})
+
+	wg := &sync.WaitGroup{}
+	ctx, cancel := context.WithCancel(context.Background())
+	wg.Add(1)
+	go gen.Run(ctx, wg)
+
+	for id := uint64(1); id <= 100; id++ {
+		pack := <-ch
+		require.Equal(t, id, pack.ID())
+	}
+
+	cancel()
+	wg.Wait()
+}
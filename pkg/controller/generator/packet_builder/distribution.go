@@ -0,0 +1,215 @@
+// Copyright 2024 Vladislav Klimenko. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package packetbuilder
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+)
+
+// Available distribution kinds
+const (
+	DistributionUniform     = "uniform"
+	DistributionNormal      = "normal"
+	DistributionExponential = "exponential"
+	DistributionZipf        = "zipf"
+	DistributionPoisson     = "poisson"
+	DistributionDrift       = "drift"
+)
+
+// Distribution specifies source of packet item values
+type Distribution interface {
+	// Next returns next value
+	Next() int
+}
+
+// DistributionOptions specifies distribution of packet item values.
+// Nil parameters are replaced with defaults derived from the value range.
+type DistributionOptions struct {
+	// Kind specifies distribution kind, one of: uniform, normal, exponential, zipf, poisson, drift.
+	// Empty kind means uniform.
+	Kind string
+	// Mean specifies mean of normal and poisson distributions and initial mean of drift distribution
+	Mean *float64
+	// StdDev specifies standard deviation of normal and drift distributions
+	StdDev *float64
+	// Rate specifies rate (lambda) of exponential distribution, values are counted from the min value
+	Rate *float64
+	// ZipfS specifies s > 1 parameter of zipf distribution, values are counted from the min value
+	ZipfS *float64
+	// ZipfV specifies v >= 1 parameter of zipf distribution
+	ZipfV *float64
+	// Drift specifies change of the drift distribution mean per generated value.
+	// Mean bounces back when it reaches bounds of the value range.
+	Drift float64
+}
+
+// Float returns pointer to the value of distribution parameter
+func Float(value float64) *float64 {
+	return &value
+}
+
+// param returns value of the parameter or default value in case parameter is not set
+func param(value *float64, defaultValue float64) float64 {
+	if value == nil {
+		return defaultValue
+	}
+	return *value
+}
+
+// NewDistribution creates distribution of values within [min, max] range which uses specified random source
+func NewDistribution(opts DistributionOptions, min, max int, rnd *rand.Rand) (Distribution, error) {
+	width := float64(max - min)
+	mean := param(opts.Mean, float64(min)+width/2)
+	stdDev := param(opts.StdDev, math.Max(width/6, 1))
+	rate := param(opts.Rate, 1/math.Max(width/4, 1))
+	zipfS := param(opts.ZipfS, 1.1)
+	zipfV := param(opts.ZipfV, 1)
+
+	base := bounds{min: min, max: max}
+	switch strings.ToLower(opts.Kind) {
+	case "", DistributionUniform:
+		return &uniform{bounds: base, rand: rnd}, nil
+	case DistributionNormal:
+		if stdDev < 0 {
+			return nil, fmt.Errorf("normal distribution requires stddev >= 0, got %v", stdDev)
+		}
+		return &normal{bounds: base, rand: rnd, mean: mean, stdDev: stdDev}, nil
+	case DistributionExponential:
+		if rate <= 0 {
+			return nil, fmt.Errorf("exponential distribution requires rate > 0, got %v", rate)
+		}
+		return &exponential{bounds: base, rand: rnd, rate: rate}, nil
+	case DistributionZipf:
+		if (zipfS <= 1) || (zipfV < 1) {
+			return nil, fmt.Errorf("zipf distribution requires s > 1 and v >= 1, got s=%v v=%v", zipfS, zipfV)
+		}
+		return &zipf{bounds: base, zipf: rand.NewZipf(rnd, zipfS, zipfV, uint64(max-min))}, nil
+	case DistributionPoisson:
+		if mean <= 0 {
+			return nil, fmt.Errorf("poisson distribution requires mean > 0, got %v", mean)
+		}
+		return &poisson{bounds: base, rand: rnd, mean: mean}, nil
+	case DistributionDrift:
+		if stdDev < 0 {
+			return nil, fmt.Errorf("drift distribution requires stddev >= 0, got %v", stdDev)
+		}
+		return &drift{normal: normal{bounds: base, rand: rnd, mean: mean, stdDev: stdDev}, drift: opts.Drift}, nil
+	}
+
+	return nil, fmt.Errorf("not a valid distribution: %q", opts.Kind)
+}
+
+// bounds specifies value range all distributions are clamped to
+type bounds struct {
+	min int
+	max int
+}
+
+func (b bounds) clamp(value int) int {
+	if value < b.min {
+		return b.min
+	}
+	if value > b.max {
+		return b.max
+	}
+	return value
+}
+
+// uniform distribution over [min, max]
+type uniform struct {
+	bounds
+	rand *rand.Rand
+}
+
+func (d *uniform) Next() int {
+	return d.min + d.rand.Intn(d.max-d.min+1)
+}
+
+// normal distribution rounded to the nearest integer
+type normal struct {
+	bounds
+	rand   *rand.Rand
+	mean   float64
+	stdDev float64
+}
+
+func (d *normal) Next() int {
+	return d.clamp(int(math.Round(d.rand.NormFloat64()*d.stdDev + d.mean)))
+}
+
+// exponential distribution counted from the min value
+type exponential struct {
+	bounds
+	rand *rand.Rand
+	rate float64
+}
+
+func (d *exponential) Next() int {
+	return d.clamp(d.min + int(d.rand.ExpFloat64()/d.rate))
+}
+
+// zipf distribution counted from the min value
+type zipf struct {
+	bounds
+	zipf *rand.Zipf
+}
+
+func (d *zipf) Next() int {
+	return d.clamp(d.min + int(d.zipf.Uint64()))
+}
+
+// poissonExactLimit specifies mean up to which poisson values are sampled exactly.
+// Normal approximation is used for greater means.
+const poissonExactLimit = 30
+
+// poisson distribution
+type poisson struct {
+	bounds
+	rand *rand.Rand
+	mean float64
+}
+
+func (d *poisson) Next() int {
+	if d.mean > poissonExactLimit {
+		return d.clamp(int(math.Round(d.rand.NormFloat64()*math.Sqrt(d.mean) + d.mean)))
+	}
+
+	// Knuth's algorithm
+	limit := math.Exp(-d.mean)
+	k := 0
+	for p := d.rand.Float64(); p > limit; p *= d.rand.Float64() {
+		k++
+	}
+	return d.clamp(k)
+}
+
+// drift is normal distribution which mean moves by drift with each value
+type drift struct {
+	normal
+	drift float64
+}
+
+func (d *drift) Next() int {
+	value := d.normal.Next()
+	d.mean += d.drift
+	if (d.mean > float64(d.max)) || (d.mean < float64(d.min)) {
+		d.drift = -d.drift
+		d.mean += 2 * d.drift
+	}
+	return value
+}
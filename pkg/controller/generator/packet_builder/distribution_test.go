@@ -0,0 +1,186 @@
+// Copyright 2024 Vladislav Klimenko. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package packetbuilder
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const samplesNum = 200000
+
+// moments calculates mean and variance of n samples of the distribution
+func moments(d Distribution, n int) (mean, variance float64) {
+	samples := make([]float64, n)
+	for i := range samples {
+		samples[i] = float64(d.Next())
+		mean += samples[i]
+	}
+	mean /= float64(n)
+	for _, sample := range samples {
+		variance += (sample - mean) * (sample - mean)
+	}
+	variance /= float64(n - 1)
+	return mean, variance
+}
+
+// zipfMoments calculates expected mean and variance of zipf distribution over [0, imax]
+func zipfMoments(s, v float64, imax int) (mean, variance float64) {
+	var norm, m1, m2 float64
+	for k := 0; k <= imax; k++ {
+		p := math.Pow(v+float64(k), -s)
+		norm += p
+		m1 += float64(k) * p
+		m2 += float64(k*k) * p
+	}
+	mean = m1 / norm
+	return mean, m2/norm - mean*mean
+}
+
+func TestDistributionMoments(t *testing.T) {
+	zipfMean, zipfVariance := zipfMoments(4, 1, 1000)
+	rate := 0.1
+	tests := []struct {
+		name     string
+		options  DistributionOptions
+		min      int
+		max      int
+		mean     float64
+		variance float64
+	}{
+		{
+			name:     "uniform",
+			options:  DistributionOptions{Kind: DistributionUniform},
+			min:      0,
+			max:      19,
+			mean:     9.5,
+			variance: (20*20 - 1) / 12.0,
+		},
+		{
+			name:    "normal",
+			options: DistributionOptions{Kind: DistributionNormal, Mean: Float(50), StdDev: Float(10)},
+			min:     0,
+			max:     100,
+			mean:    50,
+			// Rounding to integer adds variance of the uniform rounding error
+			variance: 100 + 1/12.0,
+		},
+		{
+			name:    "exponential",
+			options: DistributionOptions{Kind: DistributionExponential, Rate: Float(rate)},
+			min:     0,
+			max:     10000,
+			// Floor of exponential value is geometric
+			mean:     1 / (math.Exp(rate) - 1),
+			variance: math.Exp(rate) / ((math.Exp(rate) - 1) * (math.Exp(rate) - 1)),
+		},
+		{
+			name:     "zipf",
+			options:  DistributionOptions{Kind: DistributionZipf, ZipfS: Float(4), ZipfV: Float(1)},
+			min:      0,
+			max:      1000,
+			mean:     zipfMean,
+			variance: zipfVariance,
+		},
+		{
+			name:     "poisson",
+			options:  DistributionOptions{Kind: DistributionPoisson, Mean: Float(4)},
+			min:      0,
+			max:      100,
+			mean:     4,
+			variance: 4,
+		},
+		{
+			name:     "poisson approximated",
+			options:  DistributionOptions{Kind: DistributionPoisson, Mean: Float(400)},
+			min:      0,
+			max:      1000,
+			mean:     400,
+			variance: 400 + 1/12.0,
+		},
+	}
+	for _, tt := range tests {
+		d, err := NewDistribution(tt.options, tt.min, tt.max, rand.New(rand.NewSource(1)))
+		require.NoError(t, err, "Check distribution: %s", tt.name)
+		mean, variance := moments(d, samplesNum)
+		require.InEpsilon(t, tt.mean, mean, 0.02, "Check mean: %s", tt.name)
+		// Heavy tailed zipf has noisy sample variance
+		require.InEpsilon(t, tt.variance, variance, 0.1, "Check variance: %s", tt.name)
+	}
+}
+
+func TestDistributionDrift(t *testing.T) {
+	window := 1000
+	d, err := NewDistribution(DistributionOptions{Kind: DistributionDrift, Mean: Float(10), StdDev: Float(1), Drift: 0.01}, 0, 100, rand.New(rand.NewSource(1)))
+	require.NoError(t, err)
+	// Mean moves up by 10 with each window
+	for i := 0; i < 5; i++ {
+		mean, _ := moments(d, window)
+		require.InDelta(t, 10+0.01*float64(i*window+window/2), mean, 0.5, "Check drift window: %d", i)
+	}
+	// Mean bounces back from the max value
+	for i := 5; i < 9; i++ {
+		moments(d, window)
+	}
+	mean, _ := moments(d, window)
+	require.InDelta(t, 100-(0.01*float64(9*window+window/2)-90), mean, 1, "Check drift bounce")
+}
+
+func TestDistributionBounds(t *testing.T) {
+	kinds := []string{
+		DistributionUniform,
+		DistributionNormal,
+		DistributionExponential,
+		DistributionZipf,
+		DistributionPoisson,
+		DistributionDrift,
+	}
+	for _, kind := range kinds {
+		d, err := NewDistribution(DistributionOptions{Kind: kind, Drift: 1}, 5, 15, rand.New(rand.NewSource(1)))
+		require.NoError(t, err, "Check distribution: %s", kind)
+		for i := 0; i < 10000; i++ {
+			value := d.Next()
+			require.GreaterOrEqual(t, value, 5, "Check distribution bounds: %s", kind)
+			require.LessOrEqual(t, value, 15, "Check distribution bounds: %s", kind)
+		}
+	}
+}
+
+func TestDistributionZeroParams(t *testing.T) {
+	// Zero parameters are set explicitly rather than replaced with defaults
+	d, err := NewDistribution(DistributionOptions{Kind: DistributionNormal, Mean: Float(0), StdDev: Float(0)}, -10, 10, rand.New(rand.NewSource(1)))
+	require.NoError(t, err)
+	for i := 0; i < 100; i++ {
+		require.Equal(t, 0, d.Next())
+	}
+}
+
+func TestDistributionInvalid(t *testing.T) {
+	tests := []DistributionOptions{
+		{Kind: "unknown"},
+		{Kind: DistributionZipf, ZipfS: Float(0.5)},
+		{Kind: DistributionPoisson, Mean: Float(-1)},
+		{Kind: DistributionExponential, Rate: Float(-1)},
+		{Kind: DistributionExponential, Rate: Float(0)},
+		{Kind: DistributionNormal, StdDev: Float(-1)},
+	}
+	for _, tt := range tests {
+		_, err := NewDistribution(tt, 0, 10, rand.New(rand.NewSource(1)))
+		require.Error(t, err, "Check invalid distribution: %v", tt)
+	}
+}
@@ -17,46 +17,94 @@ package packetbuilder
 import (
 	"fmt"
 	"math/rand"
+	"time"
+
+	"github.com/sunsingerus/pipeline/pkg/controller/packet"
 )
 
-type Packet interface {
-	fmt.Stringer
-	Len() int
-	Set(int, int)
-}
+// DefaultValueMax specifies max value of a packet item, unless specified by the caller
+const DefaultValueMax = 19
 
-type packetConstructor func(int) Packet
+// PacketConstructor creates packet of the specified length
+type PacketConstructor func(int) packet.Packet
 
 // Options specifies generator options
 type Options struct {
-	// Size specifies size of generated packet
+	// Size specifies size of generated packet.
+	// In case SizeMax is greater than Size, Size specifies min size of generated packet.
 	Size int
+	// SizeMax specifies max size (inclusive) of generated packet
+	SizeMax int
+	// SizeDistribution specifies distribution of packet sizes within [Size, SizeMax] range
+	SizeDistribution DistributionOptions
+	// Seed specifies seed of the builder's own random source.
+	// Zero means seed is taken from the current time and packets are not reproducible.
+	Seed int64
+	// ValueMin specifies min value (inclusive) of a packet item
+	ValueMin int
+	// ValueMax specifies max value (inclusive) of a packet item, can not be less than ValueMin.
+	// Zero ValueMin and ValueMax mean all values are zero, defaults are applied by the caller.
+	ValueMax int
+	// Distribution specifies distribution of packet item values within [ValueMin, ValueMax] range
+	Distribution DistributionOptions
 }
 
 // PacketBuilder specifies packet builder
 type PacketBuilder struct {
-	packetConstructor packetConstructor
+	packetConstructor PacketConstructor
+	distribution      Distribution
+	// sizeDistribution is nil in case all packets are of the same size
+	sizeDistribution Distribution
 	Options
 }
 
-// New creates new packet builder from options
-func New(packetConstructor packetConstructor, opts Options) *PacketBuilder {
+// New creates new packet builder from options. Returns error in case value range or distributions are invalid
+func New(packetConstructor PacketConstructor, opts Options) (*PacketBuilder, error) {
+	if opts.ValueMax < opts.ValueMin {
+		return nil, fmt.Errorf("value max %d is less than value min %d", opts.ValueMax, opts.ValueMin)
+	}
+	seed := opts.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	rnd := rand.New(rand.NewSource(seed))
+	distribution, err := NewDistribution(opts.Distribution, opts.ValueMin, opts.ValueMax, rnd)
+	if err != nil {
+		return nil, err
+	}
+	var sizeDistribution Distribution
+	if opts.SizeMax > opts.Size {
+		sizeDistribution, err = NewDistribution(opts.SizeDistribution, opts.Size, opts.SizeMax, rnd)
+		if err != nil {
+			return nil, fmt.Errorf("size %w", err)
+		}
+	}
 	return &PacketBuilder{
 		packetConstructor: packetConstructor,
+		distribution:      distribution,
+		sizeDistribution:  sizeDistribution,
 		Options:           opts,
-	}
+	}, nil
 }
 
 // Build builds one packet
-func (b *PacketBuilder) Build() Packet {
+func (b *PacketBuilder) Build() packet.Packet {
 	if b == nil {
 		return nil
 	}
 
 	// Create randomly filled packet
-	packet := b.packetConstructor(b.Options.Size)
-	for i := 0; i < packet.Len(); i++ {
-		packet.Set(i, rand.Intn(20))
+	pack := b.packetConstructor(b.size())
+	for i := 0; i < pack.Len(); i++ {
+		pack.Set(i, b.distribution.Next())
+	}
+	return pack
+}
+
+// size returns size of the next packet
+func (b *PacketBuilder) size() int {
+	if b.sizeDistribution == nil {
+		return b.Options.Size
 	}
-	return packet
+	return b.sizeDistribution.Next()
 }
@@ -15,27 +15,41 @@
 package packetbuilder
 
 import (
-	"github.com/stretchr/testify/require"
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/stretchr/testify/require"
+
+	"github.com/sunsingerus/pipeline/pkg/controller/packet"
 	model "github.com/sunsingerus/pipeline/pkg/model/packet"
 )
 
+var update = flag.Bool("update", false, "update golden files")
+
 func TestPacketBuilder(t *testing.T) {
 
 	tests := []struct {
-		constructor packetConstructor
+		constructor PacketConstructor
 		options     Options
 		expect      int
 	}{
 		{
-			constructor: func(size int) Packet { return model.New(size) },
-			options:     Options{Size: 30},
+			constructor: func(size int) packet.Packet { return model.New(size) },
+			options:     Options{Size: 30, ValueMax: DefaultValueMax},
+			expect:      30,
+		},
+		{
+			constructor: func(size int) packet.Packet { return model.New(size) },
+			options:     Options{Size: 30, Seed: 42, ValueMax: DefaultValueMax},
 			expect:      30,
 		},
 	}
 	for _, tt := range tests {
-		builder := New(tt.constructor, tt.options)
+		builder, err := New(tt.constructor, tt.options)
+		require.NoError(t, err)
 		// Check two packets are of expected size but not equal
 		a := builder.Build()
 		b := builder.Build()
@@ -44,3 +58,143 @@ func TestPacketBuilder(t *testing.T) {
 		require.NotEqual(t, a.String(), b.String(), "Check packet builder: %s", tt.expect)
 	}
 }
+
+func TestPacketBuilderValueRange(t *testing.T) {
+	tests := []struct {
+		options Options
+		min     int
+		max     int
+	}{
+		{
+			options: Options{Size: 1000, Seed: 1, ValueMax: DefaultValueMax},
+			min:     0,
+			max:     DefaultValueMax,
+		},
+		{
+			options: Options{Size: 1000, Seed: 1, ValueMin: -5, ValueMax: 5},
+			min:     -5,
+			max:     5,
+		},
+		{
+			options: Options{Size: 1000, Seed: 1, ValueMin: 7, ValueMax: 7},
+			min:     7,
+			max:     7,
+		},
+		{
+			options: Options{Size: 1000, Seed: 1},
+			min:     0,
+			max:     0,
+		},
+	}
+	for _, tt := range tests {
+		builder, err := New(func(size int) packet.Packet { return model.New(size) }, tt.options)
+		require.NoError(t, err)
+		pack := builder.Build().(*model.Packet)
+		for _, value := range pack.Slice() {
+			require.GreaterOrEqual(t, value, tt.min, "Check value range: %v", tt.options)
+			require.LessOrEqual(t, value, tt.max, "Check value range: %v", tt.options)
+		}
+	}
+}
+
+func TestPacketBuilderInvalidValueRange(t *testing.T) {
+	tests := []Options{
+		{Size: 10, ValueMin: 5, ValueMax: 1},
+	}
+	for _, opts := range tests {
+		_, err := New(func(size int) packet.Packet { return model.New(size) }, opts)
+		require.Error(t, err, "Check invalid value range is rejected: %v", opts)
+	}
+}
+
+func TestPacketBuilderInvalidDistribution(t *testing.T) {
+	tests := []Options{
+		{Size: 10, ValueMax: DefaultValueMax, Distribution: DistributionOptions{Kind: "zpf"}},
+		{Size: 1, SizeMax: 10, ValueMax: DefaultValueMax, SizeDistribution: DistributionOptions{Kind: "zpf"}},
+	}
+	for _, opts := range tests {
+		_, err := New(func(size int) packet.Packet { return model.New(size) }, opts)
+		require.Error(t, err, "Check invalid distribution is rejected: %v", opts)
+	}
+}
+
+func TestPacketBuilderSizeRange(t *testing.T) {
+	tests := []struct {
+		options Options
+		min     int
+		max     int
+	}{
+		{
+			options: Options{Size: 5, SizeMax: 15, Seed: 1, ValueMax: DefaultValueMax},
+			min:     5,
+			max:     15,
+		},
+		{
+			options: Options{Size: 0, SizeMax: 3, Seed: 1, ValueMax: DefaultValueMax, SizeDistribution: DistributionOptions{Kind: DistributionPoisson, Mean: Float(1)}},
+			min:     0,
+			max:     3,
+		},
+		{
+			// SizeMax less than Size means fixed size
+			options: Options{Size: 7, SizeMax: 3, Seed: 1, ValueMax: DefaultValueMax},
+			min:     7,
+			max:     7,
+		},
+	}
+	for _, tt := range tests {
+		builder, err := New(func(size int) packet.Packet { return model.New(size) }, tt.options)
+		require.NoError(t, err)
+		sizes := make(map[int]bool)
+		for i := 0; i < 1000; i++ {
+			size := builder.Build().Len()
+			require.GreaterOrEqual(t, size, tt.min, "Check size range: %v", tt.options)
+			require.LessOrEqual(t, size, tt.max, "Check size range: %v", tt.options)
+			sizes[size] = true
+		}
+		require.Len(t, sizes, tt.max-tt.min+1, "Check all sizes are generated: %v", tt.options)
+	}
+}
+
+// stream builds n packets and renders them one per line
+func stream(opts Options, n int) []byte {
+	builder, _ := New(func(size int) packet.Packet { return model.New(size) }, opts)
+	var buf bytes.Buffer
+	for i := 0; i < n; i++ {
+		buf.WriteString(builder.Build().String())
+		buf.WriteString("\n")
+	}
+	return buf.Bytes()
+}
+
+func TestPacketBuilderGolden(t *testing.T) {
+	tests := []struct {
+		name    string
+		options Options
+	}{
+		{
+			name:    "seed_42",
+			options: Options{Size: 10, Seed: 42, ValueMax: DefaultValueMax},
+		},
+		{
+			name:    "seed_7_range",
+			options: Options{Size: 5, Seed: 7, ValueMin: 100, ValueMax: 199},
+		},
+		{
+			name:    "seed_3_sizes",
+			options: Options{Size: 1, SizeMax: 8, Seed: 3, ValueMax: DefaultValueMax},
+		},
+	}
+	for _, tt := range tests {
+		got := stream(tt.options, 20)
+		// Same seed and config have to produce byte-identical streams
+		require.Equal(t, got, stream(tt.options, 20), "Check reproducibility: %s", tt.name)
+
+		golden := filepath.Join("testdata", tt.name+".golden")
+		if *update {
+			require.NoError(t, os.WriteFile(golden, got, 0644))
+		}
+		expect, err := os.ReadFile(golden)
+		require.NoError(t, err)
+		require.Equal(t, string(expect), string(got), "Check golden stream: %s", tt.name)
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright 2024 Vladislav Klimenko. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package source
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// listen accepts connections on tcp address or unix socket and reads packets line by line from each of them
+func (s *Source) listen(ctx context.Context, network string) error {
+	listener, err := net.Listen(network, s.Options.Address)
+	if err != nil {
+		return err
+	}
+	log.Infof("Source [%s] - listening on %s", s.Options.Kind, listener.Addr())
+
+	// Unblock Accept and all connections on context done
+	conns := make(map[net.Conn]struct{})
+	mux := sync.Mutex{}
+	go func() {
+		<-ctx.Done()
+		_ = listener.Close()
+		mux.Lock()
+		defer mux.Unlock()
+		for conn := range conns {
+			_ = conn.Close()
+		}
+	}()
+
+	wg := sync.WaitGroup{}
+	defer wg.Wait()
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		mux.Lock()
+		conns[conn] = struct{}{}
+		mux.Unlock()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() {
+				mux.Lock()
+				delete(conns, conn)
+				mux.Unlock()
+				_ = conn.Close()
+			}()
+			if err := s.read(ctx, conn); (err != nil) && (ctx.Err() == nil) {
+				log.Warnf("Source [%s] - connection %s: %v", s.Options.Kind, conn.RemoteAddr(), err)
+			}
+		}()
+	}
+}
+
+// serveHTTP accepts packets as lines of POST request bodies
+func (s *Source) serveHTTP(ctx context.Context) error {
+	server := &http.Server{
+		Addr:    s.Options.Address,
+		Handler: http.HandlerFunc(s.handle),
+		BaseContext: func(net.Listener) context.Context {
+			return ctx
+		},
+	}
+	go func() {
+		<-ctx.Done()
+		_ = server.Close()
+	}()
+	log.Infof("Source [%s] - listening on %s", s.Options.Kind, s.Options.Address)
+	if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// handle handles one POST request with packets, one per line
+func (s *Source) handle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	defer r.Body.Close()
+
+	accepted, malformed := s.Stats.Accepted.Load(), s.Stats.Malformed.Load()
+	scanner := bufio.NewScanner(r.Body)
+	for scanner.Scan() {
+		if !s.line(r.Context(), scanner.Text()) {
+			http.Error(w, "source stopped", http.StatusServiceUnavailable)
+			return
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// Counters are shared by concurrent requests, so the numbers are approximate under concurrent load
+	w.WriteHeader(http.StatusAccepted)
+	_, _ = fmt.Fprintf(w, "accepted=%d malformed=%d\n", s.Stats.Accepted.Load()-accepted, s.Stats.Malformed.Load()-malformed)
+}
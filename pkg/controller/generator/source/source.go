@@ -0,0 +1,253 @@
+// Copyright 2024 Vladislav Klimenko. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package source
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/sunsingerus/pipeline/pkg/controller/packet"
+)
+
+// Available source kinds
+const (
+	KindStdin = "stdin"
+	KindFile  = "file"
+	KindTail  = "tail"
+	KindTCP   = "tcp"
+	KindUnix  = "unix"
+	KindHTTP  = "http"
+)
+
+// PacketConstructor creates packet of the specified length
+type PacketConstructor func(int) packet.Packet
+
+// Options specifies source options
+type Options struct {
+	// Kind specifies source kind, one of: stdin, file, tail, tcp, unix, http
+	Kind string
+	// Address specifies file path, network address or unix socket path depending on the kind
+	Address string
+	// RejectFile specifies path to a file where malformed lines are appended to. Empty means no reject file
+	RejectFile string
+	// PollInterval specifies how often tailed file is checked for new lines
+	PollInterval time.Duration
+}
+
+// Stats specifies source counters
+type Stats struct {
+	// Accepted counts lines accepted as packets
+	Accepted atomic.Int64
+	// Malformed counts lines which are not valid packets
+	Malformed atomic.Int64
+}
+
+// String returns string representation of the counters
+func (s *Stats) String() string {
+	if s == nil {
+		return ""
+	}
+	return fmt.Sprintf("accepted=%d malformed=%d", s.Accepted.Load(), s.Malformed.Load())
+}
+
+// Source specifies external source of packets.
+// Source plays packet builder role for the generator, while lines are read by the Run.
+type Source struct {
+	packetConstructor PacketConstructor
+	packets           chan packet.Packet
+	// done is closed when source stops
+	done      chan struct{}
+	reject    io.WriteCloser
+	rejectMux sync.Mutex
+	Stats
+	Options
+}
+
+// New creates new source from options
+func New(packetConstructor PacketConstructor, opts Options) (*Source, error) {
+	switch strings.ToLower(opts.Kind) {
+	case KindStdin, KindFile, KindTail, KindTCP, KindUnix, KindHTTP:
+	default:
+		return nil, fmt.Errorf("not a valid source: %q", opts.Kind)
+	}
+	if (opts.Address == "") && (strings.ToLower(opts.Kind) != KindStdin) {
+		return nil, fmt.Errorf("source %s requires address", opts.Kind)
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = 100 * time.Millisecond
+	}
+
+	s := &Source{
+		packetConstructor: packetConstructor,
+		packets:           make(chan packet.Packet),
+		done:              make(chan struct{}),
+		Options:           opts,
+	}
+	if opts.RejectFile != "" {
+		f, err := os.OpenFile(opts.RejectFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, err
+		}
+		s.reject = f
+	}
+	return s, nil
+}
+
+// Build returns next packet read from the source.
+// Blocks until packet is available. Returns nil in case source is stopped.
+func (s *Source) Build() packet.Packet {
+	if s == nil {
+		return nil
+	}
+	select {
+	case pack := <-s.packets:
+		return pack
+	case <-s.done:
+		return nil
+	}
+}
+
+// Run reads source until context is done
+func (s *Source) Run(ctx context.Context, wg *sync.WaitGroup) {
+	defer wg.Done()
+	if s == nil {
+		return
+	}
+	log.Infof("Source [%s] - start", s.Options.Kind)
+	defer log.Infof("Source [%s] - end", s.Options.Kind)
+	defer close(s.done)
+	defer s.closeReject()
+
+	var err error
+	switch strings.ToLower(s.Options.Kind) {
+	case KindStdin:
+		err = s.read(ctx, os.Stdin)
+	case KindFile:
+		err = s.readFile(ctx)
+	case KindTail:
+		err = s.tail(ctx)
+	case KindTCP, KindUnix:
+		err = s.listen(ctx, strings.ToLower(s.Options.Kind))
+	case KindHTTP:
+		err = s.serveHTTP(ctx)
+	}
+	if err != nil {
+		log.Errorf("Source [%s] - %v", s.Options.Kind, err)
+	}
+
+	// Source is exhausted, but packets already delivered are still in-flight. Stop with the pipeline
+	<-ctx.Done()
+	log.Infof("Source [%s] - done: %s", s.Options.Kind, &s.Stats)
+}
+
+func (s *Source) readFile(ctx context.Context) error {
+	f, err := os.Open(s.Options.Address)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return s.read(ctx, f)
+}
+
+// read reads packets line by line until EOF or context is done
+func (s *Source) read(ctx context.Context, r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if !s.line(ctx, scanner.Text()) {
+			return nil
+		}
+	}
+	return scanner.Err()
+}
+
+// line handles one line read from the source. Returns false in case context is done
+func (s *Source) line(ctx context.Context, line string) bool {
+	values, err := ParseLine(line)
+	switch {
+	case err != nil:
+		s.malformed(line, err)
+		return ctx.Err() == nil
+	case values == nil:
+		// Empty line
+		return ctx.Err() == nil
+	}
+
+	pack := s.packetConstructor(len(values))
+	for i, value := range values {
+		pack.Set(i, value)
+	}
+	select {
+	case <-ctx.Done():
+		return false
+	case s.packets <- pack:
+		s.Stats.Accepted.Add(1)
+		return true
+	}
+}
+
+func (s *Source) malformed(line string, err error) {
+	s.Stats.Malformed.Add(1)
+	log.Warnf("Source [%s] - malformed line %q: %v", s.Options.Kind, line, err)
+	if s.reject == nil {
+		return
+	}
+	s.rejectMux.Lock()
+	defer s.rejectMux.Unlock()
+	if _, err := fmt.Fprintln(s.reject, line); err != nil {
+		log.Errorf("Source [%s] - unable to write reject file: %v", s.Options.Kind, err)
+	}
+}
+
+func (s *Source) closeReject() {
+	if s.reject == nil {
+		return
+	}
+	s.rejectMux.Lock()
+	defer s.rejectMux.Unlock()
+	_ = s.reject.Close()
+}
+
+// ParseLine parses packet from the line of comma- and/or space-separated integers.
+// Returns nil values and no error in case line is empty.
+func ParseLine(line string) ([]int, error) {
+	fields := strings.FieldsFunc(line, func(r rune) bool {
+		return (r == ',') || (r == ' ') || (r == '\t') || (r == '\r')
+	})
+	if len(fields) == 0 {
+		if strings.Count(line, ",") > 0 {
+			return nil, fmt.Errorf("no values")
+		}
+		return nil, nil
+	}
+	values := make([]int, len(fields))
+	for i, field := range fields {
+		value, err := strconv.Atoi(field)
+		if err != nil {
+			return nil, fmt.Errorf("value %d: %w", i, err)
+		}
+		values[i] = value
+	}
+	return values, nil
+}
@@ -0,0 +1,146 @@
+// Copyright 2024 Vladislav Klimenko. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package source
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/sunsingerus/pipeline/pkg/controller/packet"
+	model "github.com/sunsingerus/pipeline/pkg/model/packet"
+)
+
+func newPacket(size int) packet.Packet {
+	return model.New(size)
+}
+
+func TestParseLine(t *testing.T) {
+	tests := []struct {
+		line   string
+		expect []int
+		err    bool
+	}{
+		{line: "1,2,3", expect: []int{1, 2, 3}},
+		{line: "1 2  3", expect: []int{1, 2, 3}},
+		{line: " 1, 2,\t-3\r", expect: []int{1, 2, -3}},
+		{line: "", expect: nil},
+		{line: "   ", expect: nil},
+		{line: ",,", err: true},
+		{line: "1,a,3", err: true},
+	}
+	for _, tt := range tests {
+		values, err := ParseLine(tt.line)
+		if tt.err {
+			require.Error(t, err, "Check line: %q", tt.line)
+			continue
+		}
+		require.NoError(t, err, "Check line: %q", tt.line)
+		require.Equal(t, tt.expect, values, "Check line: %q", tt.line)
+	}
+}
+
+// run runs source and returns function which stops it
+func run(t *testing.T, s *Source) func() {
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	go s.Run(ctx, wg)
+	return func() {
+		cancel()
+		wg.Wait()
+		require.Nil(t, s.Build(), "Check stopped source builds nothing")
+	}
+}
+
+func TestFileSource(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "input")
+	reject := filepath.Join(dir, "reject")
+	require.NoError(t, os.WriteFile(input, []byte("1,2,3\nbad line\n\n4 5\n"), 0644))
+
+	s, err := New(newPacket, Options{Kind: KindFile, Address: input, RejectFile: reject})
+	require.NoError(t, err)
+	stop := run(t, s)
+	require.Equal(t, "[1,2,3]", s.Build().String())
+	require.Equal(t, "[4,5]", s.Build().String())
+	stop()
+
+	require.Equal(t, int64(2), s.Stats.Accepted.Load())
+	require.Equal(t, int64(1), s.Stats.Malformed.Load())
+	rejected, err := os.ReadFile(reject)
+	require.NoError(t, err)
+	require.Equal(t, "bad line\n", string(rejected))
+}
+
+func TestTailSource(t *testing.T) {
+	input := filepath.Join(t.TempDir(), "input")
+	require.NoError(t, os.WriteFile(input, []byte("1\n"), 0644))
+
+	s, err := New(newPacket, Options{Kind: KindTail, Address: input, PollInterval: 10 * time.Millisecond})
+	require.NoError(t, err)
+	stop := run(t, s)
+	require.Equal(t, "[1]", s.Build().String())
+
+	f, err := os.OpenFile(input, os.O_WRONLY|os.O_APPEND, 0644)
+	require.NoError(t, err)
+	_, err = f.WriteString("2,")
+	require.NoError(t, err)
+	time.Sleep(50 * time.Millisecond)
+	_, err = f.WriteString("3\n")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	require.Equal(t, "[2,3]", s.Build().String())
+	stop()
+}
+
+func TestTCPSource(t *testing.T) {
+	// Find free port
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	address := listener.Addr().String()
+	require.NoError(t, listener.Close())
+
+	s, err := New(newPacket, Options{Kind: KindTCP, Address: address})
+	require.NoError(t, err)
+	stop := run(t, s)
+
+	var conn net.Conn
+	require.Eventually(t, func() bool {
+		conn, err = net.Dial("tcp", address)
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+	_, err = fmt.Fprint(conn, "7,8\nx\n9\n")
+	require.NoError(t, err)
+	require.Equal(t, "[7,8]", s.Build().String())
+	require.Equal(t, "[9]", s.Build().String())
+	require.NoError(t, conn.Close())
+	stop()
+	require.Equal(t, int64(1), s.Stats.Malformed.Load())
+}
+
+func TestInvalidSource(t *testing.T) {
+	_, err := New(newPacket, Options{Kind: "unknown"})
+	require.Error(t, err)
+	_, err = New(newPacket, Options{Kind: KindFile})
+	require.Error(t, err)
+}
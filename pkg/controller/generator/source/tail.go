@@ -0,0 +1,71 @@
+// Copyright 2024 Vladislav Klimenko. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package source
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// tail reads growing file until context is done. Truncated file is re-read from the beginning
+func (s *Source) tail(ctx context.Context) error {
+	f, err := os.Open(s.Options.Address)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+
+	var offset int64
+	var partial strings.Builder
+	reader := bufio.NewReader(f)
+	for {
+		chunk, err := reader.ReadString('\n')
+		offset += int64(len(chunk))
+		partial.WriteString(chunk)
+		switch {
+		case err == nil:
+			if !s.line(ctx, strings.TrimSuffix(partial.String(), "\n")) {
+				return nil
+			}
+			partial.Reset()
+			continue
+		case !errors.Is(err, io.EOF):
+			return err
+		}
+
+		// Reached the end of the file, wait for it to grow
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(s.Options.PollInterval):
+		}
+		if info, err := f.Stat(); (err == nil) && (info.Size() < offset) {
+			log.Infof("Source [%s] - file truncated, read from the beginning", s.Options.Kind)
+			if _, err := f.Seek(0, io.SeekStart); err != nil {
+				return err
+			}
+			offset = 0
+			partial.Reset()
+			reader.Reset(f)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+// Copyright 2024 Vladislav Klimenko. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package health
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Healthz returns HTTP handler, which responds ok as long as the process is alive
+func Healthz() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintln(w, "ok")
+	})
+}
+
+// Readyz returns HTTP handler, which responds ok once the pipeline is ready and service unavailable otherwise
+func Readyz(m *Monitor) http.Handler {
+	return check(m, func() error { return m.Ready() })
+}
+
+// Livez returns HTTP handler, which responds ok unless any stage is stalled and service unavailable otherwise
+func Livez(m *Monitor) http.Handler {
+	return check(m, func() error { return m.Live(time.Now()) })
+}
+
+// check returns HTTP handler of the check, which lists states of the stages after the check result
+func check(m *Monitor, check func() error) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := check(); err != nil {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = fmt.Fprintf(w, "fail: %v\n", err)
+		} else {
+			_, _ = fmt.Fprintln(w, "ok")
+		}
+		for _, state := range m.States() {
+			_, _ = fmt.Fprintf(w, "%s\n", &state)
+		}
+	})
+}
@@ -0,0 +1,233 @@
+// Copyright 2024 Vladislav Klimenko. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package health
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Defaults of the monitor
+const (
+	DefaultStallTimeout = 30 * time.Second
+	DefaultSource       = "generator"
+)
+
+// Options specifies health monitor options
+type Options struct {
+	// StallTimeout specifies how long stage with packets in flight may make no progress. Zero means default timeout
+	StallTimeout time.Duration
+	// Source specifies stage, which has to make progress for the pipeline to be ready. Empty means generator
+	Source string
+}
+
+// State specifies state of the stage reported by its heartbeats
+type State struct {
+	Name string
+	// Running specifies number of the stage workers running
+	Running int
+	// InFlight specifies number of packets the stage workers are busy with
+	InFlight int
+	// Sending specifies number of packets the stage workers wait to deliver to the next stage
+	Sending int
+	// Received counts packets the stage got busy with, LastReceived specifies time of the last of them
+	Received     int64
+	LastReceived time.Time
+	// Progress counts packets the stage is done with
+	Progress int64
+	// Last specifies time of the last heartbeat
+	Last time.Time
+}
+
+// String returns string representation of the stage state
+func (s *State) String() string {
+	return fmt.Sprintf("%s running=%d in-flight=%d sending=%d received=%d progress=%d last=%s", s.Name, s.Running, s.InFlight, s.Sending, s.Received, s.Progress, s.Last.Format(time.RFC3339Nano))
+}
+
+// Stall returns description of the stage stall
+func (s *State) Stall(now time.Time) string {
+	return fmt.Sprintf("stage %s made no progress for %s with %d packets in flight", s.Name, now.Sub(s.Last).Round(time.Second), s.InFlight)
+}
+
+// Monitor tracks heartbeats of the pipeline stages. Nil monitor tracks nothing.
+// Stage is stalled once it has packets in flight and makes no progress for the stall timeout,
+// idle stage waiting for packets is not stalled.
+type Monitor struct {
+	stages map[string]*State
+	// expected specifies stages, which have to run for the pipeline to be ready, in order of their registration
+	expected []string
+	mux      sync.Mutex
+	Options
+}
+
+// New creates new health monitor
+func New(opts Options) *Monitor {
+	if opts.StallTimeout <= 0 {
+		opts.StallTimeout = DefaultStallTimeout
+	}
+	if opts.Source == "" {
+		opts.Source = DefaultSource
+	}
+	return &Monitor{
+		stages:  make(map[string]*State),
+		Options: opts,
+	}
+}
+
+// Expect registers stages, which have to run for the pipeline to be ready
+func (m *Monitor) Expect(stages ...string) {
+	if m == nil {
+		return
+	}
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	for _, stage := range stages {
+		m.state(stage)
+		m.expected = append(m.expected, stage)
+	}
+}
+
+// state returns state of the stage, the state is created on the first call. Must be called under lock
+func (m *Monitor) state(stage string) *State {
+	state, ok := m.stages[stage]
+	if !ok {
+		state = &State{Name: stage}
+		m.stages[stage] = state
+	}
+	return state
+}
+
+// beat updates state of the stage with heartbeat
+func (m *Monitor) beat(stage string, update func(state *State)) {
+	if m == nil {
+		return
+	}
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	state := m.state(stage)
+	update(state)
+	state.Last = time.Now()
+}
+
+// Start reports stage worker started
+func (m *Monitor) Start(stage string) {
+	m.beat(stage, func(state *State) { state.Running++ })
+}
+
+// Stop reports stage worker stopped
+func (m *Monitor) Stop(stage string) {
+	m.beat(stage, func(state *State) { state.Running-- })
+}
+
+// Begin reports stage worker got busy with a packet
+func (m *Monitor) Begin(stage string) {
+	m.beat(stage, func(state *State) {
+		state.InFlight++
+		state.Received++
+		state.LastReceived = time.Now()
+	})
+}
+
+// End reports stage worker is done with a packet
+func (m *Monitor) End(stage string) {
+	m.beat(stage, func(state *State) {
+		state.InFlight--
+		state.Progress++
+	})
+}
+
+// BeginSend reports stage worker started to deliver a packet to the next stage
+func (m *Monitor) BeginSend(stage string) {
+	m.beat(stage, func(state *State) { state.Sending++ })
+}
+
+// EndSend reports stage worker is done delivering a packet, delivered or not
+func (m *Monitor) EndSend(stage string) {
+	m.beat(stage, func(state *State) { state.Sending-- })
+}
+
+// Ready returns error in case not all expected stages run or the source has made no progress yet
+func (m *Monitor) Ready() error {
+	if m == nil {
+		return fmt.Errorf("pipeline is not running")
+	}
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	var reasons []string
+	for _, stage := range m.expected {
+		if m.stages[stage].Running <= 0 {
+			reasons = append(reasons, fmt.Sprintf("stage %s is not running", stage))
+		}
+	}
+	if source := m.state(m.Options.Source); source.Progress <= 0 {
+		reasons = append(reasons, fmt.Sprintf("stage %s has delivered no packets", source.Name))
+	}
+	if len(reasons) > 0 {
+		return fmt.Errorf("%s", strings.Join(reasons, ", "))
+	}
+	return nil
+}
+
+// Live returns error in case any stage is stalled at the moment
+func (m *Monitor) Live(now time.Time) error {
+	if m == nil {
+		return nil
+	}
+	var reasons []string
+	for _, state := range m.Stalled(now) {
+		reasons = append(reasons, state.Stall(now))
+	}
+	if len(reasons) > 0 {
+		return fmt.Errorf("%s", strings.Join(reasons, ", "))
+	}
+	return nil
+}
+
+// Stalled returns states of the stages stalled at the moment, in order of States
+func (m *Monitor) Stalled(now time.Time) []State {
+	if m == nil {
+		return nil
+	}
+	var stalled []State
+	for _, state := range m.States() {
+		if (state.InFlight > 0) && (now.Sub(state.Last) > m.Options.StallTimeout) {
+			stalled = append(stalled, state)
+		}
+	}
+	return stalled
+}
+
+// States returns states of all stages, the expected ones first in order of their registration
+func (m *Monitor) States() []State {
+	if m == nil {
+		return nil
+	}
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	var states []State
+	listed := make(map[string]bool)
+	for _, stage := range m.expected {
+		states = append(states, *m.stages[stage])
+		listed[stage] = true
+	}
+	for name, state := range m.stages {
+		if !listed[name] {
+			states = append(states, *state)
+		}
+	}
+	return states
+}
@@ -0,0 +1,97 @@
+// Copyright 2024 Vladislav Klimenko. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package health
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReady(t *testing.T) {
+	var nilMonitor *Monitor
+	require.Error(t, nilMonitor.Ready(), "Check pipeline is not ready before it runs")
+	require.NoError(t, nilMonitor.Live(time.Now()))
+
+	m := New(Options{})
+	m.Expect(DefaultSource, "topn", "accum")
+	require.EqualError(t, m.Ready(), "stage generator is not running, stage topn is not running, stage accum is not running, stage generator has delivered no packets")
+
+	m.Start(DefaultSource)
+	m.Start("topn")
+	m.Start("topn")
+	m.Start("accum")
+	require.EqualError(t, m.Ready(), "stage generator has delivered no packets")
+
+	m.Begin(DefaultSource)
+	m.End(DefaultSource)
+	require.NoError(t, m.Ready())
+
+	m.Stop("topn")
+	require.NoError(t, m.Ready(), "Check stage is running while any of its workers runs")
+	m.Stop("topn")
+	require.EqualError(t, m.Ready(), "stage topn is not running")
+}
+
+func TestLive(t *testing.T) {
+	m := New(Options{StallTimeout: time.Second})
+	m.Expect("topn")
+	m.Start("topn")
+	m.Start("dedupe")
+	require.NoError(t, m.Live(time.Now().Add(time.Hour)), "Check idle stages are not stalled")
+
+	m.Begin("topn")
+	m.Begin("topn")
+	m.Begin("dedupe")
+	m.End("dedupe")
+	require.NoError(t, m.Live(time.Now()))
+	err := m.Live(time.Now().Add(time.Minute))
+	require.Error(t, err)
+	require.True(t, strings.HasPrefix(err.Error(), "stage topn made no progress for 1m0s with 2 packets in flight"), err.Error())
+
+	states := m.States()
+	require.Len(t, states, 2)
+	require.Equal(t, State{Name: "topn", Running: 1, InFlight: 2, Received: 2, LastReceived: states[0].LastReceived, Last: states[0].Last}, states[0])
+	require.Equal(t, int64(1), states[1].Progress)
+}
+
+func TestHandlers(t *testing.T) {
+	m := New(Options{StallTimeout: time.Nanosecond})
+	m.Expect(DefaultSource)
+
+	serve := func(handler http.Handler) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		return w
+	}
+	require.Equal(t, http.StatusOK, serve(Healthz()).Code)
+	w := serve(Readyz(m))
+	require.Equal(t, http.StatusServiceUnavailable, w.Code)
+	require.True(t, strings.HasPrefix(w.Body.String(), "fail: stage generator is not running"), w.Body.String())
+	require.Contains(t, w.Body.String(), "\ngenerator running=0 in-flight=0 sending=0 received=0 progress=0 ")
+	require.Equal(t, http.StatusOK, serve(Livez(m)).Code)
+
+	m.Start(DefaultSource)
+	m.Begin(DefaultSource)
+	time.Sleep(time.Millisecond)
+	require.Equal(t, http.StatusServiceUnavailable, serve(Livez(m)).Code, "Check stalled stage fails liveness")
+	m.End(DefaultSource)
+	require.Equal(t, http.StatusOK, serve(Readyz(m)).Code)
+	require.Equal(t, http.StatusOK, serve(Livez(m)).Code)
+}
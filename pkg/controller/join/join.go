@@ -0,0 +1,318 @@
+// Copyright 2024 Vladislav Klimenko. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package join
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/sunsingerus/pipeline/pkg/controller/dlq"
+	"github.com/sunsingerus/pipeline/pkg/controller/metrics"
+	"github.com/sunsingerus/pipeline/pkg/controller/packet"
+	"github.com/sunsingerus/pipeline/pkg/controller/stage"
+	"github.com/sunsingerus/pipeline/pkg/logger"
+)
+
+// Available join modes
+const (
+	// ModeSequence pairs packets with the same sequence number
+	ModeSequence = "sequence"
+	// ModeWindow pairs packets arrived within the time window of each other
+	ModeWindow = "window"
+)
+
+// UnmatchedPolicy specifies how packets with no pair are handled
+type UnmatchedPolicy string
+
+// Available unmatched policies
+const (
+	// UnmatchedDrop drops packet
+	UnmatchedDrop UnmatchedPolicy = "drop"
+	// UnmatchedPass delivers packet alone
+	UnmatchedPass UnmatchedPolicy = "pass"
+	// UnmatchedDeadLetter sends packet to the dead-letter sink
+	UnmatchedDeadLetter UnmatchedPolicy = "deadletter"
+)
+
+// Defaults
+const (
+	// DefaultMaxPending specifies max number of packets waiting for a pair on each side
+	DefaultMaxPending = 1000
+	// DefaultWindow specifies time window of window mode
+	DefaultWindow = 100 * time.Millisecond
+)
+
+// Stage specifies name of the stage join reports to the dead-letter sink
+const Stage = "join"
+
+// Sides of the join, dead-lettered packets report side as the worker id
+const (
+	Left  = 0
+	Right = 1
+)
+
+// Options specifies join options
+type Options struct {
+	// Mode specifies how packets are paired. Empty mode means sequence
+	Mode string
+	// Window specifies max time packet waits for a pair. Zero means no limit in sequence mode and DefaultWindow in window mode
+	Window time.Duration
+	// MaxPending specifies max number of packets waiting for a pair on each side, the oldest packet is unmatched on exceeding it
+	MaxPending int
+	// Unmatched specifies how packets with no pair are handled. Empty policy means drop.
+	Unmatched UnmatchedPolicy
+}
+
+// Pipes specifies channels join reads packets from and delivers joined packets to
+type Pipes struct {
+	Left  chan packet.Packet
+	Right chan packet.Packet
+	Out   chan packet.Packet
+	// DeadLetter receives unmatched packets in case of dead-letter policy
+	DeadLetter dlq.Sink
+}
+
+// PacketConstructor creates joined packet
+type PacketConstructor func([]int) packet.Packet
+
+// Join pairs packets of two streams. Joined packet contains values of the left packet followed by values of the right one.
+// Delivered packets are numbered starting from 1
+type Join struct {
+	packetConstructor PacketConstructor
+	stats             *metrics.Stage
+	state             *state
+	// sequence specifies id of the last delivered packet
+	sequence uint64
+	log      *logger.Entry
+	Pipes
+	Options
+}
+
+// New creates new join. Stats can be nil
+func New(packetConstructor PacketConstructor, stats *metrics.Stage, pipes Pipes, opts Options) (*Join, error) {
+	switch opts.Mode {
+	case "":
+		opts.Mode = ModeSequence
+	case ModeSequence, ModeWindow:
+	default:
+		return nil, fmt.Errorf("unknown join mode %q", opts.Mode)
+	}
+	switch opts.Unmatched {
+	case "":
+		opts.Unmatched = UnmatchedDrop
+	case UnmatchedDrop, UnmatchedPass, UnmatchedDeadLetter:
+	default:
+		return nil, fmt.Errorf("unknown unmatched policy %q", opts.Unmatched)
+	}
+	if (opts.Mode == ModeWindow) && (opts.Window <= 0) {
+		opts.Window = DefaultWindow
+	}
+	if opts.MaxPending <= 0 {
+		opts.MaxPending = DefaultMaxPending
+	}
+	if stats == nil {
+		stats = metrics.NewStage(Stage)
+	}
+	return &Join{
+		packetConstructor: packetConstructor,
+		stats:             stats,
+		state:             newState(opts),
+		log:               logger.Component(Stage),
+		Pipes:             pipes,
+		Options:           opts,
+	}, nil
+}
+
+// Run runs join until context is done
+func (j *Join) Run(ctx context.Context, wg *sync.WaitGroup) {
+	defer wg.Done()
+	if j == nil {
+		return
+	}
+	j.log.Info("start")
+	defer j.log.Info("end")
+
+	// Pending packets are checked for expiry a few times per window
+	var expiry <-chan time.Time
+	if j.Options.Window > 0 {
+		ticker := time.NewTicker(j.Options.Window / 4)
+		defer ticker.Stop()
+		expiry = ticker.C
+	}
+	for {
+		var unmatched []entry
+		var joined packet.Packet
+		select {
+		case <-ctx.Done():
+			j.log.Info("done")
+			return
+		case pack := <-j.Pipes.Left:
+			j.trace(Left, pack)
+			joined, unmatched = j.put(Left, pack, time.Now())
+		case pack := <-j.Pipes.Right:
+			j.trace(Right, pack)
+			joined, unmatched = j.put(Right, pack, time.Now())
+		case now := <-expiry:
+			unmatched = j.state.expire(now)
+		}
+		for _, e := range unmatched {
+			if !j.unmatched(ctx, e) {
+				return
+			}
+		}
+		if (joined != nil) && !j.deliver(ctx, joined) {
+			return
+		}
+	}
+}
+
+// put puts packet into the join state. Returns joined packet, if a pair is found, and packets left with no pair
+func (j *Join) put(side int, pack packet.Packet, now time.Time) (packet.Packet, []entry) {
+	j.stats.Received.Add(1)
+	pair, unmatched := j.state.put(entry{side: side, pack: pack, at: now})
+	if pair == nil {
+		return nil, unmatched
+	}
+	j.stats.Counter("matched").Add(1)
+	left, right := pair[Left], pair[Right]
+	values := make([]int, 0, left.Len()+right.Len())
+	values = append(values, left.Slice()...)
+	values = append(values, right.Slice()...)
+	return j.packetConstructor(values), unmatched
+}
+
+// unmatched handles packet left with no pair. Returns false in case context is done
+func (j *Join) unmatched(ctx context.Context, e entry) bool {
+	j.stats.Counter("unmatched").Add(1)
+	switch j.Options.Unmatched {
+	case UnmatchedPass:
+		return j.deliver(ctx, j.packetConstructor(e.pack.Slice()))
+	case UnmatchedDeadLetter:
+		j.stats.Rejected.Add(1)
+		j.log.WithField(logger.FieldPacketID, e.pack.ID()).Warnf("rejected: %s %s", side(e.side), e.pack)
+		if j.Pipes.DeadLetter != nil {
+			j.Pipes.DeadLetter.Put(dlq.NewEntry(Stage, e.side, fmt.Errorf("no pair"), e.pack.Slice()))
+		}
+	default:
+		j.stats.Dropped.Add(1)
+		if j.log.Logger.IsLevelEnabled(log.DebugLevel) {
+			j.log.WithField(logger.FieldPacketID, e.pack.ID()).Debugf("dropped: %s %s", side(e.side), e.pack)
+		}
+	}
+	return true
+}
+
+// trace logs packet received from the side
+func (j *Join) trace(_side int, pack packet.Packet) {
+	if j.log.Logger.IsLevelEnabled(log.TraceLevel) {
+		j.log.WithField(logger.FieldPacketID, pack.ID()).Tracef("received %s: %s", side(_side), pack)
+	}
+}
+
+// deliver numbers and delivers packet. Returns false in case context is done
+func (j *Join) deliver(ctx context.Context, pack packet.Packet) bool {
+	j.sequence++
+	pack.SetID(j.sequence)
+	// Joined packet is not ours to read once delivered, so it is described beforehand for debug logs
+	entry, str := j.log, ""
+	if j.log.Logger.IsLevelEnabled(log.DebugLevel) {
+		entry, str = j.log.WithField(logger.FieldPacketID, j.sequence), pack.String()
+	}
+	if !stage.Deliver(ctx, j.Pipes.Out, pack) {
+		entry.Debugf("NODELIVERY: %s", str)
+		return false
+	}
+	j.stats.Delivered.Add(1)
+	entry.Debugf("delivered: %s", str)
+	return true
+}
+
+// side returns name of the side
+func side(side int) string {
+	if side == Left {
+		return "left"
+	}
+	return "right"
+}
+
+// entry specifies packet waiting for a pair
+type entry struct {
+	side int
+	pack packet.Packet
+	// at specifies when packet was received
+	at time.Time
+}
+
+// state specifies packets waiting for a pair, in order of their arrival
+type state struct {
+	mode       string
+	window     time.Duration
+	maxPending int
+	pending    [2][]entry
+}
+
+func newState(opts Options) *state {
+	return &state{
+		mode:       opts.Mode,
+		window:     opts.Window,
+		maxPending: opts.MaxPending,
+	}
+}
+
+// put pairs packet with a pending packet of the other side or makes it pending.
+// Returns pair indexed by side, if found, and packets left with no pair
+func (s *state) put(e entry) (*[2]packet.Packet, []entry) {
+	unmatched := s.expire(e.at)
+
+	other := 1 - e.side
+	for i, candidate := range s.pending[other] {
+		// In window mode the oldest unexpired packet of the other side is the pair
+		if (s.mode == ModeWindow) || (candidate.pack.ID() == e.pack.ID()) {
+			s.pending[other] = append(s.pending[other][:i], s.pending[other][i+1:]...)
+			var pair [2]packet.Packet
+			pair[e.side] = e.pack
+			pair[other] = candidate.pack
+			return &pair, unmatched
+		}
+	}
+
+	s.pending[e.side] = append(s.pending[e.side], e)
+	if len(s.pending[e.side]) > s.maxPending {
+		unmatched = append(unmatched, s.pending[e.side][0])
+		s.pending[e.side] = s.pending[e.side][1:]
+	}
+	return nil, unmatched
+}
+
+// expire returns packets waited for a pair longer than the window, if any
+func (s *state) expire(now time.Time) []entry {
+	if s.window <= 0 {
+		return nil
+	}
+	var expired []entry
+	for side := range s.pending {
+		n := 0
+		for (n < len(s.pending[side])) && (now.Sub(s.pending[side][n].at) > s.window) {
+			n++
+		}
+		expired = append(expired, s.pending[side][:n]...)
+		s.pending[side] = s.pending[side][n:]
+	}
+	return expired
+}
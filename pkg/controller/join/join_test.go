@@ -0,0 +1,160 @@
+// Copyright 2024 Vladislav Klimenko. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package join
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/sunsingerus/pipeline/pkg/controller/dlq"
+	"github.com/sunsingerus/pipeline/pkg/controller/metrics"
+	"github.com/sunsingerus/pipeline/pkg/controller/packet"
+	model "github.com/sunsingerus/pipeline/pkg/model/packet"
+)
+
+func newPacket(id uint64, values ...int) packet.Packet {
+	pack := model.New(values)
+	pack.SetID(id)
+	return pack
+}
+
+func TestStateSequence(t *testing.T) {
+	s := newState(Options{Mode: ModeSequence, MaxPending: 2})
+	start := time.Now()
+
+	pair, unmatched := s.put(entry{side: Left, pack: newPacket(1, 1), at: start})
+	require.Nil(t, pair)
+	require.Empty(t, unmatched)
+	pair, _ = s.put(entry{side: Left, pack: newPacket(2, 2), at: start})
+	require.Nil(t, pair)
+
+	pair, _ = s.put(entry{side: Right, pack: newPacket(2, 20), at: start})
+	require.NotNil(t, pair, "Check packets with the same sequence number are paired")
+	require.Equal(t, "[2]", pair[Left].String())
+	require.Equal(t, "[20]", pair[Right].String())
+	require.Len(t, s.pending[Left], 1)
+	require.Empty(t, s.pending[Right])
+
+	// State is bounded, the oldest packet is unmatched
+	s.put(entry{side: Left, pack: newPacket(3, 3), at: start})
+	_, unmatched = s.put(entry{side: Left, pack: newPacket(4, 4), at: start})
+	require.Len(t, unmatched, 1)
+	require.Equal(t, uint64(1), unmatched[0].pack.ID())
+	require.Len(t, s.pending[Left], 2)
+
+	// No window means no expiry
+	require.Empty(t, s.expire(start.Add(time.Hour)))
+}
+
+func TestStateWindow(t *testing.T) {
+	s := newState(Options{Mode: ModeWindow, Window: 10 * time.Millisecond, MaxPending: 10})
+	start := time.Now()
+
+	s.put(entry{side: Left, pack: newPacket(1, 1), at: start})
+	s.put(entry{side: Left, pack: newPacket(2, 2), at: start.Add(8 * time.Millisecond)})
+
+	// The oldest packet within the window is the pair, regardless of its sequence number
+	pair, unmatched := s.put(entry{side: Right, pack: newPacket(7, 70), at: start.Add(9 * time.Millisecond)})
+	require.Empty(t, unmatched)
+	require.Equal(t, "[1]", pair[Left].String())
+
+	// Packet out of the window expires
+	pair, unmatched = s.put(entry{side: Right, pack: newPacket(8, 80), at: start.Add(20 * time.Millisecond)})
+	require.Nil(t, pair)
+	require.Len(t, unmatched, 1)
+	require.Equal(t, "[2]", unmatched[0].pack.String())
+
+	expired := s.expire(start.Add(40 * time.Millisecond))
+	require.Len(t, expired, 1)
+	require.Equal(t, Right, expired[0].side)
+	require.Empty(t, s.pending[Left])
+	require.Empty(t, s.pending[Right])
+}
+
+func TestJoin(t *testing.T) {
+	tests := []struct {
+		policy     UnmatchedPolicy
+		expect     []string
+		deadLetter int
+	}{
+		{
+			policy: UnmatchedDrop,
+			expect: []string{"[1,10]", "[3,30]"},
+		},
+		{
+			policy: UnmatchedPass,
+			expect: []string{"[1,10]", "[2]", "[3,30]"},
+		},
+		{
+			policy:     UnmatchedDeadLetter,
+			expect:     []string{"[1,10]", "[3,30]"},
+			deadLetter: 1,
+		},
+	}
+	for _, tt := range tests {
+		pipes := Pipes{
+			Left:       make(chan packet.Packet),
+			Right:      make(chan packet.Packet),
+			Out:        make(chan packet.Packet),
+			DeadLetter: dlq.NewRing(10, ""),
+		}
+		stats := metrics.NewStage(Stage)
+		join, err := New(func(values []int) packet.Packet { return model.New(values) }, stats, pipes, Options{MaxPending: 1, Unmatched: tt.policy})
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		wg := &sync.WaitGroup{}
+		wg.Add(1)
+		go join.Run(ctx, wg)
+
+		var results []string
+		collect := func(n int) {
+			for i := 0; i < n; i++ {
+				pack := <-pipes.Out
+				require.Equal(t, uint64(len(results)+1), pack.ID(), "Check joined packets are numbered")
+				results = append(results, pack.String())
+			}
+		}
+		pipes.Left <- newPacket(1, 1)
+		pipes.Right <- newPacket(1, 10)
+		collect(1)
+		pipes.Left <- newPacket(2, 2)
+		// Left packet #2 is unmatched, as only one packet may wait for a pair
+		pipes.Left <- newPacket(3, 3)
+		if tt.policy == UnmatchedPass {
+			collect(1)
+		}
+		pipes.Right <- newPacket(3, 30)
+		collect(1)
+		cancel()
+		wg.Wait()
+
+		require.Equal(t, tt.expect, results, "Check policy: %s", tt.policy)
+		require.Len(t, pipes.DeadLetter.(*dlq.Ring).List(), tt.deadLetter)
+		require.Equal(t, int64(2), stats.Counter("matched").Load())
+		require.Equal(t, int64(1), stats.Counter("unmatched").Load())
+	}
+}
+
+func TestNewInvalid(t *testing.T) {
+	_, err := New(nil, nil, Pipes{}, Options{Mode: "hash"})
+	require.Error(t, err)
+	_, err = New(nil, nil, Pipes{}, Options{Unmatched: "keep"})
+	require.Error(t, err)
+}
@@ -0,0 +1,179 @@
+// Copyright 2024 Vladislav Klimenko. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Stage specifies counters of a pipeline stage shared by all its workers
+type Stage struct {
+	name string
+	// Received counts packets received by the stage
+	Received atomic.Int64
+	// Delivered counts packets delivered to the next stage
+	Delivered atomic.Int64
+	// Filtered counts packets filtered out by the stage
+	Filtered atomic.Int64
+	// Dropped counts packets dropped because the next stage was not ready to accept them
+	Dropped atomic.Int64
+	// Rejected counts packets sent to the dead-letter sink
+	Rejected atomic.Int64
+	// TimedOut counts packets exceeded processing deadline
+	TimedOut atomic.Int64
+	// Slow counts packets logged as slow
+	Slow atomic.Int64
+	// Busy specifies number of the stage workers processing a packet at the moment
+	Busy atomic.Int64
+
+	// custom specifies stage-specific counters in order of their creation
+	custom    []string
+	customMap map[string]*atomic.Int64
+	mux       sync.RWMutex
+}
+
+// NewStage creates new stage metrics
+func NewStage(name string) *Stage {
+	return &Stage{
+		name:      name,
+		customMap: make(map[string]*atomic.Int64),
+	}
+}
+
+// Name returns name of the stage
+func (s *Stage) Name() string {
+	if s == nil {
+		return ""
+	}
+	return s.name
+}
+
+// Counter returns stage-specific counter, the counter is created on the first call
+func (s *Stage) Counter(name string) *atomic.Int64 {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if counter, ok := s.customMap[name]; ok {
+		return counter
+	}
+	counter := &atomic.Int64{}
+	s.custom = append(s.custom, name)
+	s.customMap[name] = counter
+	return counter
+}
+
+// Snapshot returns values of all counters by their names
+func (s *Stage) Snapshot() map[string]int64 {
+	snapshot := map[string]int64{
+		"received":  s.Received.Load(),
+		"delivered": s.Delivered.Load(),
+		"filtered":  s.Filtered.Load(),
+		"dropped":   s.Dropped.Load(),
+		"rejected":  s.Rejected.Load(),
+		"timed-out": s.TimedOut.Load(),
+		"slow":      s.Slow.Load(),
+	}
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+	for name, counter := range s.customMap {
+		snapshot[name] = counter.Load()
+	}
+	return snapshot
+}
+
+// String returns string representation of the counters
+func (s *Stage) String() string {
+	if s == nil {
+		return ""
+	}
+	var str strings.Builder
+	fmt.Fprintf(&str, "received=%d delivered=%d filtered=%d dropped=%d rejected=%d timed-out=%d slow=%d",
+		s.Received.Load(), s.Delivered.Load(), s.Filtered.Load(), s.Dropped.Load(), s.Rejected.Load(), s.TimedOut.Load(), s.Slow.Load())
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+	for _, name := range s.custom {
+		fmt.Fprintf(&str, " %s=%d", name, s.customMap[name].Load())
+	}
+	return str.String()
+}
+
+// Registry specifies metrics of all stages of the pipeline
+type Registry struct {
+	stages []*Stage
+	index  map[string]*Stage
+	mux    sync.RWMutex
+}
+
+// NewRegistry creates new registry
+func NewRegistry() *Registry {
+	return &Registry{
+		index: make(map[string]*Stage),
+	}
+}
+
+// Stage returns metrics of the stage, metrics are created on the first call
+func (r *Registry) Stage(name string) *Stage {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	if stage, ok := r.index[name]; ok {
+		return stage
+	}
+	stage := NewStage(name)
+	r.stages = append(r.stages, stage)
+	r.index[name] = stage
+	return stage
+}
+
+// Stages returns metrics of all stages in order of their creation
+func (r *Registry) Stages() []*Stage {
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+
+	return append([]*Stage(nil), r.stages...)
+}
+
+// StageStats specifies state of the pipeline stage at the moment
+type StageStats struct {
+	Name string `json:"name"`
+	// Counters specifies values of the stage counters by their names
+	Counters map[string]int64 `json:"counters"`
+	// Queue specifies number of packets waiting in the stage input channel of Capacity
+	Queue    int `json:"queue"`
+	Capacity int `json:"capacity"`
+	// Workers specifies number of the stage workers, Busy of them are processing a packet. Zero means stage has no pool
+	Workers int `json:"workers"`
+	Busy    int `json:"busy"`
+}
+
+// PipelineStats specifies state of the pipeline at the moment
+type PipelineStats struct {
+	Time   time.Time    `json:"time"`
+	Stages []StageStats `json:"stages"`
+	// Accum specifies value of the accumulator
+	Accum int `json:"accum"`
+}
+
+// Stats returns state of the stage counters, which has no pool
+func (s *Stage) Stats() StageStats {
+	return StageStats{
+		Name:     s.Name(),
+		Counters: s.Snapshot(),
+	}
+}
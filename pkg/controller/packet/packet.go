@@ -18,6 +18,9 @@ import "fmt"
 
 type Packet interface {
 	fmt.Stringer
+	// ID returns sequence number of the packet assigned by the generator
+	ID() uint64
+	SetID(uint64)
 	Slice(...int) []int
 	Len() int
 	Set(int, int)
@@ -0,0 +1,217 @@
+// Copyright 2024 Vladislav Klimenko. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pool
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sunsingerus/pipeline/pkg/controller/packet"
+	"github.com/sunsingerus/pipeline/pkg/logger"
+)
+
+// GapPolicy specifies how ordered pool handles a missing packet
+type GapPolicy string
+
+// Available gap policies
+const (
+	// GapWait waits for the missing packet until reorder buffer is full
+	GapWait GapPolicy = "wait"
+	// GapSkip waits for the missing packet until gap timeout or until reorder buffer is full
+	GapSkip GapPolicy = "skip"
+)
+
+// Defaults
+const (
+	DefaultReorderBuffer = 1000
+	DefaultGapTimeout    = 100 * time.Millisecond
+)
+
+// Stats specifies pool counters
+type Stats struct {
+	// Skipped counts missing packets given up on
+	Skipped atomic.Int64
+	// Late counts packets arrived after they were given up on. Late packets are delivered out of order
+	Late atomic.Int64
+}
+
+// String returns string representation of the counters
+func (s *Stats) String() string {
+	if s == nil {
+		return ""
+	}
+	return fmt.Sprintf("skipped=%d late=%d", s.Skipped.Load(), s.Late.Load())
+}
+
+// reorder specifies reorder buffer, which releases packets in the order of their ids.
+// Packet ids are expected to start from 1.
+type reorder struct {
+	// next specifies id of the packet to be released next
+	next uint64
+	// packets specifies buffered packets by their ids, nil packet marks id of the packet, which is not to be delivered
+	packets map[uint64]packet.Packet
+	size    int
+	stats   *Stats
+	log     *logger.Entry
+}
+
+func newReorder(size int, stats *Stats, log *logger.Entry) *reorder {
+	return &reorder{
+		next:    1,
+		packets: make(map[uint64]packet.Packet),
+		size:    size,
+		stats:   stats,
+		log:     log,
+	}
+}
+
+// put puts packet into the buffer and returns packets ready to be released
+func (r *reorder) put(pack packet.Packet) []packet.Packet {
+	if pack.ID() < r.next {
+		r.stats.Late.Add(1)
+		r.log.WithField(logger.FieldPacketID, pack.ID()).Warn("late packet")
+		return []packet.Packet{pack}
+	}
+	r.packets[pack.ID()] = pack
+	ready := r.release()
+	if len(r.packets) >= r.size {
+		// Buffer is full, give up on the gap
+		ready = append(ready, r.skip()...)
+	}
+	return ready
+}
+
+// forget marks id of the packet, which is not to be delivered, and returns packets ready to be released
+func (r *reorder) forget(id uint64) []packet.Packet {
+	if id < r.next {
+		// Packet has already been given up on
+		return nil
+	}
+	r.packets[id] = nil
+	ready := r.release()
+	if len(r.packets) >= r.size {
+		ready = append(ready, r.skip()...)
+	}
+	return ready
+}
+
+// release returns consecutive packets starting from the next one
+func (r *reorder) release() []packet.Packet {
+	var ready []packet.Packet
+	for {
+		pack, ok := r.packets[r.next]
+		if !ok {
+			return ready
+		}
+		delete(r.packets, r.next)
+		if pack != nil {
+			ready = append(ready, pack)
+		}
+		r.next++
+	}
+}
+
+// skip gives up on the gap and returns consecutive packets starting from the lowest buffered one
+func (r *reorder) skip() []packet.Packet {
+	if len(r.packets) == 0 {
+		return nil
+	}
+	lowest := uint64(0)
+	for id := range r.packets {
+		if (lowest == 0) || (id < lowest) {
+			lowest = id
+		}
+	}
+	r.stats.Skipped.Add(int64(lowest - r.next))
+	r.log.Warnf("skipped missing packets: #%d - #%d", r.next, lowest-1)
+	r.next = lowest
+	return r.release()
+}
+
+// waiting returns true in case there are packets waiting for a gap
+func (r *reorder) waiting() bool {
+	return len(r.packets) > 0
+}
+
+// resequence reads packets delivered by processors and delivers them to the pool output in order.
+// Packets, which leave the pool without being delivered, are reported by their ids to undelivered
+func (p *Pool) resequence(ctx context.Context, wg *sync.WaitGroup, in chan packet.Packet, undelivered chan uint64) {
+	defer wg.Done()
+	p.log.Info("resequencer start")
+	defer p.log.Info("resequencer end")
+
+	buf := newReorder(p.Options.ReorderBuffer, &p.Stats, p.log)
+	// Gap timer is armed while there are packets waiting for a gap and is re-armed on each progress
+	gap := time.NewTimer(p.Options.GapTimeout)
+	stop := func() {
+		if !gap.Stop() {
+			<-gap.C
+		}
+	}
+	stop()
+	armed := false
+	for {
+		var ready []packet.Packet
+		next := buf.next
+		select {
+		case <-ctx.Done():
+			if armed {
+				stop()
+			}
+			p.log.Infof("resequencer done: %s", &p.Stats)
+			return
+		case pack := <-in:
+			ready = buf.put(pack)
+		case id := <-undelivered:
+			ready = buf.forget(id)
+		case <-gap.C:
+			armed = false
+			ready = buf.skip()
+		}
+		if !p.deliver(ctx, ready) || (p.Options.GapPolicy != GapSkip) {
+			continue
+		}
+
+		switch {
+		case !buf.waiting():
+			if armed {
+				stop()
+			}
+			armed = false
+		case !armed || (buf.next != next):
+			if armed {
+				stop()
+			}
+			gap.Reset(p.Options.GapTimeout)
+			armed = true
+		}
+	}
+}
+
+// deliver delivers packets to the pool output. Returns false in case context is done
+func (p *Pool) deliver(ctx context.Context, packets []packet.Packet) bool {
+	for _, pack := range packets {
+		select {
+		case <-ctx.Done():
+			p.log.WithField(logger.FieldPacketID, pack.ID()).Debugf("resequencer NODELIVERY: %s", pack)
+			return false
+		case p.Pipes.Out <- pack:
+		}
+	}
+	return true
+}
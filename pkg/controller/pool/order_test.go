@@ -0,0 +1,131 @@
+// Copyright 2024 Vladislav Klimenko. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pool
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/sunsingerus/pipeline/pkg/controller/packet"
+	"github.com/sunsingerus/pipeline/pkg/logger"
+	model "github.com/sunsingerus/pipeline/pkg/model/packet"
+)
+
+func newPacket(id uint64) packet.Packet {
+	pack := model.New([]int{int(id)})
+	pack.SetID(id)
+	return pack
+}
+
+func ids(packets []packet.Packet) []uint64 {
+	var result []uint64
+	for _, pack := range packets {
+		result = append(result, pack.ID())
+	}
+	return result
+}
+
+func TestReorder(t *testing.T) {
+	stats := &Stats{}
+	r := newReorder(3, stats, logger.Component(Component))
+	require.Empty(t, r.put(newPacket(2)))
+	require.Equal(t, []uint64{1, 2}, ids(r.put(newPacket(1))))
+	require.Empty(t, r.put(newPacket(5)))
+	require.Empty(t, r.put(newPacket(6)))
+	// Buffer is full, gap #3 - #4 is skipped
+	require.Equal(t, []uint64{5, 6, 7}, ids(r.put(newPacket(7))))
+	require.Equal(t, int64(2), stats.Skipped.Load())
+	// Late packet is passed as is
+	require.Equal(t, []uint64{3}, ids(r.put(newPacket(3))))
+	require.Equal(t, int64(1), stats.Late.Load())
+}
+
+func TestReorderForget(t *testing.T) {
+	stats := &Stats{}
+	r := newReorder(3, stats, logger.Component(Component))
+	require.Empty(t, r.put(newPacket(3)))
+	require.Empty(t, r.forget(2))
+	require.Equal(t, []uint64{1, 3}, ids(r.put(newPacket(1))), "Check forgotten packet is not waited for")
+	require.Equal(t, []uint64{5}, ids(append(r.forget(4), r.put(newPacket(5))...)))
+	require.Empty(t, r.forget(1), "Check released id is ignored")
+	require.False(t, r.waiting())
+	require.Equal(t, int64(0), stats.Skipped.Load())
+}
+
+// processor delivers packets after random delay
+type processor struct {
+	Pipes
+}
+
+func (p *processor) Process(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case pack := <-p.Pipes.In:
+			time.Sleep(time.Duration(rand.Intn(1000)) * time.Microsecond)
+			select {
+			case <-ctx.Done():
+				return
+			case p.Pipes.Out <- pack:
+			}
+		}
+	}
+}
+
+func TestOrderedPool(t *testing.T) {
+	tests := []struct {
+		options Options
+		// drop specifies packet lost by processors
+		drop uint64
+	}{
+		{
+			options: Options{Ordered: true},
+		},
+		{
+			options: Options{Ordered: true, GapPolicy: GapSkip, GapTimeout: 20 * time.Millisecond},
+			drop:    10,
+		},
+	}
+	for _, tt := range tests {
+		in := make(chan packet.Packet)
+		out := make(chan packet.Packet)
+		p, err := New(4, func(id int, pipes Pipes) Processor { return &processor{pipes} }, nil, Pipes{In: in, Out: out}, tt.options)
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		wg := &sync.WaitGroup{}
+		p.Launch(ctx, wg)
+		go func() {
+			for id := uint64(1); id <= 100; id++ {
+				if id != tt.drop {
+					in <- newPacket(id)
+				}
+			}
+		}()
+		for id := uint64(1); id <= 100; id++ {
+			if id != tt.drop {
+				require.Equal(t, id, (<-out).ID(), "Check packets are delivered in order")
+			}
+		}
+		cancel()
+		wg.Wait()
+	}
+}
@@ -16,48 +16,237 @@ package pool
 
 import (
 	"context"
+	"fmt"
 	"sync"
+	"time"
 
-	log "github.com/sirupsen/logrus"
+	"github.com/sunsingerus/pipeline/pkg/controller/packet"
+	"github.com/sunsingerus/pipeline/pkg/controller/supervisor"
+	"github.com/sunsingerus/pipeline/pkg/logger"
 )
 
+// Pipes specifies channels pool processors read packets from and deliver packets to
+type Pipes struct {
+	In  chan packet.Packet
+	Out chan packet.Packet
+	// Quit is closed when processor is to quit, used to shrink the pool
+	Quit chan struct{}
+	// Undelivered receives ids of packets, which leave the pool without being delivered, ex.: filtered or rejected.
+	// Ordered pool provides it to its processors, so the resequencer does not wait for such packets
+	Undelivered chan uint64
+}
+
+// Options specifies pool options
+type Options struct {
+	// Name specifies name of the pool processors are supervised under. Empty name means processor
+	Name string
+	// Ordered specifies whether packets are delivered in the order of their ids
+	Ordered bool
+	// ReorderBuffer specifies max number of packets held while waiting for a missing packet
+	ReorderBuffer int
+	// GapPolicy specifies how missing packets are waited for. Empty policy means wait
+	GapPolicy GapPolicy
+	// GapTimeout specifies how long missing packet is waited for by the skip policy
+	GapTimeout time.Duration
+	// Sharded specifies whether packets with the same key are always processed by the same processor
+	Sharded bool
+	// ShardKey specifies name of the key packets are sharded by, one of: first, sum, max, id. Empty means first
+	ShardKey string
+}
+
+// DefaultName specifies name of the pool, unless specified
+const DefaultName = "processor"
+
+// Component specifies component pools log as
+const Component = "pool"
+
 type Pool struct {
 	size                 int
-	processorConstructor processorConstructor
+	processorConstructor ProcessorConstructor
+	supervisor           *supervisor.Supervisor
+	// sharding is nil unless pool is sharded
+	sharding *sharding
+	// ctx, wg and pipes are remembered by Launch in order to launch processors on resize
+	ctx   context.Context
+	wg    *sync.WaitGroup
+	pipes Pipes
+	// quit specifies quit channels of the launched processors
+	quit []chan struct{}
+	mux  sync.Mutex
+	log  *logger.Entry
+	Stats
+	Pipes
+	Options
 }
 
 type Processor interface {
 	Process(ctx context.Context)
 }
 
-type processorConstructor func(id int) Processor
+// inFlight is implemented by processors able to tell which packet they are busy with
+type inFlight interface {
+	InFlight() fmt.Stringer
+}
+
+// ProcessorConstructor creates processor, which reads packets from and delivers packets to the pipes
+type ProcessorConstructor func(id int, pipes Pipes) Processor
 
-func New(size int, processorConstructor processorConstructor) *Pool {
+// New creates new pool. Processors are not supervised in case supervisor is nil.
+// Returns error in case shard key is not valid
+func New(size int, processorConstructor ProcessorConstructor, supervisor *supervisor.Supervisor, pipes Pipes, opts Options) (*Pool, error) {
+	if opts.Name == "" {
+		opts.Name = DefaultName
+	}
+	if opts.ReorderBuffer <= 0 {
+		opts.ReorderBuffer = DefaultReorderBuffer
+	}
+	if opts.GapTimeout <= 0 {
+		opts.GapTimeout = DefaultGapTimeout
+	}
+	var _sharding *sharding
+	if opts.Sharded {
+		var err error
+		if _sharding, err = newSharding(opts.ShardKey); err != nil {
+			return nil, err
+		}
+	}
 	return &Pool{
 		size:                 size,
 		processorConstructor: processorConstructor,
-	}
+		supervisor:           supervisor,
+		sharding:             _sharding,
+		log:                  logger.Component(Component).WithField(logger.FieldStage, opts.Name),
+		Pipes:                pipes,
+		Options:              opts,
+	}, nil
 }
 
-func (p *Pool) launch(ctx context.Context, wg *sync.WaitGroup, id int) {
+func (p *Pool) launch(ctx context.Context, wg *sync.WaitGroup, id int, pipes Pipes) {
 	defer wg.Done()
 	if p == nil {
 		return
 	}
-	log.Infof("Launcher  [%d] - start", id)
-	defer log.Infof("Launcher  [%d] - end", id)
-	p.processorConstructor(id).Process(ctx)
+	_log := p.log.WithField(logger.FieldWorkerID, id)
+	_log.Info("launcher start")
+	defer _log.Info("launcher end")
+
+	// Crashed processor is replaced with the new one
+	var processor Processor
+	p.supervisor.Run(
+		ctx,
+		fmt.Sprintf("%s [%d]", p.Options.Name, id),
+		func(ctx context.Context) {
+			processor = p.processorConstructor(id, pipes)
+			processor.Process(ctx)
+		},
+		func() fmt.Stringer {
+			if processor, ok := processor.(inFlight); ok {
+				return processor.InFlight()
+			}
+			return nil
+		},
+	)
 }
 
 func (p *Pool) Launch(ctx context.Context, wg *sync.WaitGroup) {
 	if p == nil {
 		return
 	}
-	log.Infof("Launch - start")
-	defer log.Infof("Launch - end")
+	p.log.Info("launch start")
+	defer p.log.Info("launch end")
+
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	pipes := p.Pipes
+	if p.Options.Ordered {
+		// Processors deliver to the resequencer, which delivers packets in order
+		pipes.Out = make(chan packet.Packet)
+		pipes.Undelivered = make(chan uint64)
+		wg.Add(1)
+		go p.resequence(ctx, wg, pipes.Out, pipes.Undelivered)
+	}
 
+	p.ctx, p.wg, p.pipes = ctx, wg, pipes
 	for i := 0; i < p.size; i++ {
+		p.start(i)
+	}
+	if p.sharding != nil {
+		// Dispatcher reads pool input and feeds each processor with its own shard
 		wg.Add(1)
-		go p.launch(ctx, wg, i)
+		go p.dispatch(ctx, wg)
+	}
+}
+
+// start launches processor with its own quit channel and, in case pool is sharded, its own shard. Must be called under lock
+func (p *Pool) start(id int) {
+	pipes := p.pipes
+	pipes.Quit = make(chan struct{})
+	if p.sharding != nil {
+		pipes.In = make(chan packet.Packet)
+		p.sharding.add(id, shard{in: pipes.In, quit: pipes.Quit})
+	}
+	p.quit = append(p.quit, pipes.Quit)
+	p.wg.Add(1)
+	go p.launch(p.ctx, p.wg, id, pipes)
+}
+
+// Size returns number of the pool processors
+func (p *Pool) Size() int {
+	if p == nil {
+		return 0
+	}
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	return p.size
+}
+
+// CheckResize returns error in case pool can not be resized to size processors, as Resize would
+func (p *Pool) CheckResize(size int) error {
+	if p == nil {
+		return fmt.Errorf("no pool")
+	}
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	return p.checkResize(size)
+}
+
+// checkResize returns error in case pool can not be resized to size processors. Must be called under lock
+func (p *Pool) checkResize(size int) error {
+	if size < 1 {
+		return fmt.Errorf("invalid pool size %d", size)
+	}
+	if (p.ctx == nil) || (p.ctx.Err() != nil) {
+		return fmt.Errorf("pool is not running")
+	}
+	return nil
+}
+
+// Resize launches or quits processors of the running pool, so it has size processors.
+// Processors being quit finish packets in flight. Resizing sharded pool moves keys of the added or removed processors only.
+func (p *Pool) Resize(size int) error {
+	if p == nil {
+		return fmt.Errorf("no pool")
+	}
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	if err := p.checkResize(size); err != nil {
+		return err
+	}
+
+	p.log.Infof("resize %d -> %d", p.size, size)
+	for p.size < size {
+		p.start(p.size)
+		p.size++
+	}
+	for p.size > size {
+		p.size--
+		if p.sharding != nil {
+			// Keys of the processor move to the remaining processors before it quits
+			p.sharding.remove(p.size)
+		}
+		close(p.quit[p.size])
+		p.quit = p.quit[:p.size]
 	}
+	return nil
 }
@@ -0,0 +1,65 @@
+// Copyright 2024 Vladislav Klimenko. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pool
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// idler waits for context to be done or to be quit, counting running processors
+type idler struct {
+	Pipes
+	running *atomic.Int64
+}
+
+func (i *idler) Process(ctx context.Context) {
+	i.running.Add(1)
+	defer i.running.Add(-1)
+	select {
+	case <-ctx.Done():
+	case <-i.Pipes.Quit:
+	}
+}
+
+func TestResize(t *testing.T) {
+	running := &atomic.Int64{}
+	p, err := New(2, func(id int, pipes Pipes) Processor { return &idler{Pipes: pipes, running: running} }, nil, Pipes{}, Options{})
+	require.NoError(t, err)
+	require.Error(t, p.Resize(3), "Check pool is not resized before launch")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := &sync.WaitGroup{}
+	p.Launch(ctx, wg)
+	require.Eventually(t, func() bool { return running.Load() == 2 }, time.Second, time.Millisecond)
+
+	require.NoError(t, p.Resize(5))
+	require.Equal(t, 5, p.Size())
+	require.Eventually(t, func() bool { return running.Load() == 5 }, time.Second, time.Millisecond)
+
+	require.NoError(t, p.Resize(1))
+	require.Equal(t, 1, p.Size())
+	require.Eventually(t, func() bool { return running.Load() == 1 }, time.Second, time.Millisecond)
+
+	require.Error(t, p.Resize(0))
+	cancel()
+	wg.Wait()
+	require.Error(t, p.Resize(3), "Check pool is not resized once stopped")
+}
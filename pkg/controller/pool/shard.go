@@ -0,0 +1,261 @@
+// Copyright 2024 Vladislav Klimenko. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pool
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/sunsingerus/pipeline/pkg/controller/packet"
+	"github.com/sunsingerus/pipeline/pkg/logger"
+)
+
+// Available shard keys
+const (
+	// ShardKeyFirst uses the first value of the packet as a key
+	ShardKeyFirst = "first"
+	// ShardKeySum uses sum of the packet values as a key
+	ShardKeySum = "sum"
+	// ShardKeyMax uses max value of the packet as a key
+	ShardKeyMax = "max"
+	// ShardKeyID uses packet id as a key
+	ShardKeyID = "id"
+)
+
+// DefaultReplicas specifies default number of points each worker has on the hash ring
+const DefaultReplicas = 100
+
+// KeyFunc extracts shard key from the packet
+type KeyFunc func(packet.Packet) uint64
+
+// NewKeyFunc creates key func by its name
+func NewKeyFunc(name string) (KeyFunc, error) {
+	switch strings.ToLower(name) {
+	case "", ShardKeyFirst:
+		return func(pack packet.Packet) uint64 {
+			if pack.Len() == 0 {
+				return 0
+			}
+			return uint64(pack.Get(0))
+		}, nil
+	case ShardKeySum:
+		return func(pack packet.Packet) uint64 {
+			sum := 0
+			for i := 0; i < pack.Len(); i++ {
+				sum += pack.Get(i)
+			}
+			return uint64(sum)
+		}, nil
+	case ShardKeyMax:
+		return func(pack packet.Packet) uint64 {
+			if pack.Len() == 0 {
+				return 0
+			}
+			max := pack.Get(0)
+			for i := 1; i < pack.Len(); i++ {
+				if pack.Get(i) > max {
+					max = pack.Get(i)
+				}
+			}
+			return uint64(max)
+		}, nil
+	case ShardKeyID:
+		return func(pack packet.Packet) uint64 {
+			return pack.ID()
+		}, nil
+	}
+	return nil, fmt.Errorf("not a valid shard key: %q", name)
+}
+
+// Ring specifies consistent hash ring of workers
+type Ring struct {
+	replicas int
+	// points specifies sorted hashes of all workers' points
+	points []uint64
+	// owners maps point hash to worker id
+	owners map[uint64]int
+	mux    sync.RWMutex
+}
+
+// NewRing creates hash ring with specified number of points per worker
+func NewRing(replicas int) *Ring {
+	if replicas <= 0 {
+		replicas = DefaultReplicas
+	}
+	return &Ring{
+		replicas: replicas,
+		owners:   make(map[uint64]int),
+	}
+}
+
+// Add adds worker to the ring
+func (r *Ring) Add(worker int) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	for i := 0; i < r.replicas; i++ {
+		point := hashString(fmt.Sprintf("worker-%d#%d", worker, i))
+		r.owners[point] = worker
+		r.points = append(r.points, point)
+	}
+	sort.Slice(r.points, func(i, j int) bool { return r.points[i] < r.points[j] })
+}
+
+// Remove removes worker from the ring
+func (r *Ring) Remove(worker int) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	points := r.points[:0]
+	for _, point := range r.points {
+		if r.owners[point] == worker {
+			delete(r.owners, point)
+			continue
+		}
+		points = append(points, point)
+	}
+	r.points = points
+}
+
+// Get returns worker owning the key. Returns -1 in case the ring is empty
+func (r *Ring) Get(key uint64) int {
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+
+	if len(r.points) == 0 {
+		return -1
+	}
+	hash := hashKey(key)
+	i := sort.Search(len(r.points), func(i int) bool { return r.points[i] >= hash })
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.owners[r.points[i]]
+}
+
+func hashString(s string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(s))
+	return mix(h.Sum64())
+}
+
+func hashKey(key uint64) uint64 {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], key)
+	h := fnv.New64a()
+	_, _ = h.Write(buf[:])
+	return mix(h.Sum64())
+}
+
+// mix spreads bits of fnv hash of short similar inputs all over the ring (murmur3 finalizer)
+func mix(h uint64) uint64 {
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
+
+// shard specifies input of the worker and its quit channel
+type shard struct {
+	in   chan packet.Packet
+	quit chan struct{}
+}
+
+// sharding specifies how sharded pool assigns packets to its workers.
+// Workers are added and removed as pool is resized, so only keys of the added or removed worker move
+type sharding struct {
+	ring *Ring
+	key  KeyFunc
+	// shards specifies shards of the workers by worker ids
+	shards []shard
+	mux    sync.RWMutex
+}
+
+// newSharding creates sharding of packets by the key with no workers
+func newSharding(key string) (*sharding, error) {
+	keyFunc, err := NewKeyFunc(key)
+	if err != nil {
+		return nil, err
+	}
+	return &sharding{
+		ring: NewRing(DefaultReplicas),
+		key:  keyFunc,
+	}, nil
+}
+
+// add adds shard of the worker, which id follows ids of the existing workers
+func (s *sharding) add(worker int, _shard shard) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.shards = append(s.shards, _shard)
+	s.ring.Add(worker)
+}
+
+// remove removes shard of the worker with the last id, so its keys move to the remaining workers
+func (s *sharding) remove(worker int) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.ring.Remove(worker)
+	s.shards = s.shards[:worker]
+}
+
+// shard returns shard of the worker the packet is assigned to
+func (s *sharding) shard(pack packet.Packet) shard {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+	return s.shards[s.ring.Get(s.key(pack))]
+}
+
+// dispatch reads packets from the pool input and sends each of them to the shard of the worker owning its key
+func (p *Pool) dispatch(ctx context.Context, wg *sync.WaitGroup) {
+	defer wg.Done()
+	p.log.Info("dispatcher start")
+	defer p.log.Info("dispatcher end")
+
+	for {
+		select {
+		case <-ctx.Done():
+			p.log.Info("dispatcher done")
+			return
+		case pack := <-p.Pipes.In:
+			if !p.send(ctx, pack) {
+				p.log.WithField(logger.FieldPacketID, pack.ID()).Debugf("dispatcher NODELIVERY: %s", pack)
+				return
+			}
+		}
+	}
+}
+
+// send sends packet to the shard of the worker owning its key. Worker removed by resize meanwhile quits,
+// so the packet is sent to the new owner of its key. Returns false in case context is done
+func (p *Pool) send(ctx context.Context, pack packet.Packet) bool {
+	for {
+		_shard := p.sharding.shard(pack)
+		select {
+		case <-ctx.Done():
+			return false
+		case _shard.in <- pack:
+			return true
+		case <-_shard.quit:
+		}
+	}
+}
@@ -0,0 +1,226 @@
+// Copyright 2024 Vladislav Klimenko. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pool
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/sunsingerus/pipeline/pkg/controller/packet"
+	model "github.com/sunsingerus/pipeline/pkg/model/packet"
+)
+
+const keysNum = 100000
+
+func TestRingBalance(t *testing.T) {
+	ring := NewRing(DefaultReplicas)
+	for i := 0; i < 4; i++ {
+		ring.Add(i)
+	}
+	counts := make(map[int]int)
+	for key := uint64(0); key < keysNum; key++ {
+		counts[ring.Get(key)]++
+	}
+	require.Len(t, counts, 4)
+	for worker, count := range counts {
+		require.InEpsilon(t, keysNum/4, count, 0.25, "Check worker %d gets its share of keys", worker)
+	}
+}
+
+func TestRingResize(t *testing.T) {
+	ring := NewRing(DefaultReplicas)
+	require.Equal(t, -1, ring.Get(1))
+	for i := 0; i < 4; i++ {
+		ring.Add(i)
+	}
+	before := make([]int, keysNum)
+	for key := range before {
+		before[key] = ring.Get(uint64(key))
+	}
+
+	// Growing the ring moves keys to the new worker only
+	ring.Add(4)
+	moved := 0
+	for key, worker := range before {
+		if now := ring.Get(uint64(key)); now != worker {
+			require.Equal(t, 4, now, "Check key is moved to the new worker")
+			moved++
+		}
+	}
+	require.InEpsilon(t, keysNum/5, moved, 0.25, "Check only new worker's share of keys is moved")
+
+	// Shrinking the ring back restores the original placement
+	ring.Remove(4)
+	for key, worker := range before {
+		require.Equal(t, worker, ring.Get(uint64(key)))
+	}
+}
+
+// recorder records packets processed by each processor
+type recorder struct {
+	id int
+	Pipes
+	keys *sync.Map
+}
+
+func (r *recorder) Process(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case pack := <-r.Pipes.In:
+			if worker, loaded := r.keys.LoadOrStore(pack.Get(0), r.id); loaded && (worker != r.id) {
+				panic("key is processed by two workers")
+			}
+			r.Pipes.Out <- pack
+		}
+	}
+}
+
+func TestShardedPool(t *testing.T) {
+	in := make(chan packet.Packet)
+	out := make(chan packet.Packet)
+	keys := &sync.Map{}
+	p, err := New(4, func(id int, pipes Pipes) Processor { return &recorder{id: id, Pipes: pipes, keys: keys} }, nil, Pipes{In: in, Out: out}, Options{Sharded: true})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := &sync.WaitGroup{}
+	p.Launch(ctx, wg)
+	go func() {
+		for i := 0; i < 1000; i++ {
+			in <- model.New([]int{i % 20, i})
+		}
+	}()
+	for i := 0; i < 1000; i++ {
+		<-out
+	}
+	cancel()
+	wg.Wait()
+
+	workers := make(map[any]bool)
+	keys.Range(func(_, worker any) bool {
+		workers[worker] = true
+		return true
+	})
+	require.Greater(t, len(workers), 1, "Check keys are spread among workers")
+}
+
+// forwarder delivers packets to the output, recording worker of each key
+type forwarder struct {
+	id int
+	Pipes
+	keys *sync.Map
+}
+
+func (f *forwarder) Process(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-f.Pipes.Quit:
+			return
+		case pack := <-f.Pipes.In:
+			f.keys.Store(pack.Get(0), f.id)
+			f.Pipes.Out <- pack
+		}
+	}
+}
+
+func TestShardedPoolResize(t *testing.T) {
+	in := make(chan packet.Packet)
+	out := make(chan packet.Packet)
+	keys := &sync.Map{}
+	p, err := New(4, func(id int, pipes Pipes) Processor { return &forwarder{id: id, Pipes: pipes, keys: keys} }, nil, Pipes{In: in, Out: out}, Options{Sharded: true})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := &sync.WaitGroup{}
+	p.Launch(ctx, wg)
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+
+	// workers sends packet of each key through the pool and returns workers the keys are processed by
+	workers := func() []int {
+		go func() {
+			for key := 0; key < 1000; key++ {
+				in <- model.New([]int{key})
+			}
+		}()
+		for i := 0; i < 1000; i++ {
+			<-out
+		}
+		result := make([]int, 1000)
+		for key := range result {
+			worker, _ := keys.Load(key)
+			result[key] = worker.(int)
+		}
+		return result
+	}
+	before := workers()
+
+	// Added worker takes keys of the other workers, no key moves between the other workers
+	require.NoError(t, p.Resize(5))
+	grown := workers()
+	moved := 0
+	for key, worker := range before {
+		if grown[key] != worker {
+			require.Equal(t, 4, grown[key], "Check key %d is moved to the added worker", key)
+			moved++
+		}
+	}
+	require.Greater(t, moved, 0, "Check added worker gets keys")
+
+	// Removed worker gives its keys to the other workers, no key moves between the other workers
+	require.NoError(t, p.Resize(3))
+	shrunk := workers()
+	for key, worker := range before {
+		if worker != 3 {
+			require.Equal(t, worker, shrunk[key], "Check key %d of the remaining worker is not moved", key)
+		} else {
+			require.Less(t, shrunk[key], 3, "Check key %d of the removed worker is moved", key)
+		}
+	}
+}
+
+func TestKeyFunc(t *testing.T) {
+	pack := model.New([]int{3, 9, 1})
+	pack.SetID(7)
+	tests := map[string]uint64{
+		ShardKeyFirst: 3,
+		ShardKeySum:   13,
+		ShardKeyMax:   9,
+		ShardKeyID:    7,
+	}
+	for name, expect := range tests {
+		key, err := NewKeyFunc(name)
+		require.NoError(t, err)
+		require.Equal(t, expect, key(pack), "Check key: %s", name)
+	}
+	_, err := NewKeyFunc("unknown")
+	require.Error(t, err)
+}
+
+func TestShardedPoolInvalidKey(t *testing.T) {
+	_, err := New(2, nil, nil, Pipes{}, Options{Sharded: true, ShardKey: "unknown"})
+	require.Error(t, err)
+	_, err = New(2, nil, nil, Pipes{}, Options{ShardKey: "unknown"})
+	require.NoError(t, err, "Check shard key is not used by the pool, which is not sharded")
+}
@@ -0,0 +1,209 @@
+// Copyright 2024 Vladislav Klimenko. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"fmt"
+	"runtime/debug"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/sunsingerus/pipeline/pkg/controller/packet"
+)
+
+// DeadlinePolicy specifies how packets exceeded processing deadline are handled
+type DeadlinePolicy string
+
+// Available deadline policies
+const (
+	// DeadlineDeadLetter sends packet to the dead-letter sink
+	DeadlineDeadLetter DeadlinePolicy = "deadletter"
+	// DeadlinePass passes packet through unchanged
+	DeadlinePass DeadlinePolicy = "pass"
+)
+
+// DefaultMaxAbandoned specifies max number of processings abandoned on deadline, which may still run, per processor
+const DefaultMaxAbandoned = 16
+
+// result specifies result of packet processing
+type result struct {
+	out packet.Packet
+	err error
+	// panic specifies panic of the processing, if any
+	panic *processingPanic
+}
+
+// processingPanic specifies panic of the processing run by its own goroutine, which is re-raised by the worker
+type processingPanic struct {
+	value any
+	stack []byte
+}
+
+// String returns panic value followed by the stack of the goroutine, which panicked
+func (p *processingPanic) String() string {
+	return fmt.Sprintf("%v\n%s", p.value, p.stack)
+}
+
+// States of the processing run by its own goroutine
+const (
+	processingRunning int32 = iota
+	processingFinished
+	processingAbandoned
+)
+
+// process processes packet within the deadline, if specified, and logs slow packets.
+// Packet exceeded the deadline is passed through unchanged or fails, depending on the deadline policy.
+// Panic of the processing is re-raised, unless processing is abandoned on deadline
+func (p *Processor) process(in packet.Packet) (packet.Packet, error) {
+	start := time.Now()
+	defer func() {
+		p.observe(in, time.Since(start))
+	}()
+
+	if p.Options.Deadline <= 0 {
+		return p.processPacket(in)
+	}
+
+	// Abandoned processing may keep working on its packet in place, so it works on a copy and the packet is kept intact
+	work := p.outPacketConstructor(append([]int(nil), in.Slice()...))
+	work.SetID(in.ID())
+	done := make(chan result, 1)
+	var state atomic.Int32
+	go func() {
+		r := p.processRecovered(work)
+		if state.CompareAndSwap(processingRunning, processingFinished) {
+			done <- r
+			return
+		}
+		// Nobody waits for the result of abandoned processing
+		<-p.abandoned
+		p.stats.Counter("abandoned").Add(-1)
+		if r.panic != nil {
+			p.packetLog(in.ID()).Errorf("abandoned processing panic: %v", r.panic)
+		}
+	}()
+
+	timer := time.NewTimer(p.Options.Deadline)
+	defer timer.Stop()
+	select {
+	case r := <-done:
+		return p.result(r)
+	case <-timer.C:
+	}
+
+	err := fmt.Errorf("deadline %s exceeded", p.Options.Deadline)
+	select {
+	case p.abandoned <- struct{}{}:
+		if !state.CompareAndSwap(processingRunning, processingAbandoned) {
+			// Processing has finished right at the deadline, so its result is valid
+			<-p.abandoned
+			return p.result(<-done)
+		}
+		p.stats.Counter("abandoned").Add(1)
+	default:
+		// Processing is waited for rather than abandoned, so hanging transform does not leak goroutine per packet
+		p.packetLog(in.ID()).Warnf("%v, %d processings abandoned already, waiting", err, cap(p.abandoned))
+		if r := <-done; r.panic != nil {
+			panic(r.panic)
+		}
+	}
+	p.stats.TimedOut.Add(1)
+	if p.Options.DeadlinePolicy == DeadlinePass {
+		p.packetLog(in.ID()).Warnf("pass: %v reason: %v", in, err)
+		return in, nil
+	}
+	return nil, err
+}
+
+// processRecovered processes packet, panic is recovered into the result
+func (p *Processor) processRecovered(in packet.Packet) (r result) {
+	defer func() {
+		if value := recover(); value != nil {
+			r = result{panic: &processingPanic{value: value, stack: debug.Stack()}}
+		}
+	}()
+	r.out, r.err = p.processPacket(in)
+	return r
+}
+
+// result returns result of the processing finished within the deadline. Panic of the processing is re-raised
+func (p *Processor) result(r result) (packet.Packet, error) {
+	if r.panic != nil {
+		panic(r.panic)
+	}
+	return r.out, r.err
+}
+
+// observe logs packet in case its processing time is above the percentile of recent processing times
+func (p *Processor) observe(in packet.Packet, elapsed time.Duration) {
+	if slow, threshold := p.latency.observe(elapsed); slow {
+		p.stats.Slow.Add(1)
+		p.packetLog(in.ID()).Warnf("slow packet: size: %d elapsed: %s p%g: %s",
+			in.Len(), elapsed, p.Options.SlowPercentile*100, threshold)
+	}
+}
+
+// Latency tracking options
+const (
+	// latencyWindow specifies number of recent processing times percentile is calculated for
+	latencyWindow = 1000
+	// latencyRefresh specifies number of observations between percentile recalculations
+	latencyRefresh = 100
+)
+
+// latency tracks recent processing times
+type latency struct {
+	percentile float64
+	window     []time.Duration
+	// count specifies total number of observations
+	count int
+	// threshold specifies the latest calculated percentile
+	threshold time.Duration
+}
+
+// newLatency creates latency tracker. Returns nil in case percentile is not within (0, 1)
+func newLatency(percentile float64) *latency {
+	if (percentile <= 0) || (percentile >= 1) {
+		return nil
+	}
+	return &latency{
+		percentile: percentile,
+		window:     make([]time.Duration, latencyWindow),
+	}
+}
+
+// observe records processing time. Returns true and the threshold in case processing time is above the threshold
+func (l *latency) observe(elapsed time.Duration) (bool, time.Duration) {
+	if l == nil {
+		return false, 0
+	}
+	l.window[l.count%len(l.window)] = elapsed
+	l.count++
+	if l.count%latencyRefresh == 0 {
+		n := l.count
+		if n > len(l.window) {
+			n = len(l.window)
+		}
+		sorted := append([]time.Duration(nil), l.window[:n]...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+		l.threshold = sorted[int(l.percentile*float64(n-1))]
+	}
+	// Threshold is unknown until enough processing times are observed
+	if l.count <= latencyRefresh {
+		return false, 0
+	}
+	return elapsed > l.threshold, l.threshold
+}
@@ -17,88 +17,228 @@ package processor
 import (
 	"context"
 	"fmt"
-	"sort"
+	"strconv"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 
+	"github.com/sunsingerus/pipeline/pkg/controller/dlq"
+	"github.com/sunsingerus/pipeline/pkg/controller/health"
+	"github.com/sunsingerus/pipeline/pkg/controller/metrics"
 	"github.com/sunsingerus/pipeline/pkg/controller/packet"
+	"github.com/sunsingerus/pipeline/pkg/controller/stage"
+	"github.com/sunsingerus/pipeline/pkg/controller/tracing"
+	"github.com/sunsingerus/pipeline/pkg/controller/transform"
+	"github.com/sunsingerus/pipeline/pkg/logger"
 )
 
-type inPacket interface {
-	fmt.Stringer
-	Slice(...int) []int
-	Len() int
-}
+// OutPacketConstructor creates packet processor delivers
+type OutPacketConstructor func([]int) packet.Packet
 
-type OutPacket interface {
-	fmt.Stringer
-}
+// Component specifies component processors log as
+const Component = "processor"
 
-type outPacketConstructor func([]int) OutPacket
+// DefaultStage specifies name of the stage processors report to the dead-letter sink, unless specified
+const DefaultStage = "processor"
 
 type Pipes struct {
 	In  chan packet.Packet
 	Out chan packet.Packet
+	// DeadLetter receives packets failed to be processed. Failed packets are dropped in case it is nil
+	DeadLetter dlq.Sink
+	// Quit is closed when processor is to quit. Nil means processor runs until context is done
+	Quit chan struct{}
+	// Undelivered receives ids of packets, which leave the processor without being delivered,
+	// ex.: filtered, rejected, dropped or lost in a crash. Nil means ids are not reported
+	Undelivered chan uint64
 }
 
+// OverflowPolicy specifies how packets are delivered in case the next stage is not ready to accept them
+type OverflowPolicy string
+
+// Available overflow policies
+const (
+	// OverflowBlock waits for the next stage to accept packet
+	OverflowBlock OverflowPolicy = "block"
+	// OverflowDrop drops packet
+	OverflowDrop OverflowPolicy = "drop"
+)
+
 // Options specifies processor options
 type Options struct {
-	// Size specifies size of result packet
-	ResultSize int
+	// Stage specifies name of the stage processor belongs to. Empty name means processor
+	Stage string
+	// Deadline specifies max time to process a packet. Zero means no deadline
+	Deadline time.Duration
+	// DeadlinePolicy specifies how packets exceeded deadline are handled. Empty policy means dead-letter.
+	DeadlinePolicy DeadlinePolicy
+	// MaxAbandoned specifies max number of processings abandoned on deadline, which may still run.
+	// Processing is waited for, once the limit is reached. Zero means DefaultMaxAbandoned
+	MaxAbandoned int
+	// SlowPercentile specifies percentile of recent processing times above which packets are logged as slow.
+	// Zero means no slow packets log.
+	SlowPercentile float64
+	// Overflow specifies how packets are delivered in case the next stage is busy. Empty policy means block.
+	Overflow OverflowPolicy
+	// Tracer specifies tracer of the processed packets. Nil means no tracing
+	Tracer *tracing.Tracer
+	// Health specifies monitor processor reports heartbeats to as stage. Nil means no heartbeats
+	Health *health.Monitor
 }
 
 type Processor struct {
-	id                   int
-	outPacketConstructor outPacketConstructor
+	id int
+	// log specifies logger of the processor
+	log                  *logger.Entry
+	transform            transform.Transform
+	outPacketConstructor OutPacketConstructor
+	stats                *metrics.Stage
+	// inFlight specifies packet being processed
+	inFlight packet.Packet
+	latency  *latency
+	// abandoned holds a slot for each processing abandoned on deadline, which still runs
+	abandoned chan struct{}
+	// stage receives packets from In, processes them and delivers results to Out
+	stage *stage.Stage[packet.Packet, packet.Packet]
 	Pipes
 	Options
 }
 
-func New(id int, outPacketConstructor outPacketConstructor, pipes Pipes, opts Options) *Processor {
-	return &Processor{
-		id:                   id,
+// New creates new processor, which applies transform to packets. Stats can be nil
+func New(id int, transform transform.Transform, outPacketConstructor OutPacketConstructor, stats *metrics.Stage, pipes Pipes, opts Options) *Processor {
+	if opts.Stage == "" {
+		opts.Stage = DefaultStage
+	}
+	if stats == nil {
+		stats = metrics.NewStage(opts.Stage)
+	}
+	if opts.MaxAbandoned <= 0 {
+		opts.MaxAbandoned = DefaultMaxAbandoned
+	}
+	p := &Processor{
+		id: id,
+		log: logger.Component(Component).WithFields(log.Fields{
+			logger.FieldStage:    opts.Stage,
+			logger.FieldWorkerID: id,
+		}),
+		transform:            transform,
 		outPacketConstructor: outPacketConstructor,
+		stats:                stats,
+		latency:              newLatency(opts.SlowPercentile),
+		abandoned:            make(chan struct{}, opts.MaxAbandoned),
 		Pipes:                pipes,
 		Options:              opts,
 	}
+	p.stage = stage.New[packet.Packet, packet.Packet](
+		opts.Stage,
+		p.handle,
+		stats,
+		pipes.In,
+		pipes.Out,
+		stage.Options[packet.Packet]{
+			Component: Component,
+			Quit:      pipes.Quit,
+			Drop:      opts.Overflow == OverflowDrop,
+			OnSkip:    p.filtered,
+			OnError:   p.reject,
+			OnDrop:    p.dropped,
+			Health:    opts.Health,
+		},
+	)
+	return p
 }
 
-func (p *Processor) processPacket(in inPacket) OutPacket {
+func (p *Processor) processPacket(in packet.Packet) (packet.Packet, error) {
 	if p == nil {
-		return nil
+		return nil, fmt.Errorf("no processor")
+	}
+	if p.transform == nil {
+		return nil, fmt.Errorf("no transform")
 	}
 
-	// Sort incoming packet ASC and get N greatest numbers, which will be located on the right side of the slice
-	sort.Ints(in.Slice())
-	return p.outPacketConstructor(in.Slice(-p.Options.ResultSize))
+	values, err := p.transform.Apply(in.Slice())
+	if err != nil {
+		return nil, err
+	}
+	if values == nil {
+		return nil, stage.ErrSkip
+	}
+	return p.outPacketConstructor(values), nil
 }
 
-func (p *Processor) deliver(ctx context.Context, pack OutPacket) {
-	if p == nil {
+// handle processes packet received by the stage
+func (p *Processor) handle(pack packet.Packet) (packet.Packet, error) {
+	received := time.Now()
+	p.inFlight = pack
+	p.Options.Tracer.Wait(pack.ID(), tracing.SpanQueue+" "+p.Options.Stage, received)
+	result, err := p.process(pack)
+	p.Options.Tracer.Span(pack.ID(), tracing.SpanProcess+" "+p.Options.Stage, received, time.Now(),
+		tracing.Attribute{Key: logger.FieldWorkerID, Value: strconv.Itoa(p.id)})
+	p.inFlight = nil
+	if err != nil {
+		return nil, err
+	}
+	result.SetID(pack.ID())
+	if p.log.Logger.IsLevelEnabled(log.TraceLevel) {
+		p.packetLog(pack.ID()).Tracef("prepared: %s", result)
+	}
+	return result, nil
+}
+
+// filtered reports packet filtered out by the transform
+func (p *Processor) filtered(ctx context.Context, _ int, pack packet.Packet) {
+	p.Options.Tracer.Finish(pack.ID(), tracing.StatusFiltered)
+	p.undelivered(ctx, pack.ID())
+}
+
+// reject sends packet failed to be processed to the dead-letter sink
+func (p *Processor) reject(ctx context.Context, _ int, pack packet.Packet, err error) {
+	p.Options.Tracer.Finish(pack.ID(), tracing.StatusRejected)
+	if p.Pipes.DeadLetter != nil {
+		p.Pipes.DeadLetter.Put(dlq.NewEntry(p.Options.Stage, p.id, err, pack.Slice()))
+	}
+	p.undelivered(ctx, pack.ID())
+}
+
+// dropped reports packet dropped, since the next stage was not ready to accept it
+func (p *Processor) dropped(ctx context.Context, _ int, pack packet.Packet) {
+	p.Options.Tracer.Finish(pack.ID(), tracing.StatusDropped)
+	p.undelivered(ctx, pack.ID())
+}
+
+// Process processes packets until context is done, input is closed or quit is closed
+func (p *Processor) Process(ctx context.Context) {
+	p.log.Info("start")
+	defer p.log.Info("end")
+	// Processor may crash while being busy with a packet
+	defer func() {
+		if p.inFlight != nil {
+			p.undelivered(ctx, p.inFlight.ID())
+		}
+	}()
+	p.stage.Work(ctx, p.id)
+}
+
+// undelivered reports id of the packet, which leaves the processor without being delivered
+func (p *Processor) undelivered(ctx context.Context, id uint64) {
+	if p.Pipes.Undelivered == nil {
 		return
 	}
 	select {
 	case <-ctx.Done():
-		log.Infof("Processor [%d] - NODELIVERY: %s", p.id, pack)
-	case p.Pipes.Out <- pack.(packet.Packet):
-		log.Infof("Processor [%d] - delivered : %s", p.id, pack)
+	case p.Pipes.Undelivered <- id:
 	}
 }
 
-func (p *Processor) Process(ctx context.Context) {
-	log.Infof("Processor [%d] - start", p.id)
-	defer log.Infof("Processor [%d] - end", p.id)
-
-	for {
-		select {
-		case <-ctx.Done():
-			log.Infof("Processor [%d] - done", p.id)
-			return
-		case pack := <-p.Pipes.In:
-			log.Infof("Processor [%d] - received  : %s", p.id, pack)
-			result := p.processPacket(pack)
-			log.Infof("Processor [%d] - prepared  : %s", p.id, result)
-			p.deliver(ctx, result)
-		}
+// packetLog returns logger of the packet processing
+func (p *Processor) packetLog(id uint64) *logger.Entry {
+	return p.log.WithField(logger.FieldPacketID, id)
+}
+
+// InFlight returns packet being processed, if any
+func (p *Processor) InFlight() fmt.Stringer {
+	if (p == nil) || (p.inFlight == nil) {
+		return nil
 	}
+	return p.inFlight
 }
@@ -0,0 +1,236 @@
+// Copyright 2024 Vladislav Klimenko. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/sunsingerus/pipeline/pkg/controller/dlq"
+	"github.com/sunsingerus/pipeline/pkg/controller/metrics"
+	"github.com/sunsingerus/pipeline/pkg/controller/packet"
+	"github.com/sunsingerus/pipeline/pkg/controller/stage"
+	"github.com/sunsingerus/pipeline/pkg/controller/transform"
+	model "github.com/sunsingerus/pipeline/pkg/model/packet"
+)
+
+// topN creates top-N transform
+func topN(n int, policy transform.ShortPacketPolicy) transform.Transform {
+	return &transform.TopN{N: n, Policy: policy}
+}
+
+func newOutPacket(slice []int) packet.Packet {
+	return model.New(slice)
+}
+
+func TestProcessorTransform(t *testing.T) {
+	tests := []struct {
+		transform transform.Transform
+		input     []int
+		expect    string
+		err       error
+	}{
+		{
+			transform: topN(3, transform.ShortPacketPad),
+			input:     []int{1, 9, 6, 4, 4, 5, 7, 8, 0, 1},
+			expect:    "[7,8,9]",
+		},
+		{
+			transform: transform.Filter(5, 10),
+			input:     []int{1, 2},
+			err:       stage.ErrSkip,
+		},
+		{
+			transform: transform.Scale(2, 1),
+			input:     []int{1, 2},
+			expect:    "[3,5]",
+		},
+	}
+	for _, tt := range tests {
+		p := New(0, tt.transform, newOutPacket, nil, Pipes{}, Options{})
+		result, err := p.processPacket(model.New(tt.input))
+		if tt.err != nil {
+			require.ErrorIs(t, err, tt.err, "Check error: %v", tt.input)
+			continue
+		}
+		require.NoError(t, err, "Check result: %v", tt.input)
+		require.Equal(t, tt.expect, result.String(), "Check result: %v", tt.input)
+	}
+}
+
+func TestProcessorDeadLetter(t *testing.T) {
+	in := make(chan packet.Packet)
+	out := make(chan packet.Packet)
+	ring := dlq.NewRing(10, "")
+	stats := metrics.NewStage("topn")
+	p := New(7, topN(2, transform.ShortPacketReject), newOutPacket, stats, Pipes{In: in, Out: out, DeadLetter: ring}, Options{Stage: "topn"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		p.Process(ctx)
+	}()
+
+	in <- model.New([]int{1})
+	in <- model.New([]int{3, 1, 2})
+	require.Equal(t, "[2,3]", (<-out).String())
+	cancel()
+	wg.Wait()
+
+	entries := ring.List()
+	require.Len(t, entries, 1)
+	require.Equal(t, "topn", entries[0].Stage)
+	require.Equal(t, 7, entries[0].WorkerID)
+	require.Equal(t, "1", entries[0].Line())
+	require.Equal(t, int64(2), stats.Received.Load())
+	require.Equal(t, int64(1), stats.Delivered.Load())
+	require.Equal(t, int64(1), stats.Rejected.Load())
+}
+
+func TestProcessorUndelivered(t *testing.T) {
+	in := make(chan packet.Packet)
+	out := make(chan packet.Packet)
+	undelivered := make(chan uint64)
+	p := New(0, transform.Filter(0, 10), newOutPacket, nil, Pipes{In: in, Out: out, Undelivered: undelivered}, Options{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		p.Process(ctx)
+	}()
+
+	pack := model.New([]int{100})
+	pack.SetID(3)
+	in <- pack
+	require.Equal(t, uint64(3), <-undelivered, "Check filtered packet is reported")
+	cancel()
+	wg.Wait()
+}
+
+func TestProcessorDeadline(t *testing.T) {
+	tests := []struct {
+		policy     DeadlinePolicy
+		expect     string
+		deadLetter int
+	}{
+		{
+			policy:     DeadlineDeadLetter,
+			deadLetter: 1,
+		},
+		{
+			policy: DeadlinePass,
+			expect: "[3,1,2]",
+		},
+	}
+	for _, tt := range tests {
+		ring := dlq.NewRing(10, "")
+		stats := metrics.NewStage(DefaultStage)
+		slow := transform.Func(func(values []int) ([]int, error) {
+			time.Sleep(100 * time.Millisecond)
+			return topN(2, "").Apply(values)
+		})
+		in := make(chan packet.Packet)
+		out := make(chan packet.Packet)
+		p := New(0, slow, newOutPacket, stats, Pipes{In: in, Out: out, DeadLetter: ring}, Options{Deadline: 10 * time.Millisecond, DeadlinePolicy: tt.policy})
+		ctx, cancel := context.WithCancel(context.Background())
+		wg := &sync.WaitGroup{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.Process(ctx)
+		}()
+
+		pack := model.New([]int{3, 1, 2})
+		pack.SetID(5)
+		in <- pack
+		if tt.deadLetter > 0 {
+			require.Eventually(t, func() bool { return len(ring.List()) == tt.deadLetter }, time.Second, time.Millisecond)
+			require.Equal(t, "3,1,2", ring.List()[0].Line(), "Check original packet is dead-lettered")
+		} else {
+			result := <-out
+			require.Equal(t, tt.expect, result.String(), "Check packet is passed through unchanged")
+			require.Equal(t, uint64(5), result.ID())
+		}
+		cancel()
+		wg.Wait()
+		require.Equal(t, int64(1), stats.TimedOut.Load())
+	}
+}
+
+func TestProcessorDeadlinePanic(t *testing.T) {
+	crash := transform.Func(func(values []int) ([]int, error) {
+		panic("crash")
+	})
+	p := New(0, crash, newOutPacket, nil, Pipes{}, Options{Deadline: time.Second})
+	require.Panics(t, func() {
+		_, _ = p.process(model.New([]int{1}))
+	}, "Check panic within the deadline is re-raised by the worker")
+}
+
+func TestProcessorDeadlineAbandoned(t *testing.T) {
+	release := make(chan struct{})
+	hang := transform.Func(func(values []int) ([]int, error) {
+		<-release
+		return values, nil
+	})
+	stats := metrics.NewStage(DefaultStage)
+	p := New(0, hang, newOutPacket, stats, Pipes{}, Options{Deadline: time.Millisecond, DeadlinePolicy: DeadlinePass, MaxAbandoned: 2})
+	for i := 0; i < 2; i++ {
+		_, err := p.process(model.New([]int{i}))
+		require.NoError(t, err)
+	}
+	require.Equal(t, int64(2), stats.Counter("abandoned").Load())
+
+	// Limit is reached, so the next processing is waited for
+	processed := make(chan struct{})
+	go func() {
+		defer close(processed)
+		_, _ = p.process(model.New([]int{2}))
+	}()
+	select {
+	case <-processed:
+		require.Fail(t, "Check processing is not abandoned beyond the limit")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	<-processed
+	require.Eventually(t, func() bool {
+		return stats.Counter("abandoned").Load() == 0
+	}, time.Second, time.Millisecond, "Check abandoned processings are counted until they finish")
+	require.Equal(t, int64(3), stats.TimedOut.Load())
+}
+
+func TestLatency(t *testing.T) {
+	require.Nil(t, newLatency(0), "Check slow packets log is disabled")
+
+	l := newLatency(0.9)
+	for i := 1; i <= latencyRefresh; i++ {
+		slow, _ := l.observe(time.Duration(i))
+		require.False(t, slow, "Check no slow packets until threshold is known")
+	}
+	slow, threshold := l.observe(time.Duration(95))
+	require.True(t, slow)
+	require.Equal(t, time.Duration(90), threshold)
+	slow, _ = l.observe(time.Duration(50))
+	require.False(t, slow)
+}
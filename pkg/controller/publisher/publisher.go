@@ -16,10 +16,11 @@ package publisher
 
 import (
 	"context"
+	"fmt"
 	"sync"
 	"time"
 
-	log "github.com/sirupsen/logrus"
+	"github.com/sunsingerus/pipeline/pkg/logger"
 )
 
 type accum interface {
@@ -35,6 +36,9 @@ type Options struct {
 // Publisher specifies publisher
 type Publisher struct {
 	accum accum
+	// reset receives new interval of the running publisher
+	reset chan time.Duration
+	log   *logger.Entry
 	Options
 }
 
@@ -42,6 +46,8 @@ type Publisher struct {
 func New(accum accum, opts Options) *Publisher {
 	return &Publisher{
 		accum:   accum,
+		reset:   make(chan time.Duration, 1),
+		log:     logger.Component("publisher"),
 		Options: opts,
 	}
 }
@@ -52,18 +58,40 @@ func (p *Publisher) Run(ctx context.Context, wg *sync.WaitGroup) {
 	if p == nil {
 		return
 	}
-	log.Infof("Publisher - start")
-	defer log.Infof("Publisher - end")
+	p.log.Info("start")
+	defer p.log.Info("end")
 
 	ticker := time.NewTicker(p.Options.Interval)
 	for {
 		select {
 		case <-ctx.Done():
 			ticker.Stop()
-			log.Infof("Publisher - done")
+			p.log.Info("done")
 			return
 		case at := <-ticker.C:
-			log.Infof("Publisher: %d @[%s]", p.accum.Get(), at)
+			p.log.Infof("Publisher: %d @[%s]", p.accum.Get(), at)
+		case interval := <-p.reset:
+			p.log.Infof("interval %s -> %s", p.Options.Interval, interval)
+			p.Options.Interval = interval
+			ticker.Reset(interval)
+		}
+	}
+}
+
+// SetInterval changes interval between publications of the running publisher
+func (p *Publisher) SetInterval(interval time.Duration) error {
+	if p == nil {
+		return fmt.Errorf("no publisher")
+	}
+	if interval <= 0 {
+		return fmt.Errorf("invalid interval %s", interval)
+	}
+	// Only the latest interval matters
+	for {
+		select {
+		case p.reset <- interval:
+			return nil
+		case <-p.reset:
 		}
 	}
 }
@@ -0,0 +1,152 @@
+// Copyright 2024 Vladislav Klimenko. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/sunsingerus/pipeline/pkg/controller/dlq"
+	"github.com/sunsingerus/pipeline/pkg/controller/generator/arrival"
+)
+
+// change specifies changed field of the configuration
+type change struct {
+	field    string
+	old, new any
+}
+
+func (c change) String() string {
+	return fmt.Sprintf("%s: %+v -> %+v", c.field, c.old, c.new)
+}
+
+// diff returns fields changed in the new configuration, in the order of declaration
+func diff(old, new Config) []change {
+	var changes []change
+	oldValue, newValue := reflect.ValueOf(old), reflect.ValueOf(new)
+	for i := 0; i < oldValue.NumField(); i++ {
+		if !reflect.DeepEqual(oldValue.Field(i).Interface(), newValue.Field(i).Interface()) {
+			changes = append(changes, change{
+				field: oldValue.Type().Field(i).Name,
+				old:   oldValue.Field(i).Interface(),
+				new:   newValue.Field(i).Interface(),
+			})
+		}
+	}
+	return changes
+}
+
+// reloader applies changed field of the new configuration to the running pipeline
+type reloader func(conf Config) error
+
+// reloaders returns reloaders of the fields, which can be changed while the pipeline runs.
+// Other fields need the pipeline to be rebuilt.
+func (c *Controller) reloaders() map[string]reloader {
+	return map[string]reloader{
+		"GeneratorIntervalMillisecond": func(conf Config) error {
+			if conf.GeneratorIntervalMillisecond <= 0 {
+				return fmt.Errorf("invalid interval %d", conf.GeneratorIntervalMillisecond)
+			}
+			interval := c.generatorInterval
+			c.generatorInterval = time.Duration(conf.GeneratorIntervalMillisecond) * time.Millisecond
+			_arrival, err := c.buildArrival()
+			if err != nil {
+				c.generatorInterval = interval
+				return err
+			}
+			c.generator.SetArrival(_arrival)
+			return nil
+		},
+		"Arrival": func(conf Config) error {
+			opts := c.arrival
+			c.arrival = conf.Arrival
+			_arrival, err := c.buildArrival()
+			if err != nil {
+				c.arrival = opts
+				return err
+			}
+			c.generator.SetArrival(_arrival)
+			return nil
+		},
+		"JoinIntervalMillisecond": func(conf Config) error {
+			if c.joinGenerator == nil {
+				return fmt.Errorf("no join")
+			}
+			if conf.JoinIntervalMillisecond <= 0 {
+				return fmt.Errorf("invalid interval %d", conf.JoinIntervalMillisecond)
+			}
+			c.joinInterval = time.Duration(conf.JoinIntervalMillisecond) * time.Millisecond
+			c.joinGenerator.SetArrival(arrival.NewRegular(c.joinInterval))
+			return nil
+		},
+		"PublisherIntervalSecond": func(conf Config) error {
+			interval := time.Duration(conf.PublisherIntervalSecond) * time.Second
+			if err := c.publisher.SetInterval(interval); err != nil {
+				return err
+			}
+			c.publisherInterval = interval
+			return nil
+		},
+		"WorkersNum": func(conf Config) error {
+			if err := c.chain.Resize(conf.WorkersNum); err != nil {
+				return err
+			}
+			c.workersNum = conf.WorkersNum
+			return nil
+		},
+		"DeadLetter": func(conf Config) error {
+			sink, err := dlq.New(conf.DeadLetter)
+			if err != nil {
+				return err
+			}
+			c.deadLetterOptions = conf.DeadLetter
+			if err := c.deadLetter.Swap(sink).Close(); err != nil {
+				log.Errorf("Unable to close replaced dead-letter sink: %v", err)
+			}
+			return nil
+		},
+	}
+}
+
+// Reload applies new configuration to the running pipeline.
+// Returns changes applied and changes rejected, either because they need restart or are invalid.
+func (c *Controller) Reload(conf Config) (applied, rejected []string) {
+	c.reloadMux.Lock()
+	defer c.reloadMux.Unlock()
+	if c.chain == nil {
+		return nil, []string{"pipeline is not running"}
+	}
+
+	reloaders := c.reloaders()
+	for _, change := range diff(c.config, conf) {
+		reload, ok := reloaders[change.field]
+		if !ok {
+			rejected = append(rejected, fmt.Sprintf("%s (needs restart)", change))
+			continue
+		}
+		if err := reload(conf); err != nil {
+			rejected = append(rejected, fmt.Sprintf("%s (%v)", change, err))
+			continue
+		}
+		// Applied field becomes part of the current configuration, so it is not reported as changed again
+		field := reflect.ValueOf(&c.config).Elem().FieldByName(change.field)
+		field.Set(reflect.ValueOf(conf).FieldByName(change.field))
+		applied = append(applied, change.String())
+	}
+	return applied, rejected
+}
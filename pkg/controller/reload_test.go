@@ -0,0 +1,109 @@
+// Copyright 2024 Vladislav Klimenko. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/sunsingerus/pipeline/pkg/controller/dlq"
+	"github.com/sunsingerus/pipeline/pkg/controller/generator/arrival"
+	"github.com/sunsingerus/pipeline/pkg/controller/generator/source"
+	"github.com/sunsingerus/pipeline/pkg/controller/join"
+	"github.com/sunsingerus/pipeline/pkg/controller/pool"
+)
+
+func newConfig() Config {
+	return Config{
+		GeneratorIntervalMillisecond: 10,
+		DeadLetter:                   dlq.Options{Kind: dlq.KindMemory},
+		PublisherIntervalSecond:      1,
+		PacketSizeIn:                 10,
+		PacketSizeOut:                3,
+		WorkersNum:                   2,
+		Seed:                         1,
+		ValueMax:                     9,
+	}
+}
+
+func TestDiff(t *testing.T) {
+	old := newConfig()
+	require.Empty(t, diff(old, old))
+
+	conf := old
+	conf.WorkersNum = 4
+	conf.Stages = "dedupe"
+	changes := diff(old, conf)
+	require.Len(t, changes, 2)
+	require.Equal(t, "Stages:  -> dedupe", changes[0].String())
+	require.Equal(t, "WorkersNum: 2 -> 4", changes[1].String())
+}
+
+func TestReload(t *testing.T) {
+	c := New(newConfig())
+	_, rejected := c.Reload(newConfig())
+	require.Len(t, rejected, 1, "Check configuration is not reloaded before the pipeline runs")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	wg, stop, err := c.Run(ctx)
+	require.NoError(t, err)
+
+	conf := newConfig()
+	conf.WorkersNum = 3
+	conf.PublisherIntervalSecond = 2
+	conf.JoinIntervalMillisecond = 5
+	conf.Seed = 2
+	applied, rejected := c.Reload(conf)
+	require.Equal(t, []string{"PublisherIntervalSecond: 1 -> 2", "WorkersNum: 2 -> 3"}, applied)
+	require.Equal(t, []string{"JoinIntervalMillisecond: 0 -> 5 (no join)", "Seed: 1 -> 2 (needs restart)"}, rejected)
+
+	// Applied changes are not reported again
+	applied, rejected = c.Reload(conf)
+	require.Empty(t, applied)
+	require.Len(t, rejected, 2)
+
+	cancel()
+	wg.Wait()
+	stop()
+}
+
+func TestRunInvalidConfig(t *testing.T) {
+	tests := []func(conf *Config){
+		func(conf *Config) { conf.ValueMin, conf.ValueMax = 9, 0 },
+		func(conf *Config) { conf.ShortPacketPolicy = "unknown" },
+		func(conf *Config) { conf.Arrival.Kind = arrival.KindSteps },
+		func(conf *Config) { conf.Source.Kind = "tpc" },
+		func(conf *Config) { conf.DeadLetter.Kind = "disk" },
+		func(conf *Config) { conf.Pool = pool.Options{Sharded: true, ShardKey: "unknown"} },
+		func(conf *Config) { conf.Stages = "topn(5)|unknown" },
+		func(conf *Config) { conf.Alert = "max>>15" },
+		func(conf *Config) { conf.Join = join.Options{Mode: "zip"} },
+		func(conf *Config) {
+			conf.Source = source.Options{Kind: source.KindStdin, RejectFile: filepath.Join(t.TempDir(), "missing", "reject")}
+		},
+	}
+	for i, misconfigure := range tests {
+		conf := newConfig()
+		misconfigure(&conf)
+		c := New(conf)
+		_, _, err := c.Run(context.Background())
+		require.Error(t, err, "Check misconfigured pipeline fails to run: %d", i)
+		_, rejected := c.Reload(newConfig())
+		require.Equal(t, []string{"pipeline is not running"}, rejected)
+	}
+}
@@ -0,0 +1,262 @@
+// Copyright 2024 Vladislav Klimenko. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/sunsingerus/pipeline/pkg/controller/health"
+	"github.com/sunsingerus/pipeline/pkg/controller/metrics"
+	"github.com/sunsingerus/pipeline/pkg/logger"
+)
+
+// ErrSkip is returned by stage function in case there is nothing to deliver for the input
+var ErrSkip = errors.New("skip")
+
+// Func processes input of the stage into its output
+type Func[In, Out any] func(in In) (Out, error)
+
+// DefaultComponent specifies component stages log as, unless specified
+const DefaultComponent = "stage"
+
+// Options specifies stage options
+type Options[In any] struct {
+	// Component specifies component stage logs as. Empty component means stage
+	Component string
+	// Workers specifies number of goroutines running the stage function. Zero means one
+	Workers int
+	// Quit stops workers once closed, ex.: when pool shrinks. Nil means workers run until context is done or input is closed
+	Quit <-chan struct{}
+	// Drop drops results in case output is not ready to accept them, instead of waiting for it
+	Drop bool
+	// OnSkip is called for input stage function skipped
+	OnSkip func(ctx context.Context, worker int, in In)
+	// OnError is called for input stage function failed to process. Errors are only logged in case it is nil
+	OnError func(ctx context.Context, worker int, in In, err error)
+	// OnDrop is called for input, which result is dropped
+	OnDrop func(ctx context.Context, worker int, in In)
+	// Health specifies monitor workers report heartbeats to as stage of the stage name. Nil means no heartbeats
+	Health *health.Monitor
+}
+
+// Stage runs function over its input and delivers results to its output.
+// Output and input types of connected stages are checked at compile time
+type Stage[In, Out any] struct {
+	name  string
+	fn    Func[In, Out]
+	stats *metrics.Stage
+	in    <-chan In
+	// out specifies where results are delivered. Results are discarded in case it is nil, as by a sink
+	out chan<- Out
+	log *logger.Entry
+	Options[In]
+}
+
+// New creates new stage. Stats can be nil
+func New[In, Out any](name string, fn Func[In, Out], stats *metrics.Stage, in <-chan In, out chan<- Out, opts Options[In]) *Stage[In, Out] {
+	if opts.Workers <= 0 {
+		opts.Workers = 1
+	}
+	if opts.Component == "" {
+		opts.Component = DefaultComponent
+	}
+	if stats == nil {
+		stats = metrics.NewStage(name)
+	}
+	return &Stage[In, Out]{
+		name:    name,
+		fn:      fn,
+		stats:   stats,
+		in:      in,
+		out:     out,
+		log:     logger.Component(opts.Component).WithField(logger.FieldStage, name),
+		Options: opts,
+	}
+}
+
+// NewSink creates new stage, which consumes its input. Stats can be nil
+func NewSink[In any](name string, fn func(in In) error, stats *metrics.Stage, in <-chan In, opts Options[In]) *Stage[In, struct{}] {
+	return New[In, struct{}](
+		name,
+		func(in In) (struct{}, error) {
+			return struct{}{}, fn(in)
+		},
+		stats,
+		in,
+		nil,
+		opts,
+	)
+}
+
+// Run runs stage workers until context is done or input is closed
+func (s *Stage[In, Out]) Run(ctx context.Context, wg *sync.WaitGroup) {
+	defer wg.Done()
+	if s == nil {
+		return
+	}
+	s.log.Info("start")
+	defer s.log.Info("end")
+
+	workers := new(sync.WaitGroup)
+	for i := 0; i < s.Options.Workers; i++ {
+		workers.Add(1)
+		go func(worker int) {
+			defer workers.Done()
+			s.Work(ctx, worker)
+		}(i)
+	}
+	workers.Wait()
+}
+
+// Work runs stage function over the input as the worker until context is done, input is closed or quit is closed.
+// Stage workers may be launched by the caller instead of Run, ex.: to be supervised
+func (s *Stage[In, Out]) Work(ctx context.Context, worker int) {
+	_log := s.log.WithField(logger.FieldWorkerID, worker)
+	s.Options.Health.Start(s.name)
+	defer s.Options.Health.Stop(s.name)
+
+	for {
+		in, ok := s.receive(ctx)
+		if !ok {
+			_log.Info("done")
+			return
+		}
+		if !s.handle(ctx, _log, worker, in) {
+			return
+		}
+	}
+}
+
+// receive waits for input. Returns false in case context is done, quit is closed or input is closed
+func (s *Stage[In, Out]) receive(ctx context.Context) (In, bool) {
+	select {
+	case <-ctx.Done():
+	case <-s.Options.Quit:
+	case in, ok := <-s.in:
+		return in, ok
+	}
+	var zero In
+	return zero, false
+}
+
+// handle runs stage function over the input and delivers the result. Returns false in case context is done
+func (s *Stage[In, Out]) handle(ctx context.Context, _log *logger.Entry, worker int, in In) bool {
+	s.stats.Received.Add(1)
+	s.stats.Busy.Add(1)
+	s.Options.Health.Begin(s.name)
+	// Stage function may panic, ex.: supervised worker
+	defer func() {
+		s.stats.Busy.Add(-1)
+		s.Options.Health.End(s.name)
+	}()
+	// Values are described for debug logs only, as describing them costs more than their processing
+	debug := _log.Logger.IsLevelEnabled(log.DebugLevel)
+	if _log.Logger.IsLevelEnabled(log.TraceLevel) {
+		describe(_log, in).Tracef("received: %v", in)
+	}
+
+	out, err := s.fn(in)
+	switch {
+	case errors.Is(err, ErrSkip):
+		s.stats.Filtered.Add(1)
+		if debug {
+			describe(_log, in).Debug("skipped")
+		}
+		if s.Options.OnSkip != nil {
+			s.Options.OnSkip(ctx, worker, in)
+		}
+		return true
+	case err != nil:
+		s.stats.Rejected.Add(1)
+		describe(_log, in).Warnf("rejected: %v reason: %v", in, err)
+		if s.Options.OnError != nil {
+			s.Options.OnError(ctx, worker, in, err)
+		}
+		return true
+	}
+
+	if s.out == nil {
+		return true
+	}
+	// Output may be modified by the next stage once delivered, so it is described beforehand
+	entry, str := _log, ""
+	if debug {
+		entry, str = describe(_log, out), fmt.Sprint(out)
+	}
+	if s.Options.Drop {
+		select {
+		case s.out <- out:
+			s.stats.Delivered.Add(1)
+			entry.Debugf("delivered: %s", str)
+		default:
+			// Drop happens on overload, so drops are counted and logged for debug only
+			s.stats.Dropped.Add(1)
+			entry.Debugf("dropped: %s", str)
+			if s.Options.OnDrop != nil {
+				s.Options.OnDrop(ctx, worker, in)
+			}
+		}
+		return true
+	}
+	s.Options.Health.BeginSend(s.name)
+	delivered := Deliver(ctx, s.out, out)
+	s.Options.Health.EndSend(s.name)
+	if !delivered {
+		entry.Debugf("NODELIVERY: %s", str)
+		return false
+	}
+	s.stats.Delivered.Add(1)
+	entry.Debugf("delivered: %s", str)
+	return true
+}
+
+// identified is implemented by values with ids, as packets
+type identified interface {
+	ID() uint64
+}
+
+// describe returns logger of the value, which has id field for identified values
+func describe(entry *logger.Entry, value any) *logger.Entry {
+	if v, ok := value.(identified); ok {
+		return entry.WithField(logger.FieldPacketID, v.ID())
+	}
+	return entry
+}
+
+// Receive waits for value from the channel. Returns false in case context is done or channel is closed
+func Receive[T any](ctx context.Context, in <-chan T) (T, bool) {
+	select {
+	case <-ctx.Done():
+		var zero T
+		return zero, false
+	case value, ok := <-in:
+		return value, ok
+	}
+}
+
+// Deliver waits for the channel to accept value. Returns false in case context is done
+func Deliver[T any](ctx context.Context, out chan<- T, value T) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case out <- value:
+		return true
+	}
+}
@@ -0,0 +1,194 @@
+// Copyright 2024 Vladislav Klimenko. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/sunsingerus/pipeline/pkg/controller/metrics"
+	"github.com/sunsingerus/pipeline/pkg/controller/packet"
+	"github.com/sunsingerus/pipeline/pkg/logger"
+	model "github.com/sunsingerus/pipeline/pkg/model/packet"
+)
+
+func TestStage(t *testing.T) {
+	packets := make(chan packet.Packet)
+	sums := make(chan int)
+	labels := make(chan string)
+
+	var rejected []int
+	var mux sync.Mutex
+	sum := New[packet.Packet, int](
+		"sum",
+		func(pack packet.Packet) (int, error) {
+			if pack.Len() == 0 {
+				return 0, ErrSkip
+			}
+			sum := 0
+			for _, value := range pack.Slice() {
+				sum += value
+			}
+			return sum, nil
+		},
+		nil,
+		packets,
+		sums,
+		Options[packet.Packet]{Workers: 3},
+	)
+	stats := metrics.NewStage("label")
+	label := New[int, string](
+		"label",
+		func(sum int) (string, error) {
+			if sum < 0 {
+				return "", fmt.Errorf("negative sum")
+			}
+			return "sum=" + strconv.Itoa(sum), nil
+		},
+		stats,
+		sums,
+		labels,
+		Options[int]{
+			OnError: func(ctx context.Context, worker int, sum int, err error) {
+				mux.Lock()
+				defer mux.Unlock()
+				rejected = append(rejected, sum)
+			},
+		},
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := &sync.WaitGroup{}
+	wg.Add(2)
+	go sum.Run(ctx, wg)
+	go label.Run(ctx, wg)
+
+	go func() {
+		for _, values := range [][]int{{1, 2}, {}, {-5}, {3, 4}} {
+			packets <- model.New(values)
+		}
+	}()
+	var results []string
+	for len(results) < 2 {
+		results = append(results, <-labels)
+	}
+	sort.Strings(results)
+	require.Equal(t, []string{"sum=3", "sum=7"}, results)
+	// Negative sum may still be on its way, since workers deliver in any order
+	require.Eventually(t, func() bool { return stats.Rejected.Load() == 1 }, time.Second, time.Millisecond)
+
+	cancel()
+	wg.Wait()
+	require.Equal(t, int64(1), sum.stats.Filtered.Load())
+	require.Equal(t, int64(3), sum.stats.Delivered.Load())
+	require.Equal(t, int64(1), stats.Rejected.Load())
+	require.Equal(t, []int{-5}, rejected)
+}
+
+func TestSink(t *testing.T) {
+	in := make(chan int)
+	total := 0
+	sink := NewSink[int]("sink", func(value int) error {
+		total += value
+		return nil
+	}, nil, in, Options[int]{})
+
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	go sink.Run(context.Background(), wg)
+	in <- 1
+	in <- 2
+	// Sink stops once its input is closed
+	close(in)
+	wg.Wait()
+	require.Equal(t, 3, total)
+}
+
+func TestDescribe(t *testing.T) {
+	pack := model.New([]int{1, 2})
+	pack.SetID(7)
+	entry := logger.Component(DefaultComponent)
+	require.Equal(t, uint64(7), describe(entry, pack).Data[logger.FieldPacketID])
+	require.NotContains(t, describe(entry, 7).Data, logger.FieldPacketID)
+}
+
+// description counts how many times it is described
+type description struct {
+	count *atomic.Int32
+}
+
+func (d description) String() string {
+	d.count.Add(1)
+	return "description"
+}
+
+func TestStageDescribeDebugOnly(t *testing.T) {
+	count := &atomic.Int32{}
+	in := make(chan int)
+	out := make(chan description)
+	s := New[int, description]("describe", func(int) (description, error) {
+		return description{count: count}, nil
+	}, nil, in, out, Options[int]{Component: "describe"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	go s.Run(ctx, wg)
+
+	require.NoError(t, logger.SetLevel("describe", "info"))
+	in <- 1
+	<-out
+	require.Equal(t, int32(0), count.Load(), "Check output is not described with debug off")
+
+	require.NoError(t, logger.SetLevel("describe", "debug"))
+	defer logger.ResetLevel("describe")
+	in <- 2
+	<-out
+	require.Equal(t, int32(1), count.Load(), "Check output is described with debug on")
+}
+
+func TestStageDropDebugOnly(t *testing.T) {
+	count := &atomic.Int32{}
+	in := make(chan int)
+	// Nobody receives the output, so all of it is dropped
+	out := make(chan description)
+	stats := metrics.NewStage("drop")
+	s := New[int, description]("drop", func(int) (description, error) {
+		return description{count: count}, nil
+	}, stats, in, out, Options[int]{Component: "drop", Drop: true})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	go s.Run(ctx, wg)
+
+	require.NoError(t, logger.SetLevel("drop", "info"))
+	defer logger.ResetLevel("drop")
+	for i := 0; i < 10; i++ {
+		in <- i
+	}
+	require.Eventually(t, func() bool { return stats.Dropped.Load() == 10 }, time.Second, time.Millisecond)
+	require.Equal(t, int32(0), count.Load(), "Check dropped output is not described with debug off")
+}
@@ -0,0 +1,155 @@
+// Copyright 2024 Vladislav Klimenko. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package supervisor
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Defaults
+const (
+	DefaultBackoffMin  = 100 * time.Millisecond
+	DefaultBackoffMax  = 10 * time.Second
+	DefaultMaxRestarts = 5
+)
+
+// window specifies period restarts are counted within
+const window = time.Minute
+
+// Options specifies supervision options
+type Options struct {
+	// BackoffMin specifies delay before the first restart. Delay doubles with each restart within a minute
+	BackoffMin time.Duration
+	// BackoffMax specifies max delay before restart
+	BackoffMax time.Duration
+	// MaxRestarts specifies max number of restarts of a task per minute. Supervisor escalates on exceeding it
+	MaxRestarts int
+}
+
+// Task specifies supervised task, which runs until context is done
+type Task func(ctx context.Context)
+
+// Describe describes what the task was busy with, ex.: packet in-flight. May return nil
+type Describe func() fmt.Stringer
+
+// Supervisor recovers panics of supervised tasks and restarts them
+type Supervisor struct {
+	// escalate is called when a task crash-loops
+	escalate func(err error)
+	once     sync.Once
+	Options
+}
+
+// New creates new supervisor. Escalate is called once in case any task crash-loops
+func New(escalate func(err error), opts Options) *Supervisor {
+	if opts.BackoffMin <= 0 {
+		opts.BackoffMin = DefaultBackoffMin
+	}
+	if opts.BackoffMax < opts.BackoffMin {
+		opts.BackoffMax = DefaultBackoffMax
+	}
+	if opts.MaxRestarts <= 0 {
+		opts.MaxRestarts = DefaultMaxRestarts
+	}
+	return &Supervisor{
+		escalate: escalate,
+		Options:  opts,
+	}
+}
+
+// Run runs the task until it returns normally or context is done, restarting the task after each panic.
+// Nil supervisor runs the task once without supervision.
+func (s *Supervisor) Run(ctx context.Context, name string, task Task, describe Describe) {
+	if s == nil {
+		task(ctx)
+		return
+	}
+
+	var restarts []time.Time
+	for {
+		crashed := s.run(ctx, name, task, describe)
+		if !crashed || (ctx.Err() != nil) {
+			return
+		}
+
+		// Count restarts within the window only
+		now := time.Now()
+		for len(restarts) > 0 && now.Sub(restarts[0]) > window {
+			restarts = restarts[1:]
+		}
+		restarts = append(restarts, now)
+		if len(restarts) > s.Options.MaxRestarts {
+			err := fmt.Errorf("%s crash-loops: %d restarts within %s", name, len(restarts)-1, window)
+			log.Errorf("Supervisor - %v, escalate", err)
+			s.once.Do(func() {
+				if s.escalate != nil {
+					s.escalate(err)
+				}
+			})
+			return
+		}
+
+		backoff := s.backoff(len(restarts))
+		log.Warnf("Supervisor - restart %s in %s", name, backoff)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+	}
+}
+
+// Go runs the task in a goroutine, tracked by the wait group
+func (s *Supervisor) Go(ctx context.Context, wg *sync.WaitGroup, name string, task Task, describe Describe) {
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		s.Run(ctx, name, task, describe)
+	}()
+}
+
+// run runs the task once. Returns true in case the task panicked
+func (s *Supervisor) run(ctx context.Context, name string, task Task, describe Describe) (crashed bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			crashed = true
+			var what fmt.Stringer
+			if describe != nil {
+				what = describe()
+			}
+			log.Errorf("Supervisor - %s panic: %v in-flight: %v\n%s", name, r, what, debug.Stack())
+		}
+	}()
+	task(ctx)
+	return false
+}
+
+// backoff returns delay before n-th restart
+func (s *Supervisor) backoff(n int) time.Duration {
+	backoff := s.Options.BackoffMin
+	for i := 1; (i < n) && (backoff < s.Options.BackoffMax); i++ {
+		backoff *= 2
+	}
+	if backoff > s.Options.BackoffMax {
+		backoff = s.Options.BackoffMax
+	}
+	return backoff
+}
@@ -0,0 +1,88 @@
+// Copyright 2024 Vladislav Klimenko. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package supervisor
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type inFlight string
+
+func (s inFlight) String() string {
+	return string(s)
+}
+
+func TestRestart(t *testing.T) {
+	escalated := false
+	s := New(func(error) { escalated = true }, Options{BackoffMin: time.Millisecond, BackoffMax: 2 * time.Millisecond, MaxRestarts: 5})
+
+	runs := 0
+	s.Run(context.Background(), "task", func(ctx context.Context) {
+		runs++
+		if runs < 3 {
+			panic(fmt.Sprintf("crash %d", runs))
+		}
+	}, func() fmt.Stringer { return inFlight("packet") })
+
+	require.Equal(t, 3, runs, "Check task is restarted until it returns normally")
+	require.False(t, escalated)
+}
+
+func TestEscalate(t *testing.T) {
+	var escalated []error
+	s := New(func(err error) { escalated = append(escalated, err) }, Options{BackoffMin: time.Millisecond, BackoffMax: time.Millisecond, MaxRestarts: 2})
+
+	for i := 0; i < 2; i++ {
+		runs := 0
+		s.Run(context.Background(), "task", func(ctx context.Context) {
+			runs++
+			panic("crash")
+		}, nil)
+		require.Equal(t, 3, runs, "Check task is restarted max restarts times")
+	}
+	require.Len(t, escalated, 1, "Check supervisor escalates once")
+}
+
+func TestContextDone(t *testing.T) {
+	s := New(nil, Options{BackoffMin: time.Hour})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.Run(ctx, "task", func(ctx context.Context) {
+			panic("crash")
+		}, nil)
+	}()
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		require.Fail(t, "Check backoff is interrupted by context done")
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	s := New(nil, Options{BackoffMin: time.Second, BackoffMax: 5 * time.Second})
+	require.Equal(t, time.Second, s.backoff(1))
+	require.Equal(t, 2*time.Second, s.backoff(2))
+	require.Equal(t, 4*time.Second, s.backoff(3))
+	require.Equal(t, 5*time.Second, s.backoff(4))
+	require.Equal(t, 5*time.Second, s.backoff(40))
+}
@@ -0,0 +1,131 @@
+// Copyright 2024 Vladislav Klimenko. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package topology
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/sunsingerus/pipeline/pkg/controller/packet"
+)
+
+// Predicate reports whether packet matches
+type Predicate func(packet.Packet) bool
+
+// Available predicate keys
+const (
+	KeyFirst = "first"
+	KeyLast  = "last"
+	KeyMin   = "min"
+	KeyMax   = "max"
+	KeySum   = "sum"
+	KeyLen   = "len"
+	KeyID    = "id"
+)
+
+// keyFunc extracts key from the packet. Returns false in case packet has no such key, as empty packet has no max
+type keyFunc func(packet.Packet) (int, bool)
+
+var keys = map[string]keyFunc{
+	KeyFirst: func(pack packet.Packet) (int, bool) {
+		if pack.Len() == 0 {
+			return 0, false
+		}
+		return pack.Get(0), true
+	},
+	KeyLast: func(pack packet.Packet) (int, bool) {
+		if pack.Len() == 0 {
+			return 0, false
+		}
+		return pack.Get(pack.Len() - 1), true
+	},
+	KeyMin: func(pack packet.Packet) (int, bool) {
+		if pack.Len() == 0 {
+			return 0, false
+		}
+		min := pack.Get(0)
+		for i := 1; i < pack.Len(); i++ {
+			if pack.Get(i) < min {
+				min = pack.Get(i)
+			}
+		}
+		return min, true
+	},
+	KeyMax: func(pack packet.Packet) (int, bool) {
+		if pack.Len() == 0 {
+			return 0, false
+		}
+		max := pack.Get(0)
+		for i := 1; i < pack.Len(); i++ {
+			if pack.Get(i) > max {
+				max = pack.Get(i)
+			}
+		}
+		return max, true
+	},
+	KeySum: func(pack packet.Packet) (int, bool) {
+		sum := 0
+		for i := 0; i < pack.Len(); i++ {
+			sum += pack.Get(i)
+		}
+		return sum, true
+	},
+	KeyLen: func(pack packet.Packet) (int, bool) {
+		return pack.Len(), true
+	},
+	KeyID: func(pack packet.Packet) (int, bool) {
+		return int(pack.ID()), true
+	},
+}
+
+// operators are listed so that longer operators are matched first
+var operators = []struct {
+	op      string
+	compare func(a, b int) bool
+}{
+	{">=", func(a, b int) bool { return a >= b }},
+	{"<=", func(a, b int) bool { return a <= b }},
+	{"==", func(a, b int) bool { return a == b }},
+	{"!=", func(a, b int) bool { return a != b }},
+	{">", func(a, b int) bool { return a > b }},
+	{"<", func(a, b int) bool { return a < b }},
+}
+
+// ParsePredicate parses predicate comparing packet key with the value, ex.: "max>15".
+// Keys: first,last,min,max,sum,len,id. Operators: >,>=,<,<=,==,!=
+func ParsePredicate(spec string) (Predicate, error) {
+	for _, operator := range operators {
+		name, str, found := strings.Cut(spec, operator.op)
+		if !found {
+			continue
+		}
+		name = strings.ToLower(strings.TrimSpace(name))
+		key, ok := keys[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown key %q of predicate %q", name, spec)
+		}
+		value, err := strconv.Atoi(strings.TrimSpace(str))
+		if err != nil {
+			return nil, fmt.Errorf("invalid value %q of predicate %q", str, spec)
+		}
+		compare := operator.compare
+		return func(pack packet.Packet) bool {
+			k, ok := key(pack)
+			return ok && compare(k, value)
+		}, nil
+	}
+	return nil, fmt.Errorf("no operator in predicate %q", spec)
+}
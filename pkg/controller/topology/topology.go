@@ -0,0 +1,322 @@
+// Copyright 2024 Vladislav Klimenko. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package topology
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/sunsingerus/pipeline/pkg/controller/metrics"
+	"github.com/sunsingerus/pipeline/pkg/controller/packet"
+	"github.com/sunsingerus/pipeline/pkg/controller/stage"
+	"github.com/sunsingerus/pipeline/pkg/logger"
+)
+
+// Pipes specifies channels topology node reads packets from and delivers packets to
+type Pipes struct {
+	In  []chan packet.Packet
+	Out []chan packet.Packet
+}
+
+// check checks number of node channels
+func (p Pipes) check(kind string, minIn, maxIn, minOut, maxOut int) error {
+	if (len(p.In) < minIn) || (len(p.In) > maxIn) {
+		return fmt.Errorf("%s has %d inputs", kind, len(p.In))
+	}
+	if (len(p.Out) < minOut) || (len(p.Out) > maxOut) {
+		return fmt.Errorf("%s has %d outputs", kind, len(p.Out))
+	}
+	return nil
+}
+
+// unlimited specifies no limit on number of node channels
+const unlimited = int(^uint(0) >> 1)
+
+// PacketConstructor creates packet, copies of packets are made by
+type PacketConstructor func([]int) packet.Packet
+
+// Copy makes a copy of the packet, which shares no values with the original
+func Copy(pack packet.Packet, packetConstructor PacketConstructor) packet.Packet {
+	_copy := packetConstructor(append([]int(nil), pack.Slice()...))
+	_copy.SetID(pack.ID())
+	return _copy
+}
+
+// Component specifies component topology nodes log as
+const Component = "topology"
+
+// node specifies common part of topology nodes
+type node struct {
+	name  string
+	stats *metrics.Stage
+	log   *logger.Entry
+	Pipes
+}
+
+func newNode(name string, stats *metrics.Stage, pipes Pipes) node {
+	if stats == nil {
+		stats = metrics.NewStage(name)
+	}
+	return node{
+		name:  name,
+		stats: stats,
+		log:   logger.Component(Component).WithField(logger.FieldStage, name),
+		Pipes: pipes,
+	}
+}
+
+// receive waits for packet from the input. Returns false in case context is done or input is closed
+func (n *node) receive(ctx context.Context, in chan packet.Packet) (packet.Packet, bool) {
+	pack, ok := stage.Receive[packet.Packet](ctx, in)
+	if !ok {
+		n.log.Info("done")
+		return nil, false
+	}
+	n.stats.Received.Add(1)
+	if n.log.Logger.IsLevelEnabled(log.TraceLevel) {
+		n.log.WithField(logger.FieldPacketID, pack.ID()).Tracef("received: %s", pack)
+	}
+	return pack, true
+}
+
+// deliver delivers packet to the output. Returns false in case context is done
+func (n *node) deliver(ctx context.Context, out chan packet.Packet, pack packet.Packet) bool {
+	// Receiver owns the packet once it is delivered, so it is described beforehand for debug logs
+	entry, str := n.log, ""
+	if n.log.Logger.IsLevelEnabled(log.DebugLevel) {
+		entry, str = n.log.WithField(logger.FieldPacketID, pack.ID()), pack.String()
+	}
+	if !stage.Deliver[packet.Packet](ctx, out, pack) {
+		entry.Debugf("NODELIVERY: %s", str)
+		return false
+	}
+	n.stats.Delivered.Add(1)
+	entry.Debugf("delivered: %s", str)
+	return true
+}
+
+// Broadcast delivers each packet to all its outputs. Each output gets its own copy of the packet
+type Broadcast struct {
+	node
+	packetConstructor PacketConstructor
+}
+
+// NewBroadcast creates new broadcast of one input to a number of outputs. Stats can be nil
+func NewBroadcast(name string, packetConstructor PacketConstructor, stats *metrics.Stage, pipes Pipes) (*Broadcast, error) {
+	if err := pipes.check("broadcast", 1, 1, 1, unlimited); err != nil {
+		return nil, err
+	}
+	return &Broadcast{
+		node:              newNode(name, stats, pipes),
+		packetConstructor: packetConstructor,
+	}, nil
+}
+
+// Run runs broadcast until context is done
+func (b *Broadcast) Run(ctx context.Context, wg *sync.WaitGroup) {
+	defer wg.Done()
+	if b == nil {
+		return
+	}
+	b.log.Info("start")
+	defer b.log.Info("end")
+
+	for {
+		pack, ok := b.receive(ctx, b.Pipes.In[0])
+		if !ok {
+			return
+		}
+		last := len(b.Pipes.Out) - 1
+		for i, out := range b.Pipes.Out {
+			// The last output gets the original packet, others get copies
+			_pack := pack
+			if i < last {
+				_pack = Copy(pack, b.packetConstructor)
+			}
+			if !b.deliver(ctx, out, _pack) {
+				return
+			}
+		}
+	}
+}
+
+// RoundRobin delivers packets to its outputs in turn
+type RoundRobin struct {
+	node
+	next int
+}
+
+// NewRoundRobin creates new round-robin split of one input to a number of outputs. Stats can be nil
+func NewRoundRobin(name string, stats *metrics.Stage, pipes Pipes) (*RoundRobin, error) {
+	if err := pipes.check("round-robin", 1, 1, 1, unlimited); err != nil {
+		return nil, err
+	}
+	return &RoundRobin{
+		node: newNode(name, stats, pipes),
+	}, nil
+}
+
+// Run runs round-robin split until context is done
+func (r *RoundRobin) Run(ctx context.Context, wg *sync.WaitGroup) {
+	defer wg.Done()
+	if r == nil {
+		return
+	}
+	r.log.Info("start")
+	defer r.log.Info("end")
+
+	for {
+		pack, ok := r.receive(ctx, r.Pipes.In[0])
+		if !ok {
+			return
+		}
+		out := r.Pipes.Out[r.next]
+		r.next = (r.next + 1) % len(r.Pipes.Out)
+		if !r.deliver(ctx, out, pack) {
+			return
+		}
+	}
+}
+
+// Router delivers each packet to the output of the first predicate it matches.
+// Packets matching none of the predicates are delivered to the extra default output, if any, or dropped.
+type Router struct {
+	node
+	predicates []Predicate
+}
+
+// NewRouter creates new router of one input to the outputs of the predicates. Stats can be nil
+func NewRouter(name string, predicates []Predicate, stats *metrics.Stage, pipes Pipes) (*Router, error) {
+	if err := pipes.check("router", 1, 1, len(predicates), len(predicates)+1); err != nil {
+		return nil, err
+	}
+	return &Router{
+		node:       newNode(name, stats, pipes),
+		predicates: predicates,
+	}, nil
+}
+
+// route returns output of the packet, nil means packet is dropped
+func (r *Router) route(pack packet.Packet) chan packet.Packet {
+	for i, predicate := range r.predicates {
+		if predicate(pack) {
+			return r.Pipes.Out[i]
+		}
+	}
+	if len(r.Pipes.Out) > len(r.predicates) {
+		return r.Pipes.Out[len(r.predicates)]
+	}
+	return nil
+}
+
+// Run runs router until context is done
+func (r *Router) Run(ctx context.Context, wg *sync.WaitGroup) {
+	defer wg.Done()
+	if r == nil {
+		return
+	}
+	r.log.Info("start")
+	defer r.log.Info("end")
+
+	for {
+		pack, ok := r.receive(ctx, r.Pipes.In[0])
+		if !ok {
+			return
+		}
+		out := r.route(pack)
+		if out == nil {
+			r.stats.Dropped.Add(1)
+			if r.log.Logger.IsLevelEnabled(log.DebugLevel) {
+				r.log.WithField(logger.FieldPacketID, pack.ID()).Debugf("dropped: %s", pack)
+			}
+			continue
+		}
+		if !r.deliver(ctx, out, pack) {
+			return
+		}
+	}
+}
+
+// Merge delivers packets of all its inputs to the single output
+type Merge struct {
+	node
+}
+
+// NewMerge creates new merge of a number of inputs to one output. Stats can be nil
+func NewMerge(name string, stats *metrics.Stage, pipes Pipes) (*Merge, error) {
+	if err := pipes.check("merge", 1, unlimited, 1, 1); err != nil {
+		return nil, err
+	}
+	return &Merge{
+		node: newNode(name, stats, pipes),
+	}, nil
+}
+
+// Run runs merge until context is done or all inputs are closed
+func (m *Merge) Run(ctx context.Context, wg *sync.WaitGroup) {
+	defer wg.Done()
+	if m == nil {
+		return
+	}
+	m.log.Info("start")
+	defer m.log.Info("end")
+
+	inputs := new(sync.WaitGroup)
+	for _, in := range m.Pipes.In {
+		inputs.Add(1)
+		go func(in chan packet.Packet) {
+			defer inputs.Done()
+			for {
+				pack, ok := m.receive(ctx, in)
+				if !ok {
+					return
+				}
+				if !m.deliver(ctx, m.Pipes.Out[0], pack) {
+					return
+				}
+			}
+		}(in)
+	}
+	inputs.Wait()
+}
+
+// Sink consumes packets of its input
+type Sink struct {
+	*stage.Stage[packet.Packet, struct{}]
+}
+
+// NewSink creates new sink, which calls consume for each packet of its input. Stats can be nil
+func NewSink(name string, consume func(packet.Packet), stats *metrics.Stage, pipes Pipes) (*Sink, error) {
+	if err := pipes.check("sink", 1, 1, 0, 0); err != nil {
+		return nil, err
+	}
+	return &Sink{
+		Stage: stage.NewSink[packet.Packet](
+			name,
+			func(pack packet.Packet) error {
+				consume(pack)
+				return nil
+			},
+			stats,
+			pipes.In[0],
+			stage.Options[packet.Packet]{
+				Component: Component,
+			},
+		),
+	}, nil
+}
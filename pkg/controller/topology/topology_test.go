@@ -0,0 +1,195 @@
+// Copyright 2024 Vladislav Klimenko. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package topology
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/sunsingerus/pipeline/pkg/controller/metrics"
+	"github.com/sunsingerus/pipeline/pkg/controller/packet"
+	model "github.com/sunsingerus/pipeline/pkg/model/packet"
+)
+
+func newPacket(slice []int) packet.Packet {
+	return model.New(slice)
+}
+
+func makeChannels(n int) []chan packet.Packet {
+	channels := make([]chan packet.Packet, n)
+	for i := range channels {
+		channels[i] = make(chan packet.Packet)
+	}
+	return channels
+}
+
+// component specifies topology node
+type component interface {
+	Run(ctx context.Context, wg *sync.WaitGroup)
+}
+
+// run runs node until returned func is called
+func run(node component) func() {
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	go node.Run(ctx, wg)
+	return func() {
+		cancel()
+		wg.Wait()
+	}
+}
+
+func TestBroadcast(t *testing.T) {
+	pipes := Pipes{In: makeChannels(1), Out: makeChannels(2)}
+	broadcast, err := NewBroadcast("broadcast", newPacket, nil, pipes)
+	require.NoError(t, err)
+	stop := run(broadcast)
+	defer stop()
+
+	pack := newPacket([]int{1, 2})
+	pack.SetID(3)
+	pipes.In[0] <- pack
+	first := <-pipes.Out[0]
+	second := <-pipes.Out[1]
+
+	// Mutation in one branch does not affect another
+	first.Set(0, 100)
+	require.Equal(t, "[100,2]", first.String())
+	require.Equal(t, "[1,2]", second.String())
+	require.Equal(t, uint64(3), first.ID())
+	require.Equal(t, uint64(3), second.ID())
+}
+
+func TestRoundRobin(t *testing.T) {
+	pipes := Pipes{In: makeChannels(1), Out: makeChannels(3)}
+	roundRobin, err := NewRoundRobin("split", nil, pipes)
+	require.NoError(t, err)
+	stop := run(roundRobin)
+	defer stop()
+
+	for i := 0; i < 6; i++ {
+		pack := newPacket([]int{i})
+		go func() { pipes.In[0] <- pack }()
+		require.Equal(t, pack, <-pipes.Out[i%3], "Check packet %d is delivered in turn", i)
+	}
+}
+
+func TestRouter(t *testing.T) {
+	alerts, err := ParsePredicate("max>15")
+	require.NoError(t, err)
+	empty, err := ParsePredicate("len==0")
+	require.NoError(t, err)
+
+	tests := []struct {
+		outputs int
+		input   []int
+		expect  int
+	}{
+		{outputs: 3, input: []int{1, 16}, expect: 0},
+		{outputs: 3, input: []int{}, expect: 1},
+		{outputs: 3, input: []int{1, 15}, expect: 2},
+		{outputs: 2, input: []int{1, 15}, expect: -1},
+	}
+	for _, tt := range tests {
+		pipes := Pipes{In: makeChannels(1), Out: makeChannels(tt.outputs)}
+		stats := metrics.NewStage("router")
+		router, err := NewRouter("router", []Predicate{alerts, empty}, stats, pipes)
+		require.NoError(t, err)
+		out := router.route(newPacket(tt.input))
+		if tt.expect < 0 {
+			require.Nil(t, out, "Check packet is dropped: %v", tt.input)
+			continue
+		}
+		require.Equal(t, pipes.Out[tt.expect], out, "Check route: %v", tt.input)
+	}
+
+	_, err = NewRouter("router", []Predicate{alerts, empty}, nil, Pipes{In: makeChannels(1), Out: makeChannels(1)})
+	require.Error(t, err, "Check each predicate has output")
+}
+
+func TestMerge(t *testing.T) {
+	pipes := Pipes{In: makeChannels(3), Out: makeChannels(1)}
+	stats := metrics.NewStage("merge")
+	merge, err := NewMerge("merge", stats, pipes)
+	require.NoError(t, err)
+	stop := run(merge)
+	defer stop()
+
+	for i, in := range pipes.In {
+		go func(i int, in chan packet.Packet) {
+			for j := 0; j < 10; j++ {
+				in <- newPacket([]int{i*10 + j})
+			}
+		}(i, in)
+	}
+	var values []int
+	for i := 0; i < 30; i++ {
+		values = append(values, (<-pipes.Out[0]).Get(0))
+	}
+	sort.Ints(values)
+	for i := range values {
+		require.Equal(t, i, values[i])
+	}
+	require.Eventually(t, func() bool { return stats.Delivered.Load() == 30 }, time.Second, time.Millisecond)
+}
+
+func TestSink(t *testing.T) {
+	pipes := Pipes{In: makeChannels(1)}
+	consumed := make(chan packet.Packet, 1)
+	sink, err := NewSink("sink", func(pack packet.Packet) { consumed <- pack }, nil, pipes)
+	require.NoError(t, err)
+	stop := run(sink)
+	defer stop()
+
+	pack := newPacket([]int{1})
+	pipes.In[0] <- pack
+	require.Equal(t, pack, <-consumed)
+}
+
+func TestParsePredicate(t *testing.T) {
+	tests := []struct {
+		spec   string
+		input  []int
+		expect bool
+	}{
+		{spec: "max>15", input: []int{1, 16}, expect: true},
+		{spec: "max > 15", input: []int{1, 15}, expect: false},
+		{spec: "max>=15", input: []int{1, 15}, expect: true},
+		{spec: "max>-1", input: []int{}, expect: false},
+		{spec: "min<2", input: []int{3, 1}, expect: true},
+		{spec: "min<=0", input: []int{3, 1}, expect: false},
+		{spec: "sum==4", input: []int{3, 1}, expect: true},
+		{spec: "len!=2", input: []int{3, 1}, expect: false},
+		{spec: "first==3", input: []int{3, 1}, expect: true},
+		{spec: "last==3", input: []int{3, 1}, expect: false},
+		{spec: "ID==0", input: []int{}, expect: true},
+	}
+	for _, tt := range tests {
+		predicate, err := ParsePredicate(tt.spec)
+		require.NoError(t, err, "Check predicate: %s", tt.spec)
+		require.Equal(t, tt.expect, predicate(newPacket(tt.input)), "Check predicate: %s %v", tt.spec, tt.input)
+	}
+
+	for _, spec := range []string{"max", "avg>1", "max>x", ">1"} {
+		_, err := ParsePredicate(spec)
+		require.Error(t, err, "Check invalid predicate: %s", spec)
+	}
+}
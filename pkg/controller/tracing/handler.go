@@ -0,0 +1,42 @@
+// Copyright 2024 Vladislav Klimenko. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracing
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// Handler returns HTTP handler, which responds with finished traces as OTLP JSON.
+// Optional slowest parameter limits traces to the specified number of the slowest ones
+func Handler(t *Tracer) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		traces := t.Traces()
+		if str := r.FormValue("slowest"); str != "" {
+			n, err := strconv.Atoi(str)
+			if err != nil || (n < 0) {
+				http.Error(w, "invalid slowest "+str, http.StatusBadRequest)
+				return
+			}
+			traces = Slowest(traces, n)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = WriteOTLP(w, traces)
+	})
+}
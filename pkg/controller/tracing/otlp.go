@@ -0,0 +1,235 @@
+// Copyright 2024 Vladislav Klimenko. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracing
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// OTLP JSON encoding of the traces, see opentelemetry-proto trace/v1. Each trace is exported as root span of the packet
+// with child spans of its lifecycle steps
+
+// ServiceName specifies name of the service traces are exported by
+const ServiceName = "pipeline"
+
+// RootSpan specifies name of the root span of the trace
+const RootSpan = "packet"
+
+// Attributes of the root span
+const (
+	AttributePacketID = "packet.id"
+	AttributeStatus   = "packet.status"
+)
+
+// OTLP status codes
+const (
+	statusCodeUnset = 0
+	statusCodeOK    = 1
+	statusCodeError = 2
+)
+
+// spanKindInternal specifies OTLP kind of the spans
+const spanKindInternal = 1
+
+type otlpTraces struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpKeyValue struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// unixNano formats time as OTLP JSON does, which is decimal string
+func unixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+// parseUnixNano parses time formatted by unixNano
+func parseUnixNano(str string) (time.Time, error) {
+	nano, err := strconv.ParseInt(str, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(0, nano), nil
+}
+
+func keyValues(attrs []Attribute) []otlpKeyValue {
+	var result []otlpKeyValue
+	for _, attr := range attrs {
+		result = append(result, otlpKeyValue{Key: attr.Key, Value: otlpValue{StringValue: attr.Value}})
+	}
+	return result
+}
+
+// WriteOTLP writes traces as OTLP JSON
+func WriteOTLP(w io.Writer, traces []Trace) error {
+	scope := otlpScopeSpans{
+		Scope: otlpScope{Name: ServiceName},
+		Spans: []otlpSpan{},
+	}
+	for _, trace := range traces {
+		root := otlpSpan{
+			TraceID:           trace.TraceID,
+			SpanID:            newID(8),
+			Name:              RootSpan,
+			Kind:              spanKindInternal,
+			StartTimeUnixNano: unixNano(trace.Start),
+			EndTimeUnixNano:   unixNano(trace.End),
+			Attributes: keyValues([]Attribute{
+				{Key: AttributePacketID, Value: strconv.FormatUint(trace.PacketID, 10)},
+				{Key: AttributeStatus, Value: trace.Status},
+			}),
+		}
+		switch trace.Status {
+		case StatusOK:
+			root.Status.Code = statusCodeOK
+		case StatusRejected:
+			root.Status = otlpStatus{Code: statusCodeError, Message: trace.Status}
+		default:
+			root.Status = otlpStatus{Code: statusCodeUnset, Message: trace.Status}
+		}
+		scope.Spans = append(scope.Spans, root)
+		for _, span := range trace.Spans {
+			scope.Spans = append(scope.Spans, otlpSpan{
+				TraceID:           trace.TraceID,
+				SpanID:            newID(8),
+				ParentSpanID:      root.SpanID,
+				Name:              span.Name,
+				Kind:              spanKindInternal,
+				StartTimeUnixNano: unixNano(span.Start),
+				EndTimeUnixNano:   unixNano(span.End),
+				Attributes:        keyValues(span.Attributes),
+			})
+		}
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(otlpTraces{
+		ResourceSpans: []otlpResourceSpans{
+			{
+				Resource: otlpResource{
+					Attributes: keyValues([]Attribute{{Key: "service.name", Value: ServiceName}}),
+				},
+				ScopeSpans: []otlpScopeSpans{scope},
+			},
+		},
+	})
+}
+
+// ReadOTLP reads traces written by WriteOTLP. Traces are returned in order of their start
+func ReadOTLP(r io.Reader) ([]Trace, error) {
+	var data otlpTraces
+	if err := json.NewDecoder(r).Decode(&data); err != nil {
+		return nil, fmt.Errorf("unable to decode traces: %v", err)
+	}
+
+	traces := make(map[string]*Trace)
+	get := func(id string) *Trace {
+		if _, ok := traces[id]; !ok {
+			traces[id] = &Trace{TraceID: id}
+		}
+		return traces[id]
+	}
+	for _, resourceSpans := range data.ResourceSpans {
+		for _, scopeSpans := range resourceSpans.ScopeSpans {
+			for _, span := range scopeSpans.Spans {
+				start, err := parseUnixNano(span.StartTimeUnixNano)
+				if err != nil {
+					return nil, fmt.Errorf("span %s: invalid start time: %v", span.SpanID, err)
+				}
+				end, err := parseUnixNano(span.EndTimeUnixNano)
+				if err != nil {
+					return nil, fmt.Errorf("span %s: invalid end time: %v", span.SpanID, err)
+				}
+				trace := get(span.TraceID)
+				if span.ParentSpanID == "" {
+					trace.Start, trace.End = start, end
+					for _, attr := range span.Attributes {
+						switch attr.Key {
+						case AttributePacketID:
+							trace.PacketID, _ = strconv.ParseUint(attr.Value.StringValue, 10, 64)
+						case AttributeStatus:
+							trace.Status = attr.Value.StringValue
+						}
+					}
+					continue
+				}
+				var attrs []Attribute
+				for _, attr := range span.Attributes {
+					attrs = append(attrs, Attribute{Key: attr.Key, Value: attr.Value.StringValue})
+				}
+				trace.Spans = append(trace.Spans, Span{Name: span.Name, Start: start, End: end, Attributes: attrs})
+			}
+		}
+	}
+
+	result := make([]Trace, 0, len(traces))
+	for _, trace := range traces {
+		sort.SliceStable(trace.Spans, func(i, j int) bool {
+			return trace.Spans[i].Start.Before(trace.Spans[j].Start)
+		})
+		result = append(result, *trace)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Start.Before(result[j].Start)
+	})
+	return result, nil
+}
@@ -0,0 +1,267 @@
+// Copyright 2024 Vladislav Klimenko. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracing
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Trace statuses
+const (
+	// StatusOK specifies packet, which has reached the end of the pipeline
+	StatusOK = "ok"
+	// StatusFiltered specifies packet filtered out by the pipeline
+	StatusFiltered = "filtered"
+	// StatusRejected specifies packet failed to be processed
+	StatusRejected = "rejected"
+	// StatusDropped specifies packet dropped by the pipeline
+	StatusDropped = "dropped"
+	// StatusEvicted specifies packet, which has not reached the end of the pipeline in time, ex.: routed elsewhere
+	StatusEvicted = "evicted"
+)
+
+// Names of the spans. Spans of the stages are named after the stages, ex.: "queue topn"
+const (
+	SpanGenerate   = "generate"
+	SpanQueue      = "queue"
+	SpanProcess    = "process"
+	SpanAccumulate = "accumulate"
+)
+
+// Defaults of the tracer
+const (
+	DefaultSize      = 1000
+	DefaultMaxActive = 10000
+	DefaultMaxAge    = 10 * time.Second
+)
+
+// Attribute specifies attribute of the span
+type Attribute struct {
+	Key   string
+	Value string
+}
+
+// Span specifies timed step of the packet lifecycle
+type Span struct {
+	Name       string
+	Start      time.Time
+	End        time.Time
+	Attributes []Attribute
+}
+
+// Duration returns duration of the span
+func (s *Span) Duration() time.Duration {
+	return s.End.Sub(s.Start)
+}
+
+// Trace specifies lifecycle of the packet
+type Trace struct {
+	// TraceID specifies trace id as 32 hex digits
+	TraceID  string
+	PacketID uint64
+	Start    time.Time
+	End      time.Time
+	Status   string
+	Spans    []Span
+	// mark specifies time the packet is handed over to the next step at
+	mark time.Time
+}
+
+// Duration returns duration of the trace
+func (t *Trace) Duration() time.Duration {
+	return t.End.Sub(t.Start)
+}
+
+// Options specifies tracer options
+type Options struct {
+	// Sample specifies ratio of the packets traced, from 0 to 1
+	Sample float64
+	// Size specifies number of the finished traces kept. Zero means default size
+	Size int
+	// MaxActive specifies max number of traces of packets in flight. Zero means default max
+	MaxActive int
+	// MaxAge specifies how long trace of the packet in flight is kept. Zero means default max age
+	MaxAge time.Duration
+}
+
+// Tracer records traces of the sampled packets by packet ids. Nil tracer records nothing.
+// Packets are sampled by their ids, so packets not sampled are skipped without locking.
+// Traces of packets in flight are active, finished traces are kept in the ring buffer
+type Tracer struct {
+	active map[uint64]*Trace
+	// order specifies ids of the active traces in the order they are started, finished ones are skipped lazily
+	order []uint64
+	// ring specifies finished traces, next specifies where the next one is put
+	ring    []*Trace
+	next    int
+	evicted int
+	mux     sync.Mutex
+	Options
+}
+
+// New creates new tracer
+func New(opts Options) *Tracer {
+	if opts.Size <= 0 {
+		opts.Size = DefaultSize
+	}
+	if opts.MaxActive <= 0 {
+		opts.MaxActive = DefaultMaxActive
+	}
+	if opts.MaxAge <= 0 {
+		opts.MaxAge = DefaultMaxAge
+	}
+	return &Tracer{
+		active:  make(map[uint64]*Trace),
+		ring:    make([]*Trace, opts.Size),
+		Options: opts,
+	}
+}
+
+// Start starts trace of the packet, in case it is sampled. Returns true in case packet is traced
+func (t *Tracer) Start(id uint64, at time.Time) bool {
+	if (t == nil) || !t.sampled(id) {
+		return false
+	}
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	t.evict(at)
+	t.active[id] = &Trace{
+		TraceID:  newID(16),
+		PacketID: id,
+		Start:    at,
+		mark:     at,
+	}
+	t.order = append(t.order, id)
+	return true
+}
+
+// sampled returns true in case packet of the id is sampled.
+// Ids are numbered sequentially, so sample ratio is kept by sampling ids, which move the ratio to the next integer
+func (t *Tracer) sampled(id uint64) bool {
+	return uint64(float64(id)*t.Options.Sample) != uint64(float64(id-1)*t.Options.Sample)
+}
+
+// evict finishes the oldest active traces, which are too old or too many. Must be called under lock
+func (t *Tracer) evict(now time.Time) {
+	for len(t.order) > 0 {
+		id := t.order[0]
+		trace, ok := t.active[id]
+		if ok && (len(t.active) < t.Options.MaxActive) && (now.Sub(trace.Start) < t.Options.MaxAge) {
+			return
+		}
+		t.order = t.order[1:]
+		if ok {
+			t.evicted++
+			t.finish(trace, StatusEvicted, trace.mark)
+		}
+	}
+}
+
+// Span adds span to the trace of the packet, if it is traced. End of the span is the time packet is handed over at
+func (t *Tracer) Span(id uint64, name string, start, end time.Time, attrs ...Attribute) {
+	if (t == nil) || !t.sampled(id) {
+		return
+	}
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	if trace, ok := t.active[id]; ok {
+		trace.Spans = append(trace.Spans, Span{Name: name, Start: start, End: end, Attributes: attrs})
+		trace.mark = end
+	}
+}
+
+// Wait adds span of waiting from the time packet is handed over till the time it is received at
+func (t *Tracer) Wait(id uint64, name string, received time.Time, attrs ...Attribute) {
+	if (t == nil) || !t.sampled(id) {
+		return
+	}
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	if trace, ok := t.active[id]; ok {
+		trace.Spans = append(trace.Spans, Span{Name: name, Start: trace.mark, End: received, Attributes: attrs})
+		trace.mark = received
+	}
+}
+
+// Finish finishes trace of the packet, if it is traced
+func (t *Tracer) Finish(id uint64, status string) {
+	if (t == nil) || !t.sampled(id) {
+		return
+	}
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	if trace, ok := t.active[id]; ok {
+		t.finish(trace, status, trace.mark)
+	}
+}
+
+// finish moves trace into the ring buffer. Must be called under lock
+func (t *Tracer) finish(trace *Trace, status string, end time.Time) {
+	delete(t.active, trace.PacketID)
+	trace.Status = status
+	trace.End = end
+	t.ring[t.next] = trace
+	t.next = (t.next + 1) % len(t.ring)
+}
+
+// Traces returns finished traces, the oldest first
+func (t *Tracer) Traces() []Trace {
+	if t == nil {
+		return nil
+	}
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	var traces []Trace
+	for i := range t.ring {
+		if trace := t.ring[(t.next+i)%len(t.ring)]; trace != nil {
+			traces = append(traces, *trace)
+		}
+	}
+	return traces
+}
+
+// Evicted returns number of traces evicted before their packets reached the end of the pipeline
+func (t *Tracer) Evicted() int {
+	if t == nil {
+		return 0
+	}
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	return t.evicted
+}
+
+// Slowest returns up to n slowest traces, the slowest first
+func Slowest(traces []Trace, n int) []Trace {
+	sorted := append([]Trace(nil), traces...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Duration() > sorted[j].Duration()
+	})
+	if len(sorted) > n {
+		sorted = sorted[:n]
+	}
+	return sorted
+}
+
+// newID returns random id of size bytes as hex digits
+func newID(size int) string {
+	b := make([]byte, size)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
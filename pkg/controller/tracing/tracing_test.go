@@ -0,0 +1,153 @@
+// Copyright 2024 Vladislav Klimenko. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracing
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTracer(t *testing.T) {
+	var nilTracer *Tracer
+	require.False(t, nilTracer.Start(1, time.Now()), "Check nil tracer traces nothing")
+	require.Nil(t, nilTracer.Traces())
+
+	tracer := New(Options{Sample: 0.5, Size: 2})
+	start := time.Unix(100, 0)
+	var traced []uint64
+	for id := uint64(1); id <= 4; id++ {
+		if tracer.Start(id, start) {
+			traced = append(traced, id)
+		}
+	}
+	require.Equal(t, []uint64{2, 4}, traced, "Check every second packet is sampled")
+
+	tracer.Span(2, SpanGenerate, start, start.Add(time.Millisecond))
+	tracer.Wait(2, SpanQueue+" topn", start.Add(3*time.Millisecond))
+	tracer.Span(2, SpanProcess+" topn", start.Add(3*time.Millisecond), start.Add(4*time.Millisecond), Attribute{Key: "worker_id", Value: "1"})
+	tracer.Span(3, SpanGenerate, start, start.Add(time.Millisecond))
+	tracer.Finish(2, StatusOK)
+	tracer.Finish(3, StatusOK)
+	tracer.Finish(4, StatusFiltered)
+
+	traces := tracer.Traces()
+	require.Len(t, traces, 2)
+	trace := traces[0]
+	require.Equal(t, uint64(2), trace.PacketID)
+	require.Equal(t, StatusOK, trace.Status)
+	require.Equal(t, 4*time.Millisecond, trace.Duration())
+	require.Len(t, trace.TraceID, 32)
+	require.Len(t, trace.Spans, 3)
+	require.Equal(t, 2*time.Millisecond, trace.Spans[1].Duration(), "Check wait lasts from hand over till receiving")
+	require.Equal(t, StatusFiltered, traces[1].Status)
+
+	// Ring buffer keeps the latest traces
+	tracer = New(Options{Sample: 1, Size: 2})
+	for id := uint64(1); id <= 3; id++ {
+		tracer.Start(id, start)
+		tracer.Finish(id, StatusOK)
+	}
+	traces = tracer.Traces()
+	require.Len(t, traces, 2)
+	require.Equal(t, uint64(2), traces[0].PacketID)
+	require.Equal(t, uint64(3), traces[1].PacketID)
+}
+
+func TestEvict(t *testing.T) {
+	start := time.Unix(100, 0)
+	tracer := New(Options{Sample: 1, MaxActive: 2, MaxAge: time.Second})
+	tracer.Start(1, start)
+	tracer.Start(2, start)
+	tracer.Start(3, start)
+	require.Equal(t, 1, tracer.Evicted(), "Check the oldest trace is evicted once there are too many")
+	tracer.Finish(2, StatusOK)
+	tracer.Start(4, start.Add(2*time.Second))
+	require.Equal(t, 2, tracer.Evicted(), "Check too old trace is evicted")
+
+	traces := tracer.Traces()
+	require.Len(t, traces, 3)
+	require.Equal(t, StatusEvicted, traces[0].Status)
+	require.Equal(t, uint64(1), traces[0].PacketID)
+	require.Equal(t, StatusOK, traces[1].Status)
+	require.Equal(t, StatusEvicted, traces[2].Status)
+	require.Equal(t, uint64(3), traces[2].PacketID)
+}
+
+func TestSlowest(t *testing.T) {
+	start := time.Unix(100, 0)
+	traces := []Trace{
+		{PacketID: 1, Start: start, End: start.Add(1 * time.Millisecond)},
+		{PacketID: 2, Start: start, End: start.Add(3 * time.Millisecond)},
+		{PacketID: 3, Start: start, End: start.Add(2 * time.Millisecond)},
+	}
+	slowest := Slowest(traces, 2)
+	require.Len(t, slowest, 2)
+	require.Equal(t, uint64(2), slowest[0].PacketID)
+	require.Equal(t, uint64(3), slowest[1].PacketID)
+	require.Equal(t, uint64(1), traces[0].PacketID, "Check traces are not sorted in place")
+}
+
+func TestOTLP(t *testing.T) {
+	start := time.Unix(100, 5)
+	tracer := New(Options{Sample: 1})
+	tracer.Start(7, start)
+	tracer.Span(7, SpanGenerate, start, start.Add(time.Millisecond))
+	tracer.Span(7, SpanProcess+" topn", start.Add(time.Millisecond), start.Add(3*time.Millisecond), Attribute{Key: "worker_id", Value: "2"})
+	tracer.Finish(7, StatusRejected)
+	traces := tracer.Traces()
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, WriteOTLP(buf, traces))
+	require.Contains(t, buf.String(), `"resourceSpans"`)
+	require.Contains(t, buf.String(), `"name": "packet"`)
+
+	read, err := ReadOTLP(buf)
+	require.NoError(t, err)
+	require.Len(t, read, 1)
+	require.Equal(t, traces[0].TraceID, read[0].TraceID)
+	require.Equal(t, uint64(7), read[0].PacketID)
+	require.Equal(t, StatusRejected, read[0].Status)
+	require.True(t, start.Equal(read[0].Start))
+	require.Equal(t, 3*time.Millisecond, read[0].Duration())
+	require.Len(t, read[0].Spans, 2)
+	require.Equal(t, SpanProcess+" topn", read[0].Spans[1].Name)
+	require.Equal(t, []Attribute{{Key: "worker_id", Value: "2"}}, read[0].Spans[1].Attributes)
+}
+
+func TestWaterfall(t *testing.T) {
+	start := time.Unix(100, 0)
+	trace := Trace{
+		TraceID:  "0123",
+		PacketID: 3,
+		Start:    start,
+		End:      start.Add(4 * time.Millisecond),
+		Status:   StatusOK,
+		Spans: []Span{
+			{Name: SpanGenerate, Start: start, End: start.Add(time.Millisecond)},
+			{Name: SpanAccumulate, Start: start.Add(2 * time.Millisecond), End: start.Add(4 * time.Millisecond)},
+		},
+	}
+	buf := &bytes.Buffer{}
+	require.NoError(t, WriteWaterfall(buf, trace, 4))
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 3)
+	require.Equal(t, "packet #3 4ms ok trace 0123", lines[0])
+	require.True(t, strings.HasSuffix(lines[1], "|#   |"), lines[1])
+	require.True(t, strings.HasSuffix(lines[2], "|  ##|"), lines[2])
+}
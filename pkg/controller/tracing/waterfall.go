@@ -0,0 +1,80 @@
+// Copyright 2024 Vladislav Klimenko. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracing
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// DefaultWidth specifies width of the waterfall bars, unless specified
+const DefaultWidth = 40
+
+// WriteWaterfall writes waterfall view of the trace: a line per span with its duration and
+// a bar showing when the span took place within the trace
+func WriteWaterfall(w io.Writer, trace Trace, width int) error {
+	if width <= 0 {
+		width = DefaultWidth
+	}
+	if _, err := fmt.Fprintf(w, "packet #%d %s %s trace %s\n", trace.PacketID, trace.Duration(), trace.Status, trace.TraceID); err != nil {
+		return err
+	}
+
+	nameWidth := 0
+	for _, span := range trace.Spans {
+		if len(span.Name) > nameWidth {
+			nameWidth = len(span.Name)
+		}
+	}
+	total := trace.Duration()
+	for _, span := range trace.Spans {
+		offset, length := 0, width
+		if total > 0 {
+			offset = int(int64(span.Start.Sub(trace.Start)) * int64(width) / int64(total))
+			length = int(int64(span.Duration()) * int64(width) / int64(total))
+		}
+		offset = clamp(offset, 0, width-1)
+		length = clamp(length, 1, width-offset)
+		bar := strings.Repeat(" ", offset) + strings.Repeat("#", length) + strings.Repeat(" ", width-offset-length)
+		if _, err := fmt.Fprintf(w, "  %-*s %12s |%s|%s\n", nameWidth, span.Name, span.Duration().Round(time.Microsecond), bar, attributes(span.Attributes)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// attributes formats span attributes
+func attributes(attrs []Attribute) string {
+	var b strings.Builder
+	for _, attr := range attrs {
+		b.WriteString(" ")
+		b.WriteString(attr.Key)
+		b.WriteString("=")
+		b.WriteString(attr.Value)
+	}
+	return b.String()
+}
+
+func clamp(value, min, max int) int {
+	if value < min {
+		return min
+	}
+	if value > max {
+		return max
+	}
+	return value
+}
@@ -0,0 +1,281 @@
+// Copyright 2024 Vladislav Klimenko. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transform
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/sunsingerus/pipeline/pkg/controller/metrics"
+)
+
+// Transform transforms values of a packet. It may modify values in place.
+// Nil values with nil error mean packet is filtered out.
+type Transform interface {
+	Apply(values []int) ([]int, error)
+}
+
+// Func adapts function to the Transform
+type Func func(values []int) ([]int, error)
+
+// Apply calls the function
+func (f Func) Apply(values []int) ([]int, error) {
+	return f(values)
+}
+
+// Factory creates transform for each of the stage workers
+type Factory func() Transform
+
+// Available transforms
+const (
+	// KindFilter keeps values within [min, max] and filters out packets with no values left
+	KindFilter = "filter"
+	// KindDedupe removes repeated values keeping the first occurrence
+	KindDedupe = "dedupe"
+	// KindTopN keeps N greatest values sorted ascending
+	KindTopN = "topn"
+	// KindScale multiplies values by the factor and adds the offset
+	KindScale = "scale"
+)
+
+// Params specifies transform parameters
+type Params map[string]string
+
+// Int returns integer parameter or the default value in case parameter is not specified
+func (p Params) Int(name string, _default int) (int, error) {
+	str, ok := p[name]
+	if !ok {
+		return _default, nil
+	}
+	value, err := strconv.Atoi(str)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q", name, str)
+	}
+	return value, nil
+}
+
+// String returns string parameter or the default value in case parameter is not specified
+func (p Params) String(name string, _default string) string {
+	if str, ok := p[name]; ok {
+		return str
+	}
+	return _default
+}
+
+// check reports unknown parameters
+func (p Params) check(kind string, known ...string) error {
+	var unknown []string
+	for name := range p {
+		found := false
+		for _, k := range known {
+			found = found || (name == k)
+		}
+		if !found {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("unknown %s parameters: %s", kind, strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+// New creates factory of the transforms of the specified kind. Transforms report their counters to the stage metrics
+func New(kind string, params Params, stage *metrics.Stage) (Factory, error) {
+	switch kind {
+	case KindFilter:
+		return newFilter(params)
+	case KindDedupe:
+		if err := params.check(kind); err != nil {
+			return nil, err
+		}
+		return func() Transform { return Func(Dedupe) }, nil
+	case KindTopN:
+		return newTopN(params, stage)
+	case KindScale:
+		return newScale(params)
+	default:
+		return nil, fmt.Errorf("unknown transform %q", kind)
+	}
+}
+
+func newFilter(params Params) (Factory, error) {
+	if err := params.check(KindFilter, "min", "max"); err != nil {
+		return nil, err
+	}
+	min, err := params.Int("min", math.MinInt)
+	if err != nil {
+		return nil, err
+	}
+	max, err := params.Int("max", math.MaxInt)
+	if err != nil {
+		return nil, err
+	}
+	if min > max {
+		return nil, fmt.Errorf("filter min %d is greater than max %d", min, max)
+	}
+	return func() Transform { return Filter(min, max) }, nil
+}
+
+// Filter keeps values within [min, max]. Packets with no values left are filtered out
+func Filter(min, max int) Transform {
+	return Func(func(values []int) ([]int, error) {
+		result := values[:0]
+		for _, value := range values {
+			if (value >= min) && (value <= max) {
+				result = append(result, value)
+			}
+		}
+		if len(result) == 0 {
+			return nil, nil
+		}
+		return result, nil
+	})
+}
+
+// Dedupe removes repeated values keeping the first occurrence
+func Dedupe(values []int) ([]int, error) {
+	seen := make(map[int]struct{}, len(values))
+	result := values[:0]
+	for _, value := range values {
+		if _, ok := seen[value]; ok {
+			continue
+		}
+		seen[value] = struct{}{}
+		result = append(result, value)
+	}
+	return result, nil
+}
+
+// ShortPacketPolicy specifies how packets shorter than result size are handled
+type ShortPacketPolicy string
+
+// Available short packet policies
+const (
+	// ShortPacketPad pads result with the min value of the packet up to result size, so result stays sorted.
+	// Empty packet has no min value and is padded with zeros.
+	ShortPacketPad ShortPacketPolicy = "pad"
+	// ShortPacketPass passes whole sorted packet as a result
+	ShortPacketPass ShortPacketPolicy = "pass"
+	// ShortPacketReject rejects packet to the dead-letter sink
+	ShortPacketReject ShortPacketPolicy = "reject"
+)
+
+func newTopN(params Params, stage *metrics.Stage) (Factory, error) {
+	if err := params.check(KindTopN, "n", "short"); err != nil {
+		return nil, err
+	}
+	n, err := params.Int("n", 0)
+	if err != nil {
+		return nil, err
+	}
+	if n < 0 {
+		return nil, fmt.Errorf("invalid result size %d", n)
+	}
+	policy := ShortPacketPolicy(params.String("short", string(ShortPacketPad)))
+	switch policy {
+	case ShortPacketPad, ShortPacketPass, ShortPacketReject:
+	default:
+		return nil, fmt.Errorf("unknown short packet policy %q", policy)
+	}
+	topN := &TopN{
+		N:      n,
+		Policy: policy,
+	}
+	if stage != nil {
+		topN.padded = stage.Counter("padded")
+		topN.passed = stage.Counter("passed")
+	}
+	return func() Transform { return topN }, nil
+}
+
+// TopN keeps N greatest values sorted ascending
+type TopN struct {
+	// N specifies size of result packet
+	N int
+	// Policy specifies how packets shorter than N are handled. Empty policy means pad.
+	Policy ShortPacketPolicy
+
+	// padded and passed count short packets, if specified
+	padded *atomic.Int64
+	passed *atomic.Int64
+}
+
+// Apply sorts values and keeps N greatest of them
+func (t *TopN) Apply(values []int) ([]int, error) {
+	// Sort incoming packet ASC and get N greatest numbers, which will be located on the right side of the slice
+	sort.Ints(values)
+	if len(values) >= t.N {
+		return values[len(values)-t.N:], nil
+	}
+
+	// Packet is too short to have N greatest numbers
+	switch t.Policy {
+	case ShortPacketPass:
+		count(t.passed)
+		return values, nil
+	case ShortPacketReject:
+		return nil, fmt.Errorf("packet size %d is less than result size %d", len(values), t.N)
+	case "", ShortPacketPad:
+		count(t.padded)
+		result := make([]int, t.N)
+		pad := t.N - len(values)
+		if len(values) > 0 {
+			for i := 0; i < pad; i++ {
+				result[i] = values[0]
+			}
+		}
+		copy(result[pad:], values)
+		return result, nil
+	default:
+		return nil, fmt.Errorf("unknown short packet policy %q", t.Policy)
+	}
+}
+
+func newScale(params Params) (Factory, error) {
+	if err := params.check(KindScale, "factor", "offset"); err != nil {
+		return nil, err
+	}
+	factor, err := params.Int("factor", 1)
+	if err != nil {
+		return nil, err
+	}
+	offset, err := params.Int("offset", 0)
+	if err != nil {
+		return nil, err
+	}
+	return func() Transform { return Scale(factor, offset) }, nil
+}
+
+// Scale multiplies values by the factor and adds the offset
+func Scale(factor, offset int) Transform {
+	return Func(func(values []int) ([]int, error) {
+		for i := range values {
+			values[i] = values[i]*factor + offset
+		}
+		return values, nil
+	})
+}
+
+func count(c *atomic.Int64) {
+	if c != nil {
+		c.Add(1)
+	}
+}
@@ -0,0 +1,167 @@
+// Copyright 2024 Vladislav Klimenko. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transform
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/sunsingerus/pipeline/pkg/controller/metrics"
+)
+
+func TestTopNShortPacket(t *testing.T) {
+	tests := []struct {
+		policy ShortPacketPolicy
+		input  []int
+		expect []int
+		err    bool
+	}{
+		{
+			policy: ShortPacketPad,
+			input:  []int{1, 9, 6, 4, 4, 5, 7, 8, 0, 1},
+			expect: []int{7, 8, 9},
+		},
+		{
+			policy: ShortPacketPad,
+			input:  []int{5, 2},
+			expect: []int{2, 2, 5},
+		},
+		{
+			policy: ShortPacketPad,
+			input:  []int{-5, -2},
+			expect: []int{-5, -5, -2},
+		},
+		{
+			policy: "",
+			input:  []int{},
+			expect: []int{0, 0, 0},
+		},
+		{
+			policy: ShortPacketPass,
+			input:  []int{5, 2},
+			expect: []int{2, 5},
+		},
+		{
+			policy: ShortPacketReject,
+			input:  []int{5, 2},
+			err:    true,
+		},
+		{
+			policy: ShortPacketReject,
+			input:  []int{5, 2, 7},
+			expect: []int{2, 5, 7},
+		},
+		{
+			policy: "unknown",
+			input:  []int{5, 2},
+			err:    true,
+		},
+	}
+	for _, tt := range tests {
+		result, err := (&TopN{N: 3, Policy: tt.policy}).Apply(tt.input)
+		if tt.err {
+			require.Error(t, err, "Check rejected: %v", tt.input)
+			continue
+		}
+		require.NoError(t, err, "Check result: %v", tt.input)
+		require.Equal(t, tt.expect, result, "Check result: %v", tt.input)
+	}
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		kind   string
+		params Params
+		input  []int
+		expect []int
+		err    bool
+	}{
+		{
+			kind:   KindFilter,
+			params: Params{"min": "5"},
+			input:  []int{1, 9, 6, 4},
+			expect: []int{9, 6},
+		},
+		{
+			kind:   KindFilter,
+			params: Params{"min": "5", "max": "8"},
+			input:  []int{1, 9, 4},
+			expect: nil,
+		},
+		{
+			kind:   KindDedupe,
+			input:  []int{3, 1, 3, 2, 1},
+			expect: []int{3, 1, 2},
+		},
+		{
+			kind:   KindTopN,
+			params: Params{"n": "2"},
+			input:  []int{3, 1, 2},
+			expect: []int{2, 3},
+		},
+		{
+			kind:   KindScale,
+			params: Params{"factor": "3", "offset": "-1"},
+			input:  []int{1, 2},
+			expect: []int{2, 5},
+		},
+		{
+			kind:   KindTopN,
+			params: Params{"n": "x"},
+			err:    true,
+		},
+		{
+			kind:   KindTopN,
+			params: Params{"n": "2", "short": "drop"},
+			err:    true,
+		},
+		{
+			kind:   KindFilter,
+			params: Params{"min": "5", "max": "1"},
+			err:    true,
+		},
+		{
+			kind:   KindScale,
+			params: Params{"factr": "2"},
+			err:    true,
+		},
+		{
+			kind: "sort",
+			err:  true,
+		},
+	}
+	for _, tt := range tests {
+		factory, err := New(tt.kind, tt.params, nil)
+		if tt.err {
+			require.Error(t, err, "Check error: %s %v", tt.kind, tt.params)
+			continue
+		}
+		require.NoError(t, err, "Check factory: %s %v", tt.kind, tt.params)
+		result, err := factory().Apply(tt.input)
+		require.NoError(t, err)
+		require.Equal(t, tt.expect, result, "Check result: %s %v", tt.kind, tt.params)
+	}
+}
+
+func TestTopNCounters(t *testing.T) {
+	stage := metrics.NewStage(KindTopN)
+	factory, err := New(KindTopN, Params{"n": "3", "short": "pass"}, stage)
+	require.NoError(t, err)
+	_, err = factory().Apply([]int{1})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), stage.Counter("passed").Load())
+	require.Equal(t, int64(0), stage.Counter("padded").Load())
+}
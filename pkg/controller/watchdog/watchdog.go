@@ -0,0 +1,236 @@
+// Copyright 2024 Vladislav Klimenko. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package watchdog
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"runtime/pprof"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sunsingerus/pipeline/pkg/controller/health"
+	"github.com/sunsingerus/pipeline/pkg/controller/metrics"
+	"github.com/sunsingerus/pipeline/pkg/logger"
+)
+
+// Component specifies component watchdog logs as
+const Component = "watchdog"
+
+// DefaultInterval specifies interval between checks, unless specified
+const DefaultInterval = time.Second
+
+// Edge specifies channel packets are delivered over from one stage to another
+type Edge struct {
+	From string
+	To   string
+}
+
+// String returns string representation of the edge
+func (e Edge) String() string {
+	return e.From + " -> " + e.To
+}
+
+// Options specifies watchdog options
+type Options struct {
+	// Interval specifies interval between checks. Zero means default interval
+	Interval time.Duration
+	// DumpFile specifies file dumps of the detected stalls are appended to. Empty means dumps are logged only
+	DumpFile string
+}
+
+// Watchdog checks stages and edges of the pipeline for stalls and dumps state of the pipeline once a stall is detected.
+// Stage is stalled once it has packets in flight and makes no progress for the stall timeout of the monitor.
+// Edge is stalled once packets wait to be delivered over it, either sent by the sending stage or queued for the receiving one,
+// and the receiving stage has received no packets for the stall timeout.
+type Watchdog struct {
+	monitor *health.Monitor
+	edges   []Edge
+	stats   func() metrics.PipelineStats
+	// stalled specifies whether the stall is being reported, so it is dumped once
+	stalled bool
+	log     *logger.Entry
+	Options
+}
+
+// New creates new watchdog of the stages reporting heartbeats to the monitor. Stats provide state of the stages
+func New(monitor *health.Monitor, edges []Edge, stats func() metrics.PipelineStats, opts Options) *Watchdog {
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultInterval
+	}
+	return &Watchdog{
+		monitor: monitor,
+		edges:   edges,
+		stats:   stats,
+		log:     logger.Component(Component),
+		Options: opts,
+	}
+}
+
+// Run checks the pipeline until context is done
+func (w *Watchdog) Run(ctx context.Context, wg *sync.WaitGroup) {
+	defer wg.Done()
+	if w == nil {
+		return
+	}
+	w.log.Info("start")
+	defer w.log.Info("end")
+
+	ticker := time.NewTicker(w.Options.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			w.log.Info("done")
+			return
+		case now := <-ticker.C:
+			w.check(now)
+		}
+	}
+}
+
+// check checks the pipeline and reports stall once it is detected and once it is cleared
+func (w *Watchdog) check(now time.Time) {
+	reasons, suspect := w.detect(now)
+	switch {
+	case (len(reasons) > 0) && !w.stalled:
+		w.stalled = true
+		dump := w.dump(now, reasons, suspect)
+		w.log.Errorf("stall detected: %s\n%s", strings.Join(reasons, ", "), dump)
+		if err := w.write(dump); err != nil {
+			w.log.Errorf("unable to write dump - %v", err)
+		}
+	case (len(reasons) == 0) && w.stalled:
+		w.stalled = false
+		w.log.Info("stall cleared")
+	}
+}
+
+// detect returns reasons of the stall and the furthest stalled stage, which is the most likely cause of the stall
+func (w *Watchdog) detect(now time.Time) ([]string, string) {
+	var reasons []string
+	suspect := ""
+	for _, state := range w.monitor.Stalled(now) {
+		reasons = append(reasons, state.Stall(now))
+		suspect = state.Name
+	}
+	states := w.states()
+	queues := w.queues()
+	for _, edge := range w.edges {
+		from, to := states[edge.From], states[edge.To]
+		// Sending stage may be busy with packets it filters, so packets it is busy with are not waiting
+		if (from.Sending == 0) && (queues[edge.To] == 0) {
+			// Nothing waits to be delivered over the edge
+			continue
+		}
+		last := to.LastReceived
+		if last.IsZero() {
+			last = to.Last
+		}
+		if stalled := now.Sub(last); stalled > w.monitor.Options.StallTimeout {
+			reasons = append(reasons, fmt.Sprintf("edge %s delivered no packets for %s", edge, stalled.Round(time.Second)))
+		}
+	}
+	return reasons, suspect
+}
+
+// states returns states of the stages by their names
+func (w *Watchdog) states() map[string]health.State {
+	states := make(map[string]health.State)
+	for _, state := range w.monitor.States() {
+		states[state.Name] = state
+	}
+	return states
+}
+
+// queues returns number of packets waiting in the input channels of the stages
+func (w *Watchdog) queues() map[string]int {
+	queues := make(map[string]int)
+	if w.stats == nil {
+		return queues
+	}
+	for _, stage := range w.stats().Stages {
+		queues[stage.Name] = stage.Queue
+	}
+	return queues
+}
+
+// dump returns state of the stages and edges followed by stacks of all goroutines
+func (w *Watchdog) dump(now time.Time, reasons []string, suspect string) string {
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "=== stall detected at %s\n", now.Format(time.RFC3339Nano))
+	for _, reason := range reasons {
+		fmt.Fprintf(buf, "%s\n", reason)
+	}
+	if suspect != "" {
+		fmt.Fprintf(buf, "suspect: %s, the furthest stalled stage\n", suspect)
+	}
+
+	fmt.Fprintf(buf, "--- heartbeats\n")
+	for _, state := range w.monitor.States() {
+		fmt.Fprintf(buf, "%s\n", &state)
+	}
+	if w.stats != nil {
+		fmt.Fprintf(buf, "--- stages\n")
+		for _, stage := range w.stats().Stages {
+			fmt.Fprintf(buf, "%s queue=%d/%d busy=%d/%d", stage.Name, stage.Queue, stage.Capacity, stage.Busy, stage.Workers)
+			for _, name := range sortedNames(stage.Counters) {
+				fmt.Fprintf(buf, " %s=%d", name, stage.Counters[name])
+			}
+			fmt.Fprintf(buf, "\n")
+		}
+	}
+
+	fmt.Fprintf(buf, "--- edges\n")
+	states := w.states()
+	for _, edge := range w.edges {
+		fmt.Fprintf(buf, "%s sending=%d received=%d last=%s\n", edge, states[edge.From].Sending, states[edge.To].Received, states[edge.To].LastReceived.Format(time.RFC3339Nano))
+	}
+
+	fmt.Fprintf(buf, "--- goroutines\n")
+	_ = pprof.Lookup("goroutine").WriteTo(buf, 2)
+	return buf.String()
+}
+
+// write appends dump to the dump file, in case it is specified
+func (w *Watchdog) write(dump string) error {
+	if w.Options.DumpFile == "" {
+		return nil
+	}
+	f, err := os.OpenFile(w.Options.DumpFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(dump); err != nil {
+		_ = f.Close()
+		return err
+	}
+	w.log.Infof("dump written into %s", w.Options.DumpFile)
+	return f.Close()
+}
+
+// sortedNames returns names of the counters in alphabetical order
+func sortedNames(counters map[string]int64) []string {
+	names := make([]string, 0, len(counters))
+	for name := range counters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,98 @@
+// Copyright 2024 Vladislav Klimenko. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package watchdog
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/sunsingerus/pipeline/pkg/controller/health"
+	"github.com/sunsingerus/pipeline/pkg/controller/metrics"
+)
+
+func TestWatchdog(t *testing.T) {
+	monitor := health.New(health.Options{StallTimeout: time.Second})
+	monitor.Expect(health.DefaultSource, "topn", "accum")
+	for _, stage := range []string{health.DefaultSource, "topn", "accum"} {
+		monitor.Start(stage)
+	}
+	edges := []Edge{{From: health.DefaultSource, To: "topn"}, {From: "topn", To: "accum"}}
+	stats := func() metrics.PipelineStats {
+		return metrics.PipelineStats{
+			Stages: []metrics.StageStats{{Name: "topn", Counters: map[string]int64{"received": 1}, Workers: 1, Busy: 1}},
+		}
+	}
+	path := filepath.Join(t.TempDir(), "stall.dump")
+	w := New(monitor, edges, stats, Options{DumpFile: path})
+
+	reasons, _ := w.detect(time.Now().Add(time.Hour))
+	require.Empty(t, reasons, "Check idle pipeline is not stalled")
+
+	// Stage busy with packets it does not deliver, ex.: filtered ones, does not stall the edge
+	monitor.Begin("topn")
+	reasons, _ = w.detect(time.Now().Add(time.Minute))
+	require.Equal(t, []string{"stage topn made no progress for 1m0s with 1 packets in flight"}, reasons)
+	monitor.End("topn")
+
+	// Accumulator is stuck, so topn is blocked delivering to it and generator is blocked delivering to topn
+	monitor.Begin(health.DefaultSource)
+	monitor.BeginSend(health.DefaultSource)
+	monitor.Begin("topn")
+	monitor.BeginSend("topn")
+	monitor.Begin("accum")
+	monitor.Begin(health.DefaultSource)
+	monitor.BeginSend(health.DefaultSource)
+	later := time.Now().Add(time.Minute)
+	reasons, suspect := w.detect(later)
+	require.Equal(t, "accum", suspect)
+	require.Equal(t, []string{
+		"stage generator made no progress for 1m0s with 2 packets in flight",
+		"stage topn made no progress for 1m0s with 1 packets in flight",
+		"stage accum made no progress for 1m0s with 1 packets in flight",
+		"edge generator -> topn delivered no packets for 1m0s",
+		"edge topn -> accum delivered no packets for 1m0s",
+	}, reasons)
+
+	w.check(later)
+	w.check(later.Add(time.Second))
+	dump, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, 1, strings.Count(string(dump), "=== stall detected"), "Check stall is dumped once")
+	require.Contains(t, string(dump), "suspect: accum, the furthest stalled stage\n")
+	require.Contains(t, string(dump), "\ntopn queue=0/0 busy=1/1 received=1\n")
+	require.Contains(t, string(dump), "\ntopn -> accum sending=1 received=1 ")
+	require.Contains(t, string(dump), "--- goroutines\ngoroutine ")
+
+	// Stall is dumped again once it is cleared and detected again
+	monitor.End("accum")
+	monitor.EndSend("topn")
+	monitor.End("topn")
+	for i := 0; i < 2; i++ {
+		monitor.EndSend(health.DefaultSource)
+		monitor.End(health.DefaultSource)
+	}
+	w.check(time.Now())
+	require.False(t, w.stalled)
+	monitor.Begin("accum")
+	w.check(time.Now().Add(time.Minute))
+	dump, err = os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, 2, strings.Count(string(dump), "=== stall detected"))
+}
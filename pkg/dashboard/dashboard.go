@@ -0,0 +1,248 @@
+// Copyright 2024 Vladislav Klimenko. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dashboard
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sunsingerus/pipeline/pkg/controller/metrics"
+)
+
+// ANSI escapes the dashboard is rendered with
+const (
+	clearScreen = "\033[H\033[2J"
+	reset       = "\033[0m"
+	bold        = "\033[1m"
+	red         = "\033[31m"
+	green       = "\033[32m"
+	yellow      = "\033[33m"
+)
+
+// sparks specifies levels of the sparkline, the lowest first
+var sparks = []rune("▁▂▃▄▅▆▇█")
+
+// Defaults of the dashboard
+const (
+	DefaultInterval = time.Second
+	DefaultBarWidth = 10
+	DefaultHistory  = 40
+)
+
+// Source returns state of the pipeline to render
+type Source func() (metrics.PipelineStats, error)
+
+// Options specifies dashboard options
+type Options struct {
+	// Interval specifies interval between dashboard refreshes. Zero means default interval
+	Interval time.Duration
+	// Colors specifies whether dashboard is colored
+	Colors bool
+	// BarWidth specifies width of the fill bars. Zero means default width
+	BarWidth int
+	// History specifies number of accumulator values the sparkline shows. Zero means default history
+	History int
+}
+
+// Dashboard renders refreshing terminal view of the pipeline
+type Dashboard struct {
+	source Source
+	out    io.Writer
+	// prev specifies previous state of the pipeline throughput is calculated against
+	prev *metrics.PipelineStats
+	// history specifies the latest values of the accumulator, the oldest first
+	history []int
+	Options
+}
+
+// New creates new dashboard, which renders state of the pipeline provided by source into out
+func New(source Source, out io.Writer, opts Options) *Dashboard {
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultInterval
+	}
+	if opts.BarWidth <= 0 {
+		opts.BarWidth = DefaultBarWidth
+	}
+	if opts.History <= 0 {
+		opts.History = DefaultHistory
+	}
+	return &Dashboard{
+		source:  source,
+		out:     out,
+		Options: opts,
+	}
+}
+
+// Run refreshes dashboard until context is done
+func (d *Dashboard) Run(ctx context.Context, wg *sync.WaitGroup) {
+	defer wg.Done()
+	if d == nil {
+		return
+	}
+	ticker := time.NewTicker(d.Options.Interval)
+	defer ticker.Stop()
+	for {
+		d.refresh()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// refresh renders the current state of the pipeline with a single write, so the screen does not flicker
+func (d *Dashboard) refresh() {
+	buf := &bytes.Buffer{}
+	buf.WriteString(clearScreen)
+	stats, err := d.source()
+	if err != nil {
+		fmt.Fprintf(buf, "%s\n", d.color(red, fmt.Sprintf("unable to get pipeline stats: %v", err)))
+	} else {
+		d.Render(buf, stats)
+	}
+	_, _ = d.out.Write(buf.Bytes())
+}
+
+// Render renders state of the pipeline. Throughput is calculated against the previously rendered state
+func (d *Dashboard) Render(w io.Writer, stats metrics.PipelineStats) {
+	d.history = append(d.history, stats.Accum)
+	if len(d.history) > d.Options.History {
+		d.history = d.history[len(d.history)-d.Options.History:]
+	}
+
+	fmt.Fprintf(w, "%s  %s\n", d.color(bold, "pipeline"), stats.Time.Format("15:04:05"))
+	fmt.Fprintf(w, "accum %d  %s\n\n", stats.Accum, d.color(green, sparkline(d.history)))
+
+	fmt.Fprintf(w, "%s\n", d.color(bold, fmt.Sprintf("%-12s %9s %10s %10s %9s %8s %8s  %-*s  %-*s",
+		"STAGE", "RATE/S", "RECEIVED", "DELIVERED", "FILTERED", "DROPPED", "ERRORS",
+		d.Options.BarWidth+10, "QUEUE", d.Options.BarWidth+8, "WORKERS")))
+	for _, stage := range stats.Stages {
+		rate := "-"
+		if elapsed, prev, ok := d.previous(stats.Time, stage.Name); ok {
+			rate = fmt.Sprintf("%.1f", float64(stage.Counters["received"]-prev.Counters["received"])/elapsed.Seconds())
+		}
+		fmt.Fprintf(w, "%-12s %9s %10d %10d %9d %s %s  %s  %s\n",
+			stage.Name,
+			rate,
+			stage.Counters["received"],
+			stage.Counters["delivered"],
+			stage.Counters["filtered"],
+			d.alarm(8, stage.Counters["dropped"]),
+			d.alarm(8, stage.Counters["rejected"]),
+			d.fill(stage.Queue, stage.Capacity, 10),
+			d.fill(stage.Busy, stage.Workers, 8),
+		)
+	}
+	d.prev = &stats
+}
+
+// previous returns the previously rendered state of the stage and time elapsed since then
+func (d *Dashboard) previous(now time.Time, name string) (time.Duration, metrics.StageStats, bool) {
+	if d.prev == nil {
+		return 0, metrics.StageStats{}, false
+	}
+	elapsed := now.Sub(d.prev.Time)
+	if elapsed <= 0 {
+		return 0, metrics.StageStats{}, false
+	}
+	for _, stage := range d.prev.Stages {
+		if stage.Name == name {
+			return elapsed, stage, true
+		}
+	}
+	return 0, metrics.StageStats{}, false
+}
+
+// fill renders bar of value out of max, padded to the column of the bar width plus extra
+func (d *Dashboard) fill(value, max, extra int) string {
+	width := d.Options.BarWidth + extra
+	if max <= 0 {
+		return fmt.Sprintf("%-*s", width, "-")
+	}
+	filled := clamp(value*d.Options.BarWidth/max, 0, d.Options.BarWidth)
+	if (value > 0) && (filled == 0) {
+		filled = 1
+	}
+	bar := strings.Repeat("#", filled) + strings.Repeat("-", d.Options.BarWidth-filled)
+	counts := fmt.Sprintf("%d/%d", value, max)
+	// Colors do not take place on the screen, so the column is padded by the visible text
+	padding := strings.Repeat(" ", clamp(width-len(bar)-len(counts)-3, 0, width))
+	color := green
+	switch {
+	case value*10 >= max*8:
+		color = red
+	case value*2 >= max:
+		color = yellow
+	}
+	return "[" + d.color(color, bar) + "] " + counts + padding
+}
+
+// alarm renders counter of troubles, highlighted once there are any
+func (d *Dashboard) alarm(width int, value int64) string {
+	str := fmt.Sprintf("%*d", width, value)
+	if value > 0 {
+		return d.color(red, str)
+	}
+	return str
+}
+
+// color colors the text, in case dashboard is colored
+func (d *Dashboard) color(color, text string) string {
+	if !d.Options.Colors {
+		return text
+	}
+	return color + text + reset
+}
+
+// sparkline renders values as a line of bars scaled between the min and the max value
+func sparkline(values []int) string {
+	if len(values) == 0 {
+		return ""
+	}
+	min, max := values[0], values[0]
+	for _, value := range values {
+		if value < min {
+			min = value
+		}
+		if value > max {
+			max = value
+		}
+	}
+	var b strings.Builder
+	for _, value := range values {
+		level := 0
+		if max > min {
+			level = (value - min) * (len(sparks) - 1) / (max - min)
+		}
+		b.WriteRune(sparks[level])
+	}
+	return b.String()
+}
+
+func clamp(value, min, max int) int {
+	if value < min {
+		return min
+	}
+	if value > max {
+		return max
+	}
+	return value
+}
@@ -0,0 +1,130 @@
+// Copyright 2024 Vladislav Klimenko. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dashboard
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/sunsingerus/pipeline/pkg/controller/metrics"
+)
+
+func newStats(at time.Time, received int64, accum int) metrics.PipelineStats {
+	return metrics.PipelineStats{
+		Time: at,
+		Stages: []metrics.StageStats{
+			{
+				Name:     "topn",
+				Counters: map[string]int64{"received": received, "delivered": received - 1, "dropped": 2},
+				Queue:    5,
+				Capacity: 10,
+				Workers:  4,
+				Busy:     4,
+			},
+			{
+				Name:     "accum",
+				Counters: map[string]int64{"received": received - 1},
+			},
+		},
+		Accum: accum,
+	}
+}
+
+func TestRender(t *testing.T) {
+	d := New(nil, nil, Options{BarWidth: 4})
+	start := time.Unix(100, 0)
+
+	buf := &bytes.Buffer{}
+	d.Render(buf, newStats(start, 10, 1))
+	lines := strings.Split(buf.String(), "\n")
+	require.Equal(t, "pipeline  "+start.Format("15:04:05"), lines[0])
+	require.Equal(t, "accum 1  ▁", lines[1])
+	require.True(t, strings.HasPrefix(lines[3], "STAGE"))
+	require.Equal(t, []string{"topn", "-", "10", "9", "0", "2", "0", "[##--]", "5/10", "[####]", "4/4"}, strings.Fields(lines[4]))
+	require.Equal(t, []string{"accum", "-", "9", "0", "0", "0", "0", "-", "-"}, strings.Fields(lines[5]))
+	require.Equal(t, len(lines[3]), len(lines[4]), "Check columns are aligned")
+
+	buf.Reset()
+	d.Render(buf, newStats(start.Add(2*time.Second), 30, 5))
+	lines = strings.Split(buf.String(), "\n")
+	require.Equal(t, "accum 5  ▁█", lines[1])
+	require.Equal(t, "10.0", strings.Fields(lines[4])[1], "Check throughput is calculated against the previous state")
+}
+
+func TestColors(t *testing.T) {
+	d := New(nil, nil, Options{Colors: true})
+	buf := &bytes.Buffer{}
+	d.Render(buf, newStats(time.Unix(100, 0), 10, 1))
+	require.Contains(t, buf.String(), red+"       2"+reset, "Check drops are highlighted")
+	require.Contains(t, buf.String(), "["+red+"##########"+reset+"] 4/4", "Check full worker pool is highlighted")
+}
+
+func TestSparkline(t *testing.T) {
+	require.Equal(t, "", sparkline(nil))
+	require.Equal(t, "▁▁▁", sparkline([]int{3, 3, 3}))
+	require.Equal(t, "▁▄█▁", sparkline([]int{0, 7, 14, 0}))
+	require.Equal(t, "█▁", sparkline([]int{5, -5}))
+}
+
+func TestRun(t *testing.T) {
+	buf := &bytes.Buffer{}
+	mux := sync.Mutex{}
+	calls := 0
+	d := New(func() (metrics.PipelineStats, error) {
+		mux.Lock()
+		defer mux.Unlock()
+		calls++
+		if calls == 1 {
+			return metrics.PipelineStats{}, fmt.Errorf("connection refused")
+		}
+		return newStats(time.Now(), 10, calls), nil
+	}, &lockedWriter{buf: buf, mux: &mux}, Options{Interval: time.Millisecond})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	go d.Run(ctx, wg)
+	require.Eventually(t, func() bool {
+		mux.Lock()
+		defer mux.Unlock()
+		return calls > 2
+	}, time.Second, time.Millisecond)
+	cancel()
+	wg.Wait()
+
+	frames := strings.Split(buf.String(), clearScreen)
+	require.Equal(t, "", frames[0])
+	require.Equal(t, "unable to get pipeline stats: connection refused\n", frames[1], "Check source errors are rendered")
+	require.True(t, strings.HasPrefix(frames[2], "pipeline"))
+}
+
+// lockedWriter writes into the buffer under lock
+type lockedWriter struct {
+	buf *bytes.Buffer
+	mux *sync.Mutex
+}
+
+func (w *lockedWriter) Write(p []byte) (int, error) {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	return w.buf.Write(p)
+}
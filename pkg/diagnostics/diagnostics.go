@@ -0,0 +1,95 @@
+// Copyright 2024 Vladislav Klimenko. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diagnostics
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"time"
+
+	"github.com/sunsingerus/pipeline/pkg/admin"
+)
+
+// RecentPauses specifies number of the latest GC pauses reported
+const RecentPauses = 10
+
+// Runtime specifies runtime stats of the process
+type Runtime struct {
+	Goroutines int `json:"goroutines"`
+	// Heap stats in bytes, objects in number of objects
+	HeapAlloc   uint64 `json:"heapAlloc"`
+	HeapInuse   uint64 `json:"heapInuse"`
+	HeapObjects uint64 `json:"heapObjects"`
+	// Sys specifies bytes obtained from the OS
+	Sys uint64 `json:"sys"`
+	// TotalAlloc and Mallocs specify cumulative bytes and objects allocated
+	TotalAlloc uint64 `json:"totalAlloc"`
+	Mallocs    uint64 `json:"mallocs"`
+	NumGC      uint32 `json:"numGC"`
+	// PauseTotal specifies cumulative GC pause, Pauses specifies the latest GC pauses, the latest first
+	PauseTotal time.Duration   `json:"pauseTotal"`
+	Pauses     []time.Duration `json:"pauses"`
+	// LastGC specifies time of the last GC, zero in case there was no GC
+	LastGC time.Time `json:"lastGC"`
+}
+
+// ReadRuntime reads runtime stats of the process. Reading stats stops the world for a while
+func ReadRuntime() Runtime {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	stats := Runtime{
+		Goroutines:  runtime.NumGoroutine(),
+		HeapAlloc:   mem.HeapAlloc,
+		HeapInuse:   mem.HeapInuse,
+		HeapObjects: mem.HeapObjects,
+		Sys:         mem.Sys,
+		TotalAlloc:  mem.TotalAlloc,
+		Mallocs:     mem.Mallocs,
+		NumGC:       mem.NumGC,
+		PauseTotal:  time.Duration(mem.PauseTotalNs),
+	}
+	if mem.LastGC > 0 {
+		stats.LastGC = time.Unix(0, int64(mem.LastGC))
+	}
+	// Pauses are kept in the circular buffer, the latest one is at (NumGC+255)%256
+	for i := uint32(0); (i < RecentPauses) && (i < mem.NumGC); i++ {
+		stats.Pauses = append(stats.Pauses, time.Duration(mem.PauseNs[(mem.NumGC-1-i)%uint32(len(mem.PauseNs))]))
+	}
+	return stats
+}
+
+// RuntimeHandler returns HTTP handler, which responds with runtime stats as JSON
+func RuntimeHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(ReadRuntime())
+	})
+}
+
+// Register registers pprof handlers under /debug/pprof/ and runtime stats handler under /debug/runtime
+func Register(server *admin.Server) {
+	server.Handle("/debug/pprof/", http.HandlerFunc(pprof.Index))
+	server.Handle("/debug/pprof/cmdline", http.HandlerFunc(pprof.Cmdline))
+	server.Handle("/debug/pprof/profile", http.HandlerFunc(pprof.Profile))
+	server.Handle("/debug/pprof/symbol", http.HandlerFunc(pprof.Symbol))
+	server.Handle("/debug/pprof/trace", http.HandlerFunc(pprof.Trace))
+	server.Handle("/debug/runtime", RuntimeHandler())
+}
@@ -0,0 +1,76 @@
+// Copyright 2024 Vladislav Klimenko. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diagnostics
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRuntime(t *testing.T) {
+	runtime.GC()
+	stats := ReadRuntime()
+	require.Greater(t, stats.Goroutines, 0)
+	require.Greater(t, stats.HeapAlloc, uint64(0))
+	require.NotZero(t, stats.NumGC)
+	require.NotEmpty(t, stats.Pauses)
+	require.LessOrEqual(t, len(stats.Pauses), RecentPauses)
+	require.False(t, stats.LastGC.IsZero())
+
+	w := httptest.NewRecorder()
+	RuntimeHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/debug/runtime", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var decoded Runtime
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &decoded))
+	require.Greater(t, decoded.Goroutines, 0)
+}
+
+func TestProfiler(t *testing.T) {
+	dir := t.TempDir()
+	p := NewProfiler(ProfileOptions{Dir: dir, Duration: 50 * time.Millisecond})
+
+	ctx := context.Background()
+	var paths []string
+	var err error
+	done := make(chan struct{})
+	go func() {
+		paths, err = p.Capture(ctx)
+		close(done)
+	}()
+	require.Eventually(t, func() bool {
+		p.mux.Lock()
+		defer p.mux.Unlock()
+		return p.busy
+	}, time.Second, time.Millisecond)
+	_, busyErr := p.Capture(ctx)
+	require.EqualError(t, busyErr, "capture is in progress")
+
+	<-done
+	require.NoError(t, err)
+	require.Len(t, paths, 2)
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		require.Greater(t, info.Size(), int64(0), "Check profile %s is written", path)
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright 2024 Vladislav Klimenko. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diagnostics
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultProfileDuration specifies how long CPU profile is captured for, unless specified
+const DefaultProfileDuration = 30 * time.Second
+
+// ProfileOptions specifies options of the profiles capture
+type ProfileOptions struct {
+	// Dir specifies directory profiles are written into
+	Dir string
+	// Duration specifies how long CPU profile is captured for. Zero means default duration
+	Duration time.Duration
+}
+
+// Profiler captures CPU and heap profiles into files of the directory, one capture at a time
+type Profiler struct {
+	// busy specifies whether capture is in progress
+	busy bool
+	mux  sync.Mutex
+	ProfileOptions
+}
+
+// NewProfiler creates new profiler
+func NewProfiler(opts ProfileOptions) *Profiler {
+	if opts.Duration <= 0 {
+		opts.Duration = DefaultProfileDuration
+	}
+	return &Profiler{
+		ProfileOptions: opts,
+	}
+}
+
+// Capture captures CPU profile for the duration or until context is done, then captures heap profile.
+// Returns paths of the written profiles
+func (p *Profiler) Capture(ctx context.Context) ([]string, error) {
+	if p == nil {
+		return nil, fmt.Errorf("no profiler")
+	}
+	p.mux.Lock()
+	if p.busy {
+		p.mux.Unlock()
+		return nil, fmt.Errorf("capture is in progress")
+	}
+	p.busy = true
+	p.mux.Unlock()
+	defer func() {
+		p.mux.Lock()
+		p.busy = false
+		p.mux.Unlock()
+	}()
+
+	if err := os.MkdirAll(p.ProfileOptions.Dir, 0755); err != nil {
+		return nil, err
+	}
+	stamp := time.Now().Format("20060102T150405")
+	cpu := filepath.Join(p.ProfileOptions.Dir, "cpu-"+stamp+".pprof")
+	heap := filepath.Join(p.ProfileOptions.Dir, "heap-"+stamp+".pprof")
+
+	log.Infof("Profiler - capturing CPU profile for %s into %s", p.ProfileOptions.Duration, cpu)
+	if err := p.captureCPU(ctx, cpu); err != nil {
+		return nil, err
+	}
+	log.Infof("Profiler - capturing heap profile into %s", heap)
+	if err := captureHeap(heap); err != nil {
+		return []string{cpu}, err
+	}
+	return []string{cpu, heap}, nil
+}
+
+// captureCPU writes CPU profile captured for the duration or until context is done into the file
+func (p *Profiler) captureCPU(ctx context.Context, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := pprof.StartCPUProfile(f); err != nil {
+		_ = f.Close()
+		_ = os.Remove(path)
+		return err
+	}
+	timer := time.NewTimer(p.ProfileOptions.Duration)
+	select {
+	case <-ctx.Done():
+		timer.Stop()
+	case <-timer.C:
+	}
+	pprof.StopCPUProfile()
+	return f.Close()
+}
+
+// captureHeap writes heap profile as of the latest GC into the file
+func captureHeap(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	// Heap profile reflects the latest GC, so GC is run to have it up to date
+	runtime.GC()
+	if err := pprof.WriteHeapProfile(f); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,164 @@
+// Copyright 2024 Vladislav Klimenko. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logger
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Fields of the component log entries
+const (
+	FieldComponent = "component"
+	FieldStage     = "stage"
+	FieldWorkerID  = "worker_id"
+	FieldPacketID  = "packet_id"
+)
+
+// ComponentLevels specifies levels of components, which log with level other than Level,
+// as comma-separated list of component=level pairs, ex.: "processor=debug,generator=warn"
+var ComponentLevels string
+
+var (
+	// components specifies loggers of the components
+	components = make(map[string]*component)
+	// levels specifies levels of the components, which are set explicitly
+	levels = make(map[string]log.Level)
+	mux    sync.Mutex
+)
+
+// Component returns logger of the component. Component logs with its own level, which is Level unless set explicitly
+func Component(name string) *Entry {
+	mux.Lock()
+	defer mux.Unlock()
+
+	c, ok := components[name]
+	if !ok {
+		c = &component{logger: log.New()}
+		components[name] = c
+		configure(name, c)
+	}
+	return &Entry{Entry: c.logger.WithField(FieldComponent, name), component: c}
+}
+
+// configure makes component logger write as the standard logger does, with the component level and sampling.
+// Must be called under lock
+func configure(name string, c *component) {
+	std := log.StandardLogger()
+	logger := c.logger
+	logger.SetOutput(std.Out)
+	logger.SetFormatter(std.Formatter)
+	c.sampler.Store(samplerOf(name))
+	logger.SetReportCaller(std.ReportCaller)
+	logger.ReplaceHooks(std.Hooks)
+	if level, ok := levels[name]; ok {
+		logger.SetLevel(level)
+	} else {
+		logger.SetLevel(std.GetLevel())
+	}
+}
+
+// configureAll applies settings of the standard logger and levels to all component loggers
+func configureAll() {
+	mux.Lock()
+	defer mux.Unlock()
+	for name, c := range components {
+		configure(name, c)
+	}
+}
+
+// SetLevel sets level of the component. Empty component means Level, which applies to components with no level set explicitly
+func SetLevel(component, level string) error {
+	parsed, err := log.ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	if component == "" {
+		log.SetLevel(parsed)
+		Level = parsed.String()
+	} else {
+		mux.Lock()
+		levels[component] = parsed
+		mux.Unlock()
+	}
+	configureAll()
+	return nil
+}
+
+// ResetLevel makes component log with Level
+func ResetLevel(component string) {
+	mux.Lock()
+	delete(levels, component)
+	mux.Unlock()
+	configureAll()
+}
+
+// Levels returns levels of all components known so far and of components with level set explicitly.
+// Empty component specifies Level
+func Levels() map[string]string {
+	mux.Lock()
+	defer mux.Unlock()
+	result := map[string]string{
+		"": log.GetLevel().String(),
+	}
+	for name, c := range components {
+		result[name] = c.logger.GetLevel().String()
+	}
+	for name, level := range levels {
+		result[name] = level.String()
+	}
+	return result
+}
+
+// parseLevels parses comma-separated list of component=level pairs
+func parseLevels(str string) (map[string]log.Level, error) {
+	result := make(map[string]log.Level)
+	for _, pair := range strings.Split(str, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		component, level, ok := strings.Cut(pair, "=")
+		component = strings.TrimSpace(component)
+		if !ok || (component == "") {
+			return nil, fmt.Errorf("invalid component level %q, expected component=level", pair)
+		}
+		parsed, err := log.ParseLevel(strings.TrimSpace(level))
+		if err != nil {
+			return nil, fmt.Errorf("component %s: %v", component, err)
+		}
+		result[component] = parsed
+	}
+	return result, nil
+}
+
+// initComponents sets levels of the components from ComponentLevels
+func initComponents() {
+	parsed, err := parseLevels(ComponentLevels)
+	if err != nil {
+		log.Warnf("Component log levels - %v, fallback to log level", err)
+		parsed = make(map[string]log.Level)
+	}
+	mux.Lock()
+	levels = parsed
+	mux.Unlock()
+	configureAll()
+	if len(parsed) > 0 {
+		log.Infof("Set component log levels: %s", ComponentLevels)
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright 2024 Vladislav Klimenko. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logger
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLevels(t *testing.T) {
+	levels, err := parseLevels(" processor=debug, generator=warn,")
+	require.NoError(t, err)
+	require.Equal(t, map[string]log.Level{"processor": log.DebugLevel, "generator": log.WarnLevel}, levels)
+
+	for _, str := range []string{"processor", "=debug", "processor=loud"} {
+		_, err := parseLevels(str)
+		require.Error(t, err, "Check invalid levels: %s", str)
+	}
+}
+
+func TestComponentLevels(t *testing.T) {
+	Level, ComponentLevels = "info", "processor=debug"
+	Init()
+	processor, generator := Component("processor"), Component("generator")
+	require.Equal(t, "processor", processor.Data[FieldComponent])
+	require.True(t, processor.Logger.IsLevelEnabled(log.DebugLevel))
+	require.False(t, generator.Logger.IsLevelEnabled(log.DebugLevel))
+
+	// Level applies to components with no level set explicitly
+	require.NoError(t, SetLevel("", "warn"))
+	require.False(t, generator.Logger.IsLevelEnabled(log.InfoLevel))
+	require.True(t, processor.Logger.IsLevelEnabled(log.DebugLevel))
+
+	ResetLevel("processor")
+	require.False(t, processor.Logger.IsLevelEnabled(log.InfoLevel))
+	require.Error(t, SetLevel("processor", "loud"))
+
+	Level, ComponentLevels = "info", ""
+	Init()
+}
+
+func TestHandler(t *testing.T) {
+	Level, ComponentLevels = "info", ""
+	Init()
+	Component("accum")
+	handler := Handler()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/log/level", strings.NewReader(url.Values{"component": {"accum"}, "level": {"trace"}}.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	handler.ServeHTTP(w, r)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/log/level", nil))
+	require.Contains(t, w.Body.String(), "default info\n")
+	require.Contains(t, w.Body.String(), "accum trace\n")
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/log/level?component=accum&level=loud", nil))
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	ResetLevel("accum")
+}
@@ -0,0 +1,123 @@
+// Copyright 2024 Vladislav Klimenko. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logger
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// component specifies logger of the component and its sampler
+type component struct {
+	logger *log.Logger
+	// sampler is nil in case component is not sampled
+	sampler atomic.Pointer[sampler]
+}
+
+// Entry specifies log entry of the component.
+// Messages of sampled component are sampled before they are built, so suppressed messages cost neither formatting nor writing
+type Entry struct {
+	*log.Entry
+	component *component
+}
+
+// WithField returns entry with the field added
+func (e *Entry) WithField(key string, value any) *Entry {
+	return &Entry{Entry: e.Entry.WithField(key, value), component: e.component}
+}
+
+// WithFields returns entry with the fields added
+func (e *Entry) WithFields(fields log.Fields) *Entry {
+	return &Entry{Entry: e.Entry.WithFields(fields), component: e.component}
+}
+
+// allow returns true in case message of the level, which event is specified by the format, is to be logged
+func (e *Entry) allow(level log.Level, format string) bool {
+	if !e.Logger.IsLevelEnabled(level) {
+		return false
+	}
+	s := e.component.sampler.Load()
+	return (s == nil) || s.allow(level, event(format))
+}
+
+// Logf logs message of the level, unless the level is disabled or the message is suppressed
+func (e *Entry) Logf(level log.Level, format string, args ...any) {
+	if e.allow(level, format) {
+		e.Entry.Logf(level, format, args...)
+	}
+}
+
+// Log logs message of the level, unless the level is disabled or the message is suppressed
+func (e *Entry) Log(level log.Level, args ...any) {
+	if !e.Logger.IsLevelEnabled(level) {
+		return
+	}
+	message := fmt.Sprint(args...)
+	if e.allow(level, message) {
+		e.Entry.Log(level, message)
+	}
+}
+
+// Tracef logs message of the trace level
+func (e *Entry) Tracef(format string, args ...any) {
+	e.Logf(log.TraceLevel, format, args...)
+}
+
+// Debugf logs message of the debug level
+func (e *Entry) Debugf(format string, args ...any) {
+	e.Logf(log.DebugLevel, format, args...)
+}
+
+// Infof logs message of the info level
+func (e *Entry) Infof(format string, args ...any) {
+	e.Logf(log.InfoLevel, format, args...)
+}
+
+// Warnf logs message of the warn level
+func (e *Entry) Warnf(format string, args ...any) {
+	e.Logf(log.WarnLevel, format, args...)
+}
+
+// Errorf logs message of the error level
+func (e *Entry) Errorf(format string, args ...any) {
+	e.Logf(log.ErrorLevel, format, args...)
+}
+
+// Trace logs message of the trace level
+func (e *Entry) Trace(args ...any) {
+	e.Log(log.TraceLevel, args...)
+}
+
+// Debug logs message of the debug level
+func (e *Entry) Debug(args ...any) {
+	e.Log(log.DebugLevel, args...)
+}
+
+// Info logs message of the info level
+func (e *Entry) Info(args ...any) {
+	e.Log(log.InfoLevel, args...)
+}
+
+// Warn logs message of the warn level
+func (e *Entry) Warn(args ...any) {
+	e.Log(log.WarnLevel, args...)
+}
+
+// Error logs message of the error level
+func (e *Entry) Error(args ...any) {
+	e.Log(log.ErrorLevel, args...)
+}
@@ -0,0 +1,180 @@
+// Copyright 2024 Vladislav Klimenko. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logger
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// LogfmtFormatter formats entries as logfmt key=value pairs: time, level, msg and fields sorted by key
+type LogfmtFormatter struct{}
+
+// Format formats entry
+func (f *LogfmtFormatter) Format(entry *log.Entry) ([]byte, error) {
+	b := &bytes.Buffer{}
+	appendPair(b, "time", entry.Time.Format(time.RFC3339Nano))
+	appendPair(b, "level", entry.Level.String())
+	appendPair(b, "msg", entry.Message)
+	for _, key := range sortedKeys(entry.Data) {
+		appendPair(b, key, stringify(entry.Data[key]))
+	}
+	b.WriteByte('\n')
+	return b.Bytes(), nil
+}
+
+// appendPair appends key=value pair, separated by space from the previous one
+func appendPair(b *bytes.Buffer, key, value string) {
+	if b.Len() > 0 {
+		b.WriteByte(' ')
+	}
+	b.WriteString(key)
+	b.WriteByte('=')
+	b.WriteString(quote(value))
+}
+
+// quote quotes value, in case it is empty or contains spaces, quotes, equal signs or control characters
+func quote(value string) string {
+	if value == "" {
+		return `""`
+	}
+	for _, r := range value {
+		if (r <= ' ') || (r == '=') || (r == '"') || (r == 0x7f) {
+			return strconv.Quote(value)
+		}
+	}
+	return value
+}
+
+// stringify returns string representation of the field value
+func stringify(value any) string {
+	if err, ok := value.(error); ok {
+		return err.Error()
+	}
+	return fmt.Sprint(value)
+}
+
+// sortedKeys returns keys of the fields in sorted order
+func sortedKeys(fields log.Fields) []string {
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ANSI colors of the console formatter
+const (
+	colorReset  = "\x1b[0m"
+	colorRed    = "\x1b[31m"
+	colorGreen  = "\x1b[32m"
+	colorYellow = "\x1b[33m"
+	colorCyan   = "\x1b[36m"
+	colorGray   = "\x1b[90m"
+)
+
+// ConsoleFormatter formats entries compactly for humans: time, level, component, message and the rest of fields
+type ConsoleFormatter struct {
+	// Colors specifies whether level and fields are colored with ANSI escapes
+	Colors bool
+}
+
+// Format formats entry
+func (f *ConsoleFormatter) Format(entry *log.Entry) ([]byte, error) {
+	b := &bytes.Buffer{}
+	b.WriteString(entry.Time.Format("15:04:05.000"))
+	b.WriteByte(' ')
+	f.colored(b, levelColor(entry.Level), levelAbbr(entry.Level))
+	if component, ok := entry.Data[FieldComponent]; ok {
+		b.WriteByte(' ')
+		f.colored(b, colorCyan, stringify(component))
+	}
+	b.WriteByte(' ')
+	b.WriteString(entry.Message)
+	for _, key := range sortedKeys(entry.Data) {
+		if key == FieldComponent {
+			continue
+		}
+		b.WriteByte(' ')
+		f.colored(b, colorGray, key+"=")
+		b.WriteString(quote(stringify(entry.Data[key])))
+	}
+	b.WriteByte('\n')
+	return b.Bytes(), nil
+}
+
+// colored writes text in color, if colors are enabled
+func (f *ConsoleFormatter) colored(b *bytes.Buffer, color, text string) {
+	if !f.Colors {
+		b.WriteString(text)
+		return
+	}
+	b.WriteString(color)
+	b.WriteString(text)
+	b.WriteString(colorReset)
+}
+
+// levelAbbr returns three-letter abbreviation of the level
+func levelAbbr(level log.Level) string {
+	switch level {
+	case log.TraceLevel:
+		return "TRC"
+	case log.DebugLevel:
+		return "DBG"
+	case log.InfoLevel:
+		return "INF"
+	case log.WarnLevel:
+		return "WRN"
+	case log.ErrorLevel:
+		return "ERR"
+	case log.FatalLevel:
+		return "FTL"
+	case log.PanicLevel:
+		return "PNC"
+	}
+	return strings.ToUpper(level.String())
+}
+
+// levelColor returns color of the level
+func levelColor(level log.Level) string {
+	switch level {
+	case log.TraceLevel, log.DebugLevel:
+		return colorGray
+	case log.InfoLevel:
+		return colorGreen
+	case log.WarnLevel:
+		return colorYellow
+	}
+	return colorRed
+}
+
+// IsTerminal returns true in case output is a terminal
+func IsTerminal(out io.Writer) bool {
+	file, ok := out.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := file.Stat()
+	return (err == nil) && (info.Mode()&os.ModeCharDevice != 0)
+}
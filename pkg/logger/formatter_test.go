@@ -0,0 +1,63 @@
+// Copyright 2024 Vladislav Klimenko. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logger
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func newEntry() *log.Entry {
+	return &log.Entry{
+		Time:    time.Date(2024, 1, 2, 3, 4, 5, 6000000, time.UTC),
+		Level:   log.WarnLevel,
+		Message: "rejected: [1 2]",
+		Data: log.Fields{
+			FieldComponent: "processor",
+			FieldPacketID:  7,
+			"reason":       errors.New("no pair"),
+			"empty":        "",
+		},
+	}
+}
+
+func TestLogfmtFormatter(t *testing.T) {
+	b, err := (&LogfmtFormatter{}).Format(newEntry())
+	require.NoError(t, err)
+	require.Equal(t, `time=2024-01-02T03:04:05.006Z level=warning msg="rejected: [1 2]" component=processor empty="" packet_id=7 reason="no pair"`+"\n", string(b))
+}
+
+func TestConsoleFormatter(t *testing.T) {
+	b, err := (&ConsoleFormatter{}).Format(newEntry())
+	require.NoError(t, err)
+	require.Equal(t, `03:04:05.006 WRN processor rejected: [1 2] empty="" packet_id=7 reason="no pair"`+"\n", string(b))
+
+	b, err = (&ConsoleFormatter{Colors: true}).Format(newEntry())
+	require.NoError(t, err)
+	require.Contains(t, string(b), colorYellow+"WRN"+colorReset)
+}
+
+func TestParseFormatter(t *testing.T) {
+	for _, str := range []string{"json", "text", "logfmt", "console"} {
+		_, err := parseFormatter(str, nil)
+		require.NoError(t, err, "Check formatter: %s", str)
+	}
+	_, err := parseFormatter("xml", nil)
+	require.Error(t, err)
+}
@@ -0,0 +1,62 @@
+// Copyright 2024 Vladislav Klimenko. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logger
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// LevelReset resets level of the component to Level, when set by the handler
+const LevelReset = "reset"
+
+// Handler returns HTTP handler of the log levels.
+// GET lists levels of the components, POST or PUT with component and level parameters sets level of the component.
+// Empty component sets Level.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPost, http.MethodPut:
+			component, level := r.FormValue("component"), r.FormValue("level")
+			if (level == LevelReset) && (component != "") {
+				ResetLevel(component)
+			} else if err := SetLevel(component, level); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			log.Infof("Log level of %q set to %s", component, level)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		levels := Levels()
+		components := make([]string, 0, len(levels))
+		for component := range levels {
+			if component != "" {
+				components = append(components, component)
+			}
+		}
+		sort.Strings(components)
+		_, _ = fmt.Fprintf(w, "default %s\n", levels[""])
+		for _, component := range components {
+			_, _ = fmt.Fprintf(w, "%s %s\n", component, levels[component])
+		}
+	})
+}
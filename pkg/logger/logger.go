@@ -16,6 +16,8 @@ package logger
 
 import (
 	"fmt"
+	"io"
+	"os"
 	"strings"
 
 	log "github.com/sirupsen/logrus"
@@ -37,12 +39,23 @@ var (
 	// Available formatters are:
 	// "json"
 	// "text", "txt"
+	// "logfmt"
+	// "console"
 	Formatter string
+
+	// File specifies log file. Logs are written to stderr in case no path is specified
+	File FileOptions
 )
 
+// output specifies log file logs are written to, if any
+var output *RotatingFile
+
 // Init sets logging options
 func Init() {
-	if formatter, err := parseFormatter(Formatter); err == nil {
+	closeOutput := initOutput()
+	defer closeOutput()
+
+	if formatter, err := parseFormatter(Formatter, log.StandardLogger().Out); err == nil {
 		log.SetFormatter(formatter)
 		log.Infof("Set formatter: %s", Formatter)
 	} else {
@@ -57,15 +70,61 @@ func Init() {
 		log.SetLevel(log.InfoLevel)
 		log.Infof("Set default log level - Info")
 	}
+
+	initComponents()
+	initSampling()
+}
+
+// initOutput opens log file, in case it is specified and differs from the current one.
+// Returns function, which closes the replaced log file once nothing writes into it
+func initOutput() func() {
+	replaced := output
+	if (replaced == nil) && (File.Path == "") {
+		return func() {}
+	}
+	if (replaced != nil) && (replaced.FileOptions == File) {
+		return func() {}
+	}
+
+	output = nil
+	if File.Path == "" {
+		log.SetOutput(os.Stderr)
+	} else if file, err := NewRotatingFile(File); err == nil {
+		output = file
+		log.SetOutput(file)
+	} else {
+		log.SetOutput(os.Stderr)
+		log.Warnf("Log file - %v, fallback to stderr", err)
+	}
+	return func() {
+		if replaced != nil {
+			_ = replaced.Close()
+		}
+	}
+}
+
+// Close closes log file, if any. Logs are written to stderr afterwards
+func Close() {
+	if output == nil {
+		return
+	}
+	log.SetOutput(os.Stderr)
+	configureAll()
+	_ = output.Close()
+	output = nil
 }
 
-// parseFormatter makes Formatter out of its string name
-func parseFormatter(str string) (log.Formatter, error) {
+// parseFormatter makes Formatter out of its string name. Console formatter is colored in case output is a terminal
+func parseFormatter(str string, out io.Writer) (log.Formatter, error) {
 	switch strings.ToLower(str) {
 	case "json":
 		return &log.JSONFormatter{}, nil
 	case "txt", "text":
 		return &log.TextFormatter{}, nil
+	case "logfmt":
+		return &LogfmtFormatter{}, nil
+	case "console":
+		return &ConsoleFormatter{Colors: IsTerminal(out)}, nil
 	}
 
 	return nil, fmt.Errorf("not a valid logrus formatter: %q", str)
@@ -0,0 +1,319 @@
+// Copyright 2024 Vladislav Klimenko. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logger
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Defaults of the log file rotation
+const (
+	DefaultFileMaxSize    = 100 * 1024 * 1024
+	DefaultFileMaxAge     = 24 * time.Hour
+	DefaultFileMaxBackups = 5
+)
+
+// backupTimeFormat specifies format of the rotation time in names of the rotated files, which sorts in time order
+const backupTimeFormat = "20060102T150405.000"
+
+// backupSeqSeparator separates rotation time and sequence number of the files rotated within the same millisecond
+const backupSeqSeparator = "-"
+
+// compressedExt specifies extension of the compressed rotated files
+const compressedExt = ".gz"
+
+// FileOptions specifies log file options
+type FileOptions struct {
+	// Path specifies path of the log file
+	Path string
+	// MaxSize specifies size in bytes file is rotated on reaching. Zero means no size-based rotation
+	MaxSize int64
+	// MaxAge specifies age file is rotated on reaching. Zero means no age-based rotation
+	MaxAge time.Duration
+	// MaxBackups specifies number of rotated files kept. Zero means all of them are kept
+	MaxBackups int
+	// Compress specifies whether rotated files are compressed with gzip
+	Compress bool
+}
+
+// RotatingFile specifies log file, which is rotated on reaching max size or max age.
+// Rotated file is renamed to <path>.<rotation time>, optionally compressed, and the oldest rotated files are removed
+type RotatingFile struct {
+	file *os.File
+	// size specifies size of the current file
+	size int64
+	// opened specifies time the current file is opened at
+	opened time.Time
+	// now returns current time, replaced by tests
+	now func() time.Time
+	// closed specifies whether the file is closed by Close, rather than left closed by failed rotation
+	closed bool
+	mux    sync.Mutex
+	// rotated specifies rotated files waiting for compression and removal, the oldest first
+	rotated []string
+	// milling specifies whether rotated files are being processed
+	milling bool
+	// mill specifies processing of the rotated files in progress
+	mill sync.WaitGroup
+	FileOptions
+}
+
+// NewRotatingFile opens log file for appending
+func NewRotatingFile(opts FileOptions) (*RotatingFile, error) {
+	if opts.Path == "" {
+		return nil, fmt.Errorf("no log file path")
+	}
+	f := &RotatingFile{
+		now:         time.Now,
+		FileOptions: opts,
+	}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// open opens the log file. Age of the existing file is counted from its modification time
+func (f *RotatingFile) open() error {
+	if err := os.MkdirAll(filepath.Dir(f.Path), 0o755); err != nil {
+		return fmt.Errorf("unable to make log file directory: %v", err)
+	}
+	file, err := os.OpenFile(f.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("unable to open log file: %v", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return fmt.Errorf("unable to stat log file: %v", err)
+	}
+	f.file, f.size, f.opened = file, info.Size(), f.now()
+	if info.Size() > 0 {
+		f.opened = info.ModTime()
+	}
+	return nil
+}
+
+// Write writes to the log file, rotating it beforehand in case it has reached max size or max age
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	if f.closed {
+		return 0, os.ErrClosed
+	}
+	if f.file == nil {
+		// Reopening failed on the last rotation, so it is retried
+		if err := f.open(); err != nil {
+			return 0, err
+		}
+	}
+
+	full := (f.MaxSize > 0) && (f.size > 0) && (f.size+int64(len(p)) > f.MaxSize)
+	old := (f.MaxAge > 0) && (f.size > 0) && (f.now().Sub(f.opened) >= f.MaxAge)
+	if full || old {
+		if err := f.rotate(); err != nil {
+			if f.file == nil {
+				return 0, err
+			}
+			// Original file is reopened, so the write is not lost
+			_, _ = fmt.Fprintf(os.Stderr, "Log file - %v\n", err)
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// rotate renames the current file and opens the new one.
+// In case rotation fails, the file at the original path is reopened. Must be called under lock
+func (f *RotatingFile) rotate() error {
+	err := f.file.Close()
+	f.file = nil
+	if err != nil {
+		return f.reopen(fmt.Errorf("unable to close log file: %v", err))
+	}
+	backup := f.backup(f.now().Format(backupTimeFormat))
+	if err := os.Rename(f.Path, backup); err != nil {
+		return f.reopen(fmt.Errorf("unable to rotate log file: %v", err))
+	}
+	if err := f.open(); err != nil {
+		return err
+	}
+	// Rotated files are processed in background one at a time in rotation order, so writes are not blocked by compression
+	f.rotated = append(f.rotated, backup)
+	if !f.milling {
+		f.milling = true
+		f.mill.Add(1)
+		go f.millAll()
+	}
+	return nil
+}
+
+// backup returns path of the rotated file of the rotation time, which does not exist yet.
+// Files rotated within the same millisecond are numbered, so the earlier ones are not overwritten
+func (f *RotatingFile) backup(stamp string) string {
+	backup := f.Path + "." + stamp
+	for seq := 1; exists(backup) || exists(backup+compressedExt); seq++ {
+		backup = f.Path + "." + stamp + backupSeqSeparator + strconv.Itoa(seq)
+	}
+	return backup
+}
+
+// exists checks whether file of the path exists
+func exists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
+
+// reopen reopens the file at the original path after failed rotation and returns the rotation error
+func (f *RotatingFile) reopen(err error) error {
+	if e := f.open(); e != nil {
+		return fmt.Errorf("%v; %v", err, e)
+	}
+	return err
+}
+
+// millAll processes rotated files until none is left
+func (f *RotatingFile) millAll() {
+	defer f.mill.Done()
+	for {
+		f.mux.Lock()
+		if len(f.rotated) == 0 {
+			f.milling = false
+			f.mux.Unlock()
+			return
+		}
+		backup := f.rotated[0]
+		f.rotated = f.rotated[1:]
+		f.mux.Unlock()
+		f.millRotated(backup)
+	}
+}
+
+// millRotated compresses rotated file, if needed, and removes the oldest rotated files beyond max backups
+func (f *RotatingFile) millRotated(backup string) {
+	if f.Compress {
+		if err := compress(backup); err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Log file - unable to compress %s: %v\n", backup, err)
+		}
+	}
+	if f.MaxBackups <= 0 {
+		return
+	}
+	backups, err := f.Backups()
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Log file - unable to list rotated files: %v\n", err)
+		return
+	}
+	for len(backups) > f.MaxBackups {
+		if err := os.Remove(backups[0]); err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Log file - unable to remove %s: %v\n", backups[0], err)
+		}
+		backups = backups[1:]
+	}
+}
+
+// Backups returns paths of the rotated files, the oldest first
+func (f *RotatingFile) Backups() ([]string, error) {
+	matches, err := filepath.Glob(f.Path + ".*")
+	if err != nil {
+		return nil, err
+	}
+	type backup struct {
+		path  string
+		stamp string
+		seq   int
+	}
+	var backups []backup
+	for _, match := range matches {
+		stamp := strings.TrimSuffix(strings.TrimPrefix(match, f.Path+"."), compressedExt)
+		seq := 0
+		if i := strings.LastIndex(stamp, backupSeqSeparator); i >= 0 {
+			if seq, err = strconv.Atoi(stamp[i+len(backupSeqSeparator):]); (err != nil) || (seq <= 0) {
+				continue
+			}
+			stamp = stamp[:i]
+		}
+		if _, err := time.Parse(backupTimeFormat, stamp); err == nil {
+			backups = append(backups, backup{path: match, stamp: stamp, seq: seq})
+		}
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		if backups[i].stamp != backups[j].stamp {
+			return backups[i].stamp < backups[j].stamp
+		}
+		return backups[i].seq < backups[j].seq
+	})
+	paths := make([]string, len(backups))
+	for i := range backups {
+		paths[i] = backups[i].path
+	}
+	return paths, nil
+}
+
+// compress compresses file with gzip and removes the original
+func compress(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.OpenFile(path+compressedExt, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(dst)
+	if _, err := io.Copy(zw, src); err != nil {
+		_ = zw.Close()
+		_ = dst.Close()
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		_ = dst.Close()
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// Close closes the log file and waits for rotated files to be processed
+func (f *RotatingFile) Close() error {
+	f.mux.Lock()
+	if f.closed {
+		f.mux.Unlock()
+		return nil
+	}
+	f.closed = true
+	var err error
+	if f.file != nil {
+		err = f.file.Close()
+		f.file = nil
+	}
+	f.mux.Unlock()
+	// Closed file is not rotated, so no processing is started while waiting
+	f.mill.Wait()
+	return err
+}
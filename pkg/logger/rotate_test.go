@@ -0,0 +1,134 @@
+// Copyright 2024 Vladislav Klimenko. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logger
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRotatingFileSize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "pipeline.log")
+	f, err := NewRotatingFile(FileOptions{Path: path, MaxSize: 10, MaxBackups: 2})
+	require.NoError(t, err)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	f.now = func() time.Time {
+		now = now.Add(time.Second)
+		return now
+	}
+
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		_, err := f.Write([]byte(line))
+		require.NoError(t, err)
+	}
+	require.NoError(t, f.Close())
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "fourth\n", string(content))
+	backups, err := f.Backups()
+	require.NoError(t, err)
+	require.Len(t, backups, 2, "Check the oldest rotated file is removed")
+	content, err = os.ReadFile(backups[1])
+	require.NoError(t, err)
+	require.Equal(t, "third\n", string(content))
+}
+
+func TestRotatingFileAge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pipeline.log")
+	f, err := NewRotatingFile(FileOptions{Path: path, MaxAge: time.Hour, Compress: true})
+	require.NoError(t, err)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	f.now = func() time.Time { return now }
+	f.opened = now
+
+	_, err = f.Write([]byte("old\n"))
+	require.NoError(t, err)
+	now = now.Add(time.Hour)
+	_, err = f.Write([]byte("new\n"))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	backups, err := f.Backups()
+	require.NoError(t, err)
+	require.Len(t, backups, 1)
+	require.True(t, strings.HasSuffix(backups[0], compressedExt), "Check rotated file is compressed")
+
+	file, err := os.Open(backups[0])
+	require.NoError(t, err)
+	defer file.Close()
+	zr, err := gzip.NewReader(file)
+	require.NoError(t, err)
+	content, err := io.ReadAll(zr)
+	require.NoError(t, err)
+	require.Equal(t, "old\n", string(content))
+}
+
+func TestRotatingFileRenameFailure(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pipeline.log")
+	f, err := NewRotatingFile(FileOptions{Path: path, MaxSize: 10})
+	require.NoError(t, err)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	f.now = func() time.Time { return now }
+
+	_, err = f.Write([]byte("first\n"))
+	require.NoError(t, err)
+	// Log file removed from under the writer fails the rename
+	require.NoError(t, os.Remove(path))
+	_, err = f.Write([]byte("second\n"))
+	require.NoError(t, err, "Check write continues to the original path")
+	require.NoError(t, f.Close())
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "second\n", string(content))
+	backups, err := f.Backups()
+	require.NoError(t, err)
+	require.Empty(t, backups)
+	_, err = f.Write([]byte("third\n"))
+	require.ErrorIs(t, err, os.ErrClosed)
+}
+
+func TestRotatingFileSameMillisecond(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pipeline.log")
+	f, err := NewRotatingFile(FileOptions{Path: path, MaxSize: 5})
+	require.NoError(t, err)
+	// All rotations happen at the same time
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	f.now = func() time.Time { return now }
+
+	lines := []string{"1111\n", "2222\n", "3333\n", "4444\n"}
+	for _, line := range lines {
+		_, err := f.Write([]byte(line))
+		require.NoError(t, err)
+	}
+	require.NoError(t, f.Close())
+
+	backups, err := f.Backups()
+	require.NoError(t, err)
+	require.Len(t, backups, 3, "Check no rotated file is overwritten")
+	for i, backup := range backups {
+		content, err := os.ReadFile(backup)
+		require.NoError(t, err)
+		require.Equal(t, lines[i], string(content), "Check rotated files are in rotation order")
+	}
+}
@@ -0,0 +1,244 @@
+// Copyright 2024 Vladislav Klimenko. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logger
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// FieldSuppressed specifies field of the summary of the suppressed messages
+const FieldSuppressed = "suppressed"
+
+// SamplingAll specifies sampling of all components, which have no sampling specified
+const SamplingAll = "*"
+
+// DefaultSamplingInterval specifies interval sampling starts over, unless specified
+const DefaultSamplingInterval = time.Second
+
+var (
+	// Sampling specifies sampling of the component logs as comma-separated list of component=first/every items,
+	// ex.: "processor=10/100,*=5/0". Each interval first messages of each event are logged, then every one of them
+	Sampling string
+	// SamplingInterval specifies interval sampling starts over and suppressed messages are summarized
+	SamplingInterval = DefaultSamplingInterval
+)
+
+var (
+	// samplers specifies samplers of the components
+	samplers = make(map[string]*sampler)
+	// samplingAll specifies sampling of the components with no sampling specified, if any
+	samplingAll *SamplingOptions
+	// samplingStop stops summarizing of the suppressed messages
+	samplingStop chan struct{}
+)
+
+// SamplingOptions specifies sampling of the component log
+type SamplingOptions struct {
+	// First specifies number of messages of each event logged each interval
+	First int
+	// Every specifies that every such message of each event is logged after the first ones. Zero means none
+	Every int
+}
+
+// sampler suppresses repeated messages. Messages are sampled by level and event,
+// which is the format of the message up to the first colon, ex.: "delivered" of "delivered: %s"
+type sampler struct {
+	SamplingOptions
+	counters map[key]*counter
+	mux      sync.Mutex
+}
+
+// key specifies messages sampled together
+type key struct {
+	level log.Level
+	event string
+}
+
+// counter specifies number of messages of the interval
+type counter struct {
+	count      int
+	suppressed int
+}
+
+func newSampler(opts SamplingOptions) *sampler {
+	return &sampler{
+		SamplingOptions: opts,
+		counters:        make(map[key]*counter),
+	}
+}
+
+// event returns event of the message format
+func event(format string) string {
+	if i := strings.IndexByte(format, ':'); i >= 0 {
+		return format[:i]
+	}
+	return format
+}
+
+// allow returns true in case message of the level and the event is to be logged
+func (s *sampler) allow(level log.Level, event string) bool {
+	k := key{level: level, event: event}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	c, ok := s.counters[k]
+	if !ok {
+		c = &counter{}
+		s.counters[k] = c
+	}
+	c.count++
+	if (c.count <= s.First) || ((s.Every > 0) && ((c.count-s.First)%s.Every == 0)) {
+		return true
+	}
+	c.suppressed++
+	return false
+}
+
+// summary specifies number of suppressed messages of the event
+type summary struct {
+	key
+	suppressed int
+}
+
+// flush starts sampling over. Returns summaries of the suppressed messages, sorted by event
+func (s *sampler) flush() []summary {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	var summaries []summary
+	for k, c := range s.counters {
+		if c.suppressed > 0 {
+			summaries = append(summaries, summary{key: k, suppressed: c.suppressed})
+		}
+	}
+	s.counters = make(map[key]*counter)
+	sort.Slice(summaries, func(i, j int) bool {
+		return summaries[i].event < summaries[j].event
+	})
+	return summaries
+}
+
+// samplerOf returns sampler of the component, if it is sampled. Must be called under lock
+func samplerOf(component string) *sampler {
+	if s, ok := samplers[component]; ok {
+		return s
+	}
+	if samplingAll == nil {
+		return nil
+	}
+	s := newSampler(*samplingAll)
+	samplers[component] = s
+	return s
+}
+
+// parseSampling parses comma-separated list of component=first/every items
+func parseSampling(str string) (map[string]SamplingOptions, error) {
+	result := make(map[string]SamplingOptions)
+	for _, item := range strings.Split(str, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		component, spec, ok := strings.Cut(item, "=")
+		component = strings.TrimSpace(component)
+		if !ok || (component == "") {
+			return nil, fmt.Errorf("invalid sampling %q, expected component=first/every", item)
+		}
+		first, every, _ := strings.Cut(spec, "/")
+		var opts SamplingOptions
+		var err error
+		if opts.First, err = strconv.Atoi(strings.TrimSpace(first)); err != nil || (opts.First < 0) {
+			return nil, fmt.Errorf("invalid first %q of component %s sampling", first, component)
+		}
+		if every = strings.TrimSpace(every); every != "" {
+			if opts.Every, err = strconv.Atoi(every); err != nil || (opts.Every < 0) {
+				return nil, fmt.Errorf("invalid every %q of component %s sampling", every, component)
+			}
+		}
+		result[component] = opts
+	}
+	return result, nil
+}
+
+// initSampling sets sampling of the components from Sampling and starts summarizing of the suppressed messages
+func initSampling() {
+	parsed, err := parseSampling(Sampling)
+	if err != nil {
+		log.Warnf("Log sampling - %v, fallback to no sampling", err)
+		parsed = nil
+	}
+	interval := SamplingInterval
+	if interval <= 0 {
+		interval = DefaultSamplingInterval
+	}
+
+	mux.Lock()
+	if samplingStop != nil {
+		close(samplingStop)
+		samplingStop = nil
+	}
+	samplers = make(map[string]*sampler)
+	samplingAll = nil
+	for component, opts := range parsed {
+		if component == SamplingAll {
+			opts := opts
+			samplingAll = &opts
+			continue
+		}
+		samplers[component] = newSampler(opts)
+	}
+	if len(parsed) > 0 {
+		samplingStop = make(chan struct{})
+		go summarize(interval, samplingStop)
+	}
+	mux.Unlock()
+
+	configureAll()
+	if len(parsed) > 0 {
+		log.Infof("Set log sampling: %s every %s", Sampling, interval)
+	}
+}
+
+// summarize logs summaries of the suppressed messages and starts sampling over each interval until stopped
+func summarize(interval time.Duration, stop chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			mux.Lock()
+			flushed := make(map[string][]summary)
+			for component, s := range samplers {
+				flushed[component] = s.flush()
+			}
+			mux.Unlock()
+			for component, summaries := range flushed {
+				for _, summary := range summaries {
+					// Summary is not sampled
+					Component(component).Entry.WithField(FieldSuppressed, summary.suppressed).
+						Logf(summary.level, "suppressed %d messages: %s", summary.suppressed, summary.event)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright 2024 Vladislav Klimenko. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logger
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSampler(t *testing.T) {
+	s := newSampler(SamplingOptions{First: 2, Every: 3})
+	allowed := 0
+	for i := 0; i < 10; i++ {
+		if s.allow(log.InfoLevel, event("delivered: %s")) {
+			allowed++
+		}
+	}
+	require.Equal(t, 2+2, allowed, "Check first 2 and then every 3rd of 8 are allowed")
+	require.True(t, s.allow(log.InfoLevel, event("other: %d")), "Check other event is sampled separately")
+	require.True(t, s.allow(log.WarnLevel, event("delivered: %s")), "Check other level is sampled separately")
+
+	summaries := s.flush()
+	require.Len(t, summaries, 1)
+	require.Equal(t, 6, summaries[0].suppressed)
+	require.Empty(t, s.flush(), "Check sampling starts over")
+}
+
+func TestParseSampling(t *testing.T) {
+	sampling, err := parseSampling("processor=10/100, *=5")
+	require.NoError(t, err)
+	require.Equal(t, map[string]SamplingOptions{
+		"processor": {First: 10, Every: 100},
+		SamplingAll: {First: 5},
+	}, sampling)
+
+	for _, str := range []string{"processor", "processor=x/1", "processor=1/-1"} {
+		_, err := parseSampling(str)
+		require.Error(t, err, "Check invalid sampling: %s", str)
+	}
+}
+
+// buffer specifies log output safe for concurrent use
+type buffer struct {
+	buf bytes.Buffer
+	mux sync.Mutex
+}
+
+func (b *buffer) Write(p []byte) (int, error) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *buffer) lines(substrs ...string) int {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	count := 0
+	for _, line := range strings.Split(b.buf.String(), "\n") {
+		matches := true
+		for _, substr := range substrs {
+			matches = matches && strings.Contains(line, substr)
+		}
+		if matches {
+			count++
+		}
+	}
+	return count
+}
+
+func TestSampling(t *testing.T) {
+	out := &buffer{}
+	log.SetOutput(out)
+	Level, Sampling, SamplingInterval = "info", "sampled=2/0", 20*time.Millisecond
+	Init()
+
+	for i := 0; i < 5; i++ {
+		Component("sampled").Infof("delivered: %d", i)
+		Component("unsampled").Infof("delivered: %d", i)
+	}
+	require.Eventually(t, func() bool {
+		return out.lines("suppressed 3 messages: delivered", "component=sampled") == 1
+	}, time.Second, 5*time.Millisecond)
+	require.Equal(t, 2, out.lines(`msg="delivered: `, "component=sampled"))
+	require.Equal(t, 5, out.lines(`msg="delivered: `, "component=unsampled"))
+
+	Sampling, SamplingInterval = "", DefaultSamplingInterval
+	Init()
+	log.SetOutput(os.Stderr)
+}
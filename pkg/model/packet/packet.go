@@ -19,7 +19,11 @@ import (
 	"strconv"
 )
 
-type Packet []int
+// Packet specifies packet of values identified by id
+type Packet struct {
+	id     uint64
+	values []int
+}
 
 func New(what any) *Packet {
 	switch _typed := what.(type) {
@@ -32,34 +36,52 @@ func New(what any) *Packet {
 }
 
 func newFromLen(_len int) *Packet {
-	packet := make(Packet, _len)
-	return &packet
+	return &Packet{
+		values: make([]int, _len),
+	}
 }
 
 func newFromSlice(slice []int) *Packet {
-	packet := Packet(slice)
-	return &packet
+	return &Packet{
+		values: slice,
+	}
+}
+
+// ID returns id of the packet
+func (p *Packet) ID() uint64 {
+	if p == nil {
+		return 0
+	}
+	return p.id
+}
+
+// SetID sets id of the packet
+func (p *Packet) SetID(id uint64) {
+	if p == nil {
+		return
+	}
+	p.id = id
 }
 
 func (p *Packet) Len() int {
 	if p == nil {
 		return 0
 	}
-	return len(*p)
+	return len(p.values)
 }
 
 func (p *Packet) Set(i int, value int) {
 	if p == nil {
 		return
 	}
-	(*p)[i] = value
+	p.values[i] = value
 }
 
 func (p *Packet) Get(i int) int {
 	if p == nil {
 		return 0
 	}
-	return (*p)[i]
+	return p.values[i]
 }
 
 func (p *Packet) Slice(boundaries ...int) []int {
@@ -69,19 +91,19 @@ func (p *Packet) Slice(boundaries ...int) []int {
 	switch len(boundaries) {
 	// Whole packet
 	case 0:
-		return (*p)[:]
+		return p.values[:]
 	// Left or Right side of the slice
 	case 1:
 		boundary := boundaries[0]
 		if boundary < 0 {
-			return (*p)[p.Len()+boundary:]
+			return p.values[p.Len()+boundary:]
 		} else {
-			return (*p)[boundary:]
+			return p.values[boundary:]
 		}
 
 	// Slice by specified boundaries
 	default:
-		return (*p)[boundaries[0]:boundaries[1]]
+		return p.values[boundaries[0]:boundaries[1]]
 	}
 }
 
@@ -92,11 +114,11 @@ func (p *Packet) String() string {
 
 	var str bytes.Buffer
 	str.WriteString("[")
-	for i := 0; i < len(*p); i++ {
+	for i := 0; i < len(p.values); i++ {
 		if i > 0 {
 			str.WriteString(",")
 		}
-		str.WriteString(strconv.Itoa((*p)[i]))
+		str.WriteString(strconv.Itoa(p.values[i]))
 	}
 	str.WriteString("]")
 	return str.String()
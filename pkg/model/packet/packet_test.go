@@ -58,3 +58,14 @@ func TestPacketFromSize(t *testing.T) {
 		require.Equal(t, tt.size, pack.Len(), "Check packet from size: %s", pack)
 	}
 }
+
+func TestPacketID(t *testing.T) {
+	pack := New([]int{1, 2})
+	require.Equal(t, uint64(0), pack.ID())
+	pack.SetID(42)
+	require.Equal(t, uint64(42), pack.ID())
+	require.Equal(t, "[1,2]", pack.String(), "Check id is not a part of packet values")
+
+	var empty *Packet
+	require.Equal(t, uint64(0), empty.ID())
+}
@@ -0,0 +1,82 @@
+// Copyright 2024 Vladislav Klimenko. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pipeline_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/sunsingerus/pipeline/pkg/pipeline"
+)
+
+func Example() {
+	err := pipeline.New().
+		Source(pipeline.Values([]int{1, 9, 6, 4}, []int{1, 2, 3}, []int{8, 7, 7, 5})).
+		Stage(pipeline.Filter(5, 100)).
+		Stage(pipeline.Dedupe()).
+		Stage(pipeline.TopN(2)).
+		Sink(pipeline.Print(os.Stdout)).
+		Run(context.Background())
+	if err != nil {
+		fmt.Println(err)
+	}
+	// Output:
+	// [6,9]
+	// [7,8]
+}
+
+func ExampleMap() {
+	sum := &pipeline.Sum{}
+	err := pipeline.New().
+		Source(pipeline.Reader(strings.NewReader("1,2,3\n4 5 6\n"))).
+		Stage(pipeline.Map("square", func(values []int) ([]int, error) {
+			for i := range values {
+				values[i] *= values[i]
+			}
+			return values, nil
+		}).Workers(4)).
+		Sink(sum).
+		Run(context.Background())
+	if err != nil {
+		fmt.Println(err)
+	}
+	fmt.Println(sum.Get())
+	// Output: 91
+}
+
+func ExampleGenerator() {
+	collector := &pipeline.Collector{}
+	err := pipeline.New().
+		Source(pipeline.Generator(pipeline.GeneratorOptions{
+			Count: 1000,
+			Packet: pipeline.PacketOptions{
+				Size: 10,
+				Seed: 42,
+			},
+		})).
+		Workers(4).
+		Stage(pipeline.TopN(3)).
+		Stage(pipeline.Scale(10, 0).Ordered()).
+		Sink(collector).
+		Run(context.Background())
+	if err != nil {
+		fmt.Println(err)
+	}
+	packets := collector.Packets()
+	fmt.Println(len(packets), packets[0].ID(), packets[len(packets)-1].ID())
+	// Output: 1000 1 1000
+}
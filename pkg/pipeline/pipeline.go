@@ -0,0 +1,324 @@
+// Copyright 2024 Vladislav Klimenko. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package pipeline provides packet processing pipelines, which can be embedded into services:
+//
+//	err := pipeline.New().
+//		Source(pipeline.Values([]int{1, 9, 6}, []int{4, 4, 5})).
+//		Stage(pipeline.TopN(2)).
+//		Sink(pipeline.Print(os.Stdout)).
+//		Run(ctx)
+//
+// Source produces packets, which are processed by the stages in order and consumed by the sink.
+// Each stage runs its own pool of workers.
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/sunsingerus/pipeline/pkg/controller/chain"
+	"github.com/sunsingerus/pipeline/pkg/controller/metrics"
+	"github.com/sunsingerus/pipeline/pkg/controller/packet"
+	"github.com/sunsingerus/pipeline/pkg/controller/processor"
+	"github.com/sunsingerus/pipeline/pkg/controller/supervisor"
+	"github.com/sunsingerus/pipeline/pkg/controller/topology"
+	mpacket "github.com/sunsingerus/pipeline/pkg/model/packet"
+)
+
+// Packet specifies packet of values flowing through the pipeline
+type Packet = packet.Packet
+
+// NewPacket creates packet of the values
+func NewPacket(values ...int) Packet {
+	return mpacket.New(values)
+}
+
+// drainCheckInterval specifies how often pipeline checks whether all packets of exhausted source are processed
+const drainCheckInterval = 10 * time.Millisecond
+
+// Pipeline specifies pipeline of the source, the stages and the sink
+type Pipeline struct {
+	source     Source
+	stages     []*Stage
+	sink       Sink
+	workers    int
+	deadLetter DeadLetterSink
+	processor  processor.Options
+	supervisor *SuperviseOptions
+	metrics    *metrics.Registry
+	// err specifies the first error of the builder
+	err error
+	// run specifies whether pipeline has been run
+	run bool
+}
+
+// New creates new pipeline builder
+func New() *Pipeline {
+	return &Pipeline{
+		workers: chain.DefaultWorkers,
+		metrics: metrics.NewRegistry(),
+	}
+}
+
+// Source sets source of the packets
+func (p *Pipeline) Source(source Source) *Pipeline {
+	if source == nil {
+		p.fail(fmt.Errorf("nil source"))
+	}
+	p.source = source
+	return p
+}
+
+// Stage appends processing stage
+func (p *Pipeline) Stage(stage *Stage) *Pipeline {
+	if stage == nil {
+		p.fail(fmt.Errorf("nil stage"))
+		return p
+	}
+	if stage.err != nil {
+		p.fail(stage.err)
+	}
+	p.stages = append(p.stages, stage)
+	return p
+}
+
+// Sink sets sink of the processed packets
+func (p *Pipeline) Sink(sink Sink) *Pipeline {
+	if sink == nil {
+		p.fail(fmt.Errorf("nil sink"))
+	}
+	p.sink = sink
+	return p
+}
+
+// Workers sets number of workers of stages, which do not specify it
+func (p *Pipeline) Workers(workers int) *Pipeline {
+	p.workers = workers
+	return p
+}
+
+// DeadLetter sets sink of packets failed to be processed
+func (p *Pipeline) DeadLetter(sink DeadLetterSink) *Pipeline {
+	p.deadLetter = sink
+	return p
+}
+
+// Deadline sets max time to process a packet by a stage. Packets exceeded the deadline are dead-lettered
+func (p *Pipeline) Deadline(deadline time.Duration) *Pipeline {
+	p.processor.Deadline = deadline
+	return p
+}
+
+// SuperviseOptions specifies restarts of crashed stage workers
+type SuperviseOptions struct {
+	// BackoffMin specifies delay before the first restart. Delay doubles with each restart within a minute
+	BackoffMin time.Duration
+	// BackoffMax specifies max delay before restart
+	BackoffMax time.Duration
+	// MaxRestarts specifies max number of restarts of a worker per minute. Pipeline is stopped on exceeding it
+	MaxRestarts int
+}
+
+// Supervise restarts crashed stage workers. Pipeline is stopped with an error in case a worker crash-loops
+func (p *Pipeline) Supervise(opts SuperviseOptions) *Pipeline {
+	p.supervisor = &opts
+	return p
+}
+
+// StageStats specifies counters of the pipeline stage
+type StageStats struct {
+	Name string
+	// Counters specifies values of the stage counters by their names, ex.: received, delivered, filtered
+	Counters map[string]int64
+}
+
+// Stats returns counters of the source, the stages and the sink of the pipeline
+func (p *Pipeline) Stats() []StageStats {
+	var stats []StageStats
+	for _, stage := range p.metrics.Stages() {
+		stats = append(stats, StageStats{
+			Name:     stage.Name(),
+			Counters: stage.Snapshot(),
+		})
+	}
+	return stats
+}
+
+// fail records the first builder error
+func (p *Pipeline) fail(err error) {
+	if p.err == nil {
+		p.err = err
+	}
+}
+
+// Run runs pipeline until context is done or all packets of the exhausted source are processed.
+// Context done while the source produces packets is normal shutdown, so nil is returned.
+// Returns error in case pipeline is misconfigured, source fails, a stage worker crash-loops
+// or context is done once the source is exhausted, but before all its packets are processed
+func (p *Pipeline) Run(ctx context.Context) error {
+	if p.err != nil {
+		return p.err
+	}
+	if p.run {
+		return fmt.Errorf("pipeline has already been run")
+	}
+	p.run = true
+	if p.source == nil {
+		return fmt.Errorf("no source")
+	}
+	if p.sink == nil {
+		return fmt.Errorf("no sink")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var err error
+	var errOnce sync.Once
+	stop := func(_err error) {
+		errOnce.Do(func() {
+			err = _err
+		})
+		cancel()
+	}
+
+	var _supervisor *supervisor.Supervisor
+	if p.supervisor != nil {
+		_supervisor = supervisor.New(stop, supervisor.Options{
+			BackoffMin:  p.supervisor.BackoffMin,
+			BackoffMax:  p.supervisor.BackoffMax,
+			MaxRestarts: p.supervisor.MaxRestarts,
+		})
+	}
+
+	var stages []chain.Stage
+	for _, stage := range p.stages {
+		if stage.stage.Name == "" {
+			stage.stage.Name = stage.stage.Transform
+		}
+		stages = append(stages, stage.stage)
+	}
+	out := make(chan packet.Packet)
+	_chain, _err := chain.New(
+		stages,
+		func(values []int) packet.Packet {
+			return mpacket.New(values)
+		},
+		_supervisor,
+		p.metrics,
+		chain.Pipes{
+			Out:        out,
+			DeadLetter: newDeadLetterSink(p.deadLetter),
+		},
+		chain.Options{
+			Workers:   p.workers,
+			Processor: p.processor,
+		},
+	)
+	if _err != nil {
+		return _err
+	}
+	sink, _err := topology.NewSink("Sink", p.sink.Put, p.metrics.Stage("sink"), topology.Pipes{
+		In: []chan packet.Packet{out},
+	})
+	if _err != nil {
+		return _err
+	}
+
+	wg := new(sync.WaitGroup)
+	_chain.Launch(ctx, wg)
+	wg.Add(1)
+	go sink.Run(ctx, wg)
+	exhausted := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if _err := p.produce(ctx, _chain.In()); _err != nil {
+			stop(_err)
+			return
+		}
+		close(exhausted)
+	}()
+
+	select {
+	case <-ctx.Done():
+		// Pipeline is stopped while the source produces packets
+	case <-exhausted:
+		if !p.drain(ctx) {
+			stop(fmt.Errorf("pipeline is not drained: %w", ctx.Err()))
+		}
+	}
+	cancel()
+	wg.Wait()
+	_chain.Close()
+	close(out)
+	return err
+}
+
+// produce delivers packets of the source to the first stage until the source is exhausted.
+// Packets are numbered starting from 1
+func (p *Pipeline) produce(ctx context.Context, out chan packet.Packet) error {
+	stats := p.metrics.Stage("source")
+	for sequence := uint64(1); ; sequence++ {
+		pack, err := p.source.Next(ctx)
+		if errors.Is(err, ErrExhausted) {
+			log.Infof("Source - exhausted")
+			return nil
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("source: %w", err)
+		}
+		pack.SetID(sequence)
+		select {
+		case <-ctx.Done():
+			return nil
+		case out <- pack:
+			stats.Delivered.Add(1)
+		}
+	}
+}
+
+// drain waits until context is done or all packets of the exhausted source are processed.
+// Returns false in case context is done before all packets are processed
+func (p *Pipeline) drain(ctx context.Context) bool {
+	ticker := time.NewTicker(drainCheckInterval)
+	defer ticker.Stop()
+	for !p.drained() {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+		}
+	}
+	return true
+}
+
+// drained checks whether every packet delivered by the source has left the pipeline, either received by the sink or left by a stage.
+// Packets delivered by a stage are not counted, since packets delivered by workers of an ordered stage wait for their resequencer
+func (p *Pipeline) drained() bool {
+	left := p.metrics.Stage("sink").Received.Load()
+	for _, stage := range p.stages {
+		stats := p.metrics.Stage(stage.stage.Name)
+		left += stats.Filtered.Load() + stats.Dropped.Load() + stats.Rejected.Load()
+	}
+	return left == p.metrics.Stage("source").Delivered.Load()
+}
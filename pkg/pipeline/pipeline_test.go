@@ -0,0 +1,156 @@
+// Copyright 2024 Vladislav Klimenko. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPipelineInvalid(t *testing.T) {
+	tests := []struct {
+		pipeline *Pipeline
+		err      string
+	}{
+		{
+			pipeline: New().Stage(TopN(1)).Sink(&Sum{}),
+			err:      "no source",
+		},
+		{
+			pipeline: New().Source(Values()).Stage(TopN(1)),
+			err:      "no sink",
+		},
+		{
+			pipeline: New().Source(Values()).Sink(&Sum{}),
+			err:      "no stages",
+		},
+		{
+			pipeline: New().Source(Values()).Stage(TopN(-1)).Sink(&Sum{}),
+			err:      "invalid result size -1",
+		},
+		{
+			pipeline: New().Source(Values()).Stage(TopN(1)).Stage(TopN(2)).Sink(&Sum{}),
+			err:      "duplicate stage name",
+		},
+	}
+	for _, tt := range tests {
+		err := tt.pipeline.Run(context.Background())
+		require.ErrorContains(t, err, tt.err)
+	}
+}
+
+func TestPipelineRunOnce(t *testing.T) {
+	p := New().Source(Values([]int{1})).Stage(TopN(1)).Sink(&Sum{})
+	require.NoError(t, p.Run(context.Background()))
+	require.Error(t, p.Run(context.Background()))
+}
+
+func TestPipelineSourceError(t *testing.T) {
+	err := New().
+		Source(Reader(strings.NewReader("1,2\n1,x\n"))).
+		Stage(TopN(1)).
+		Sink(&Sum{}).
+		Run(context.Background())
+	require.ErrorContains(t, err, "source")
+}
+
+func TestPipelineDrain(t *testing.T) {
+	deadLetters := make(chan DeadLetter, 10)
+	sum := &Sum{}
+	p := New().
+		Source(Values([]int{1, 2}, []int{7}, []int{8, 9}, []int{}, []int{3})).
+		Workers(3).
+		Stage(Filter(2, 100)).
+		Stage(Map("odd", func(values []int) ([]int, error) {
+			if values[0]%2 == 0 {
+				return nil, fmt.Errorf("even")
+			}
+			return values, nil
+		})).
+		DeadLetter(DeadLetterFunc(func(deadLetter DeadLetter) { deadLetters <- deadLetter })).
+		Sink(sum)
+	require.NoError(t, p.Run(context.Background()), "Check pipeline stops once all packets are processed")
+	require.Equal(t, 10, sum.Get())
+	require.Len(t, deadLetters, 2)
+	require.Equal(t, "odd", (<-deadLetters).Stage)
+	var filtered int64
+	for _, stats := range p.Stats() {
+		if stats.Name == "filter" {
+			filtered = stats.Counters["filtered"]
+		}
+	}
+	require.Equal(t, int64(1), filtered)
+}
+
+func TestPipelineOrderedFiltered(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	collector := &Collector{}
+	err := New().
+		Source(Values([]int{1}, []int{100}, []int{2})).
+		Stage(Filter(0, 10).Ordered()).
+		Sink(collector).
+		Run(ctx)
+	require.NoError(t, err)
+	require.NoError(t, ctx.Err(), "Check pipeline is not stuck waiting for the filtered packet")
+	packets := collector.Packets()
+	require.Len(t, packets, 2)
+	require.Equal(t, uint64(1), packets[0].ID())
+	require.Equal(t, uint64(3), packets[1].ID())
+}
+
+func TestPipelineCancel(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	collector := &Collector{}
+	err := New().
+		Source(Generator(GeneratorOptions{Interval: time.Millisecond})).
+		Stage(TopN(3).Ordered()).
+		Sink(collector).
+		Run(ctx)
+	require.NoError(t, err, "Check pipeline stopped while the source produces is shut down normally")
+	require.NotEmpty(t, collector.Packets())
+}
+
+func TestPipelineNotDrained(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := New().
+		Source(Values([]int{1})).
+		Stage(Map("stuck", func(values []int) ([]int, error) {
+			<-ctx.Done()
+			return values, nil
+		})).
+		Sink(&Collector{}).
+		Run(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded, "Check pipeline stopped before the exhausted source is processed is not drained")
+}
+
+func TestPipelineSupervise(t *testing.T) {
+	err := New().
+		Source(Generator(GeneratorOptions{})).
+		Stage(Map("panic", func(values []int) ([]int, error) {
+			panic("crash")
+		})).
+		Supervise(SuperviseOptions{BackoffMin: time.Millisecond, BackoffMax: time.Millisecond, MaxRestarts: 2}).
+		Sink(&Sum{}).
+		Run(context.Background())
+	require.Error(t, err, "Check crash-looping stage stops the pipeline")
+}
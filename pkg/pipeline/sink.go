@@ -0,0 +1,142 @@
+// Copyright 2024 Vladislav Klimenko. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pipeline
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/sunsingerus/pipeline/pkg/controller/dlq"
+)
+
+// Sink consumes processed packets
+type Sink interface {
+	Put(Packet)
+}
+
+// SinkFunc adapts function to the Sink
+type SinkFunc func(Packet)
+
+// Put calls the function
+func (f SinkFunc) Put(pack Packet) {
+	f(pack)
+}
+
+// Print creates sink, which writes values of each packet as a line
+func Print(w io.Writer) Sink {
+	return SinkFunc(func(pack Packet) {
+		_, _ = fmt.Fprintln(w, pack)
+	})
+}
+
+// Collector collects processed packets
+type Collector struct {
+	packets []Packet
+	mux     sync.RWMutex
+}
+
+// Put collects packet
+func (c *Collector) Put(pack Packet) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.packets = append(c.packets, pack)
+}
+
+// Packets returns collected packets
+func (c *Collector) Packets() []Packet {
+	c.mux.RLock()
+	defer c.mux.RUnlock()
+	return append([]Packet(nil), c.packets...)
+}
+
+// Sum sums values of all packets
+type Sum struct {
+	sum int
+	mux sync.RWMutex
+}
+
+// Put adds values of the packet to the sum
+func (s *Sum) Put(pack Packet) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	for i := 0; i < pack.Len(); i++ {
+		s.sum += pack.Get(i)
+	}
+}
+
+// Get returns the sum
+func (s *Sum) Get() int {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+	return s.sum
+}
+
+// DeadLetter specifies packet failed to be processed
+type DeadLetter struct {
+	// Time specifies when packet was dead-lettered
+	Time time.Time
+	// Stage specifies name of the stage which failed to process the packet
+	Stage string
+	// WorkerID specifies id of the stage worker which failed to process the packet
+	WorkerID int
+	// Error specifies why packet was dead-lettered
+	Error string
+	// Values specifies values of the packet
+	Values []int
+}
+
+// DeadLetterSink consumes packets failed to be processed
+type DeadLetterSink interface {
+	Put(DeadLetter)
+}
+
+// DeadLetterFunc adapts function to the DeadLetterSink
+type DeadLetterFunc func(DeadLetter)
+
+// Put calls the function
+func (f DeadLetterFunc) Put(deadLetter DeadLetter) {
+	f(deadLetter)
+}
+
+// deadLetterSink adapts dead-letter sink of the pipeline to the stages
+type deadLetterSink struct {
+	sink DeadLetterSink
+}
+
+// newDeadLetterSink returns nil in case no dead-letter sink is set, so stages dead-letter nothing
+func newDeadLetterSink(sink DeadLetterSink) dlq.Sink {
+	if sink == nil {
+		return nil
+	}
+	return deadLetterSink{sink: sink}
+}
+
+// Put converts the entry to the dead letter
+func (s deadLetterSink) Put(entry dlq.Entry) {
+	s.sink.Put(DeadLetter{
+		Time:     entry.Time,
+		Stage:    entry.Stage,
+		WorkerID: entry.WorkerID,
+		Error:    entry.Error,
+		Values:   entry.Packet,
+	})
+}
+
+// Close does nothing, dead-letter sink is owned by the caller
+func (s deadLetterSink) Close() error {
+	return nil
+}
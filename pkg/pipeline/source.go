@@ -0,0 +1,143 @@
+// Copyright 2024 Vladislav Klimenko. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pipeline
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/sunsingerus/pipeline/pkg/controller/generator/packet_builder"
+	"github.com/sunsingerus/pipeline/pkg/controller/generator/source"
+	mpacket "github.com/sunsingerus/pipeline/pkg/model/packet"
+)
+
+// ErrExhausted is returned by source, which has no more packets
+var ErrExhausted = errors.New("source exhausted")
+
+// Source produces packets
+type Source interface {
+	// Next blocks until the next packet is available. Returns ErrExhausted in case source has no more packets
+	Next(ctx context.Context) (Packet, error)
+}
+
+// SourceFunc adapts function to the Source
+type SourceFunc func(ctx context.Context) (Packet, error)
+
+// Next calls the function
+func (f SourceFunc) Next(ctx context.Context) (Packet, error) {
+	return f(ctx)
+}
+
+// Values creates source of the packets of the values
+func Values(values ...[]int) Source {
+	next := 0
+	return SourceFunc(func(ctx context.Context) (Packet, error) {
+		if next >= len(values) {
+			return nil, ErrExhausted
+		}
+		next++
+		return NewPacket(append([]int(nil), values[next-1]...)...), nil
+	})
+}
+
+// Reader creates source of the packets read line by line, values are separated by commas or spaces.
+// Empty lines are skipped, malformed line fails the source
+func Reader(r io.Reader) Source {
+	scanner := bufio.NewScanner(r)
+	return SourceFunc(func(ctx context.Context) (Packet, error) {
+		for scanner.Scan() {
+			if strings.TrimSpace(scanner.Text()) == "" {
+				continue
+			}
+			values, err := source.ParseLine(scanner.Text())
+			if err != nil {
+				return nil, err
+			}
+			return NewPacket(values...), nil
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+		return nil, ErrExhausted
+	})
+}
+
+// GeneratorOptions specifies random packets generator options
+type GeneratorOptions struct {
+	// Interval specifies interval between packets. Zero means as fast as possible
+	Interval time.Duration
+	// Count specifies number of packets to generate. Zero means unlimited
+	Count int
+	// Packet specifies packets options
+	Packet PacketOptions
+}
+
+// PacketOptions specifies random packets options, values are distributed uniformly
+type PacketOptions struct {
+	// Size specifies size of packet. In case SizeMax is greater than Size, Size specifies min size of packet
+	Size int
+	// SizeMax specifies max size (inclusive) of packet
+	SizeMax int
+	// Seed specifies seed of the random values. Zero means packets are not reproducible
+	Seed int64
+	// ValueMin and ValueMax specify range (inclusive) of values. Zero range means [0, 19] range
+	ValueMin int
+	ValueMax int
+}
+
+// Generator creates source of random packets. Source fails in case packets options are invalid
+func Generator(opts GeneratorOptions) Source {
+	if (opts.Packet.ValueMin == 0) && (opts.Packet.ValueMax == 0) {
+		opts.Packet.ValueMax = packetbuilder.DefaultValueMax
+	}
+	builder, err := packetbuilder.New(
+		func(_len int) Packet {
+			return mpacket.New(_len)
+		},
+		packetbuilder.Options{
+			Size:     opts.Packet.Size,
+			SizeMax:  opts.Packet.SizeMax,
+			Seed:     opts.Packet.Seed,
+			ValueMin: opts.Packet.ValueMin,
+			ValueMax: opts.Packet.ValueMax,
+		},
+	)
+	generated := 0
+	next := time.Now()
+	return SourceFunc(func(ctx context.Context) (Packet, error) {
+		if err != nil {
+			return nil, err
+		}
+		if (opts.Count > 0) && (generated >= opts.Count) {
+			return nil, ErrExhausted
+		}
+		if opts.Interval > 0 {
+			next = next.Add(opts.Interval)
+			timer := time.NewTimer(time.Until(next))
+			defer timer.Stop()
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-timer.C:
+			}
+		}
+		generated++
+		return builder.Build(), nil
+	})
+}
@@ -0,0 +1,129 @@
+// Copyright 2024 Vladislav Klimenko. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pipeline
+
+import (
+	"fmt"
+
+	"github.com/sunsingerus/pipeline/pkg/controller/chain"
+	"github.com/sunsingerus/pipeline/pkg/controller/processor"
+	"github.com/sunsingerus/pipeline/pkg/controller/transform"
+)
+
+// Transformer transforms values of a packet. Values may be modified in place.
+// Nil values with nil error mean packet is filtered out, error means packet is dead-lettered
+type Transformer interface {
+	Apply(values []int) ([]int, error)
+}
+
+// Stage specifies processing stage
+type Stage struct {
+	stage chain.Stage
+	err   error
+}
+
+// NewStage creates stage, which applies transformer created by the factory for each of its workers
+func NewStage(name string, factory func() Transformer) *Stage {
+	return &Stage{
+		stage: chain.Stage{
+			Name: name,
+			Factory: func() transform.Transform {
+				return factory()
+			},
+		},
+	}
+}
+
+// Map creates stage, which applies the function to values of each packet.
+// Function may modify values in place. Nil values with nil error mean packet is filtered out.
+// Function is called concurrently in case stage has a number of workers
+func Map(name string, fn func(values []int) ([]int, error)) *Stage {
+	return NewStage(name, func() Transformer {
+		return transform.Func(fn)
+	})
+}
+
+// Transform creates stage of the transform of the kind: filter,dedupe,topn,scale
+func Transform(kind string, params map[string]string) *Stage {
+	stage := &Stage{
+		stage: chain.Stage{
+			Transform: kind,
+			Params:    params,
+		},
+	}
+	// Parameters are checked when stage is added, rather than when pipeline is run
+	if _, err := transform.New(kind, params, nil); err != nil {
+		stage.err = err
+	}
+	return stage
+}
+
+// Filter creates stage, which keeps values within [min, max] and filters out packets with no values left
+func Filter(min, max int) *Stage {
+	return Transform(transform.KindFilter, map[string]string{
+		"min": fmt.Sprint(min),
+		"max": fmt.Sprint(max),
+	})
+}
+
+// Dedupe creates stage, which removes repeated values keeping the first occurrence
+func Dedupe() *Stage {
+	return Transform(transform.KindDedupe, nil)
+}
+
+// TopN creates stage, which keeps N greatest values sorted ascending, short packets are padded with their min value
+func TopN(n int) *Stage {
+	return Transform(transform.KindTopN, map[string]string{
+		"n": fmt.Sprint(n),
+	})
+}
+
+// Scale creates stage, which multiplies values by the factor and adds the offset
+func Scale(factor, offset int) *Stage {
+	return Transform(transform.KindScale, map[string]string{
+		"factor": fmt.Sprint(factor),
+		"offset": fmt.Sprint(offset),
+	})
+}
+
+// Named sets name of the stage, names of the pipeline stages must be unique
+func (s *Stage) Named(name string) *Stage {
+	s.stage.Name = name
+	return s
+}
+
+// Workers sets number of stage workers
+func (s *Stage) Workers(workers int) *Stage {
+	s.stage.Workers = workers
+	return s
+}
+
+// Capacity sets capacity of the stage input channel
+func (s *Stage) Capacity(capacity int) *Stage {
+	s.stage.Capacity = capacity
+	return s
+}
+
+// DropOnOverflow drops packets in case the next stage is not ready to accept them, instead of waiting
+func (s *Stage) DropOnOverflow() *Stage {
+	s.stage.Overflow = processor.OverflowDrop
+	return s
+}
+
+// Ordered delivers packets in the order they were produced by the source
+func (s *Stage) Ordered() *Stage {
+	s.stage.Ordered = true
+	return s
+}